@@ -0,0 +1,232 @@
+// Package batch renders streams of orders concurrently with a bounded worker pool.
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+// ErrInvalidOrder is reported for jobs whose order fails validation
+var ErrInvalidOrder = errors.New("invalid order")
+
+// Job is a single order to render
+type Job struct {
+	// Index is the position of the job in the input stream
+	Index int
+	// ID identifies the job in the manifest, usually the order ID
+	ID      string
+	Order   ordersummary.OrderSummary
+	Options ordersummary.Options
+	// Path is the file the image is written to
+	Path string
+}
+
+// Result describes the outcome of a job
+type Result struct {
+	Index    int           `json:"index"`
+	ID       string        `json:"id,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Bytes    int64         `json:"bytes,omitempty"`
+	Width    int           `json:"width,omitempty"`
+	Height   int           `json:"height,omitempty"`
+	Duration time.Duration `json:"durationNs"`
+	Error    string        `json:"error,omitempty"`
+	// Truncated lists the items whose names were cut short in the image
+	Truncated []int `json:"truncatedItems,omitempty"`
+	// AltText gives the header, item count, first few item names and total when
+	// Options.AltText is set
+	AltText string `json:"altText,omitempty"`
+	// HitMap locates the item rows and totals in the image when Options.HitMap is set
+	HitMap *ordersummary.HitMap `json:"hitMap,omitempty"`
+	// Err is the error that failed the job, if any
+	Err error `json:"-"`
+}
+
+// Config configures a batch run
+type Config struct {
+	// Workers is the number of orders rendered concurrently, GOMAXPROCS when zero
+	Workers int
+	// Fonts is shared by every worker; a new cache is created when nil
+	Fonts *ordersummary.FontCache
+	// Create opens the destination for a job, creating the file at job.Path when
+	// nil. When the render fails, the destination is discarded with its
+	// Abort() error method if it has one, and closed otherwise.
+	Create func(job Job) (io.WriteCloser, error)
+}
+
+// Run renders jobs until the channel is closed or ctx is cancelled. Results are
+// delivered in completion order; a failed job does not stop the batch.
+func Run(ctx context.Context, cfg Config, jobs <-chan Job) <-chan Result {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if cfg.Fonts == nil {
+		cfg.Fonts = ordersummary.NewFontCache()
+	}
+	if cfg.Create == nil {
+		cfg.Create = createFile
+	}
+
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job, ok := <-jobs:
+					if !ok {
+						return
+					}
+					select {
+					case results <- renderJob(cfg, job):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func renderJob(cfg Config, job Job) Result {
+	start := time.Now()
+	res := Result{Index: job.Index, ID: job.ID, Path: job.Path}
+	fail := func(err error) Result {
+		res.Err = err
+		res.Error = err.Error()
+		res.Duration = time.Since(start)
+		return res
+	}
+
+	if err := job.Order.Validate(); err != nil {
+		return fail(fmt.Errorf("%w: %w", ErrInvalidOrder, err))
+	}
+
+	w, err := cfg.Create(job)
+	if err != nil {
+		return fail(err)
+	}
+
+	opts := job.Options
+	opts.Fonts = cfg.Fonts
+	r, err := ordersummary.Render(job.Order, w, opts)
+	if err != nil {
+		if a, ok := w.(aborter); ok {
+			a.Abort()
+		} else {
+			w.Close()
+		}
+		return fail(err)
+	}
+	if err := w.Close(); err != nil {
+		return fail(err)
+	}
+
+	res.Bytes = r.Bytes
+	res.Width = r.Width
+	res.Height = r.Height
+	res.Truncated = r.TruncatedItems
+	res.AltText = r.AltText
+	res.HitMap = r.HitMap
+	res.Duration = time.Since(start)
+	return res
+}
+
+// aborter is a destination that can be discarded instead of closed
+type aborter interface {
+	Abort() error
+}
+
+// createFile writes to a temporary file beside job.Path, which is renamed into
+// place on Close, so a failed render never leaves a partial image at job.Path
+func createFile(job Job) (io.WriteCloser, error) {
+	dir := filepath.Dir(job.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(job.Path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &pendingFile{File: f, path: job.Path}, nil
+}
+
+// pendingFile is a temporary file that replaces path when closed
+type pendingFile struct {
+	*os.File
+	path string
+}
+
+func (p *pendingFile) Close() error {
+	if err := p.File.Chmod(0o644); err != nil {
+		p.Abort()
+		return err
+	}
+	if err := p.File.Close(); err != nil {
+		os.Remove(p.Name())
+		return err
+	}
+	if err := os.Rename(p.Name(), p.path); err != nil {
+		os.Remove(p.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort discards the temporary file, leaving path untouched
+func (p *pendingFile) Abort() error {
+	p.File.Close()
+	return os.Remove(p.Name())
+}
+
+// Manifest summarizes a batch run
+type Manifest struct {
+	Started   time.Time     `json:"started"`
+	Finished  time.Time     `json:"finished"`
+	Duration  time.Duration `json:"durationNs"`
+	Workers   int           `json:"workers"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []Result      `json:"results"`
+}
+
+// Add records a result in the manifest
+func (m *Manifest) Add(r Result) {
+	if r.Err != nil || r.Error != "" {
+		m.Failed++
+	} else {
+		m.Succeeded++
+	}
+	m.Results = append(m.Results, r)
+}
+
+// WriteFile writes the manifest as indented JSON to path, with results in input order
+func (m *Manifest) WriteFile(path string) error {
+	sort.Slice(m.Results, func(i, j int) bool { return m.Results[i].Index < m.Results[j].Index })
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,156 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+func testJob(dir string, index int) Job {
+	return Job{
+		Index: index,
+		Order: ordersummary.OrderSummary{
+			Items:    []ordersummary.Item{{Name: "Orchid", Quantity: 1, Price: 349.99}},
+			Total:    349.99,
+			Currency: "INR",
+		},
+		Options: ordersummary.Options{Layout: ordersummary.DefaultLayout()},
+		Path:    filepath.Join(dir, "out", "order.png"),
+	}
+}
+
+// oversized returns options that pass validation but fail to render
+func oversized() ordersummary.Options {
+	layout := ordersummary.DefaultLayout()
+	layout.Width = 100_000
+	layout.Scale = ordersummary.MaxScale
+	return ordersummary.Options{Layout: layout}
+}
+
+// run renders jobs with cfg and returns their results
+func run(cfg Config, jobs ...Job) []Result {
+	ch := make(chan Job, len(jobs))
+	for _, job := range jobs {
+		ch <- job
+	}
+	close(ch)
+	var results []Result
+	for r := range Run(context.Background(), cfg, ch) {
+		results = append(results, r)
+	}
+	return results
+}
+
+func TestRunWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	job := testJob(dir, 0)
+	results := run(Config{Workers: 1}, job)
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("got results %+v", results)
+	}
+	info, err := os.Stat(job.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != results[0].Bytes || info.Size() == 0 {
+		t.Errorf("file has %d bytes, result reports %d", info.Size(), results[0].Bytes)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(job.Path))
+	if len(entries) != 1 {
+		t.Errorf("got %d files in the output directory, want only the image", len(entries))
+	}
+}
+
+func TestRunFailureLeavesNoFile(t *testing.T) {
+	dir := t.TempDir()
+	job := testJob(dir, 0)
+	job.Options = oversized()
+	results := run(Config{Workers: 1}, job)
+	if len(results) != 1 || !errors.Is(results[0].Err, ordersummary.ErrCanvasTooLarge) {
+		t.Fatalf("got results %+v, want ErrCanvasTooLarge", results)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(job.Path))
+	if len(entries) != 0 {
+		t.Errorf("failed render left %d files behind", len(entries))
+	}
+}
+
+func TestRunFailureKeepsExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	job := testJob(dir, 0)
+	if err := os.MkdirAll(filepath.Dir(job.Path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(job.Path, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	job.Options = oversized()
+	run(Config{Workers: 1}, job)
+	if data, err := os.ReadFile(job.Path); err != nil || string(data) != "previous" {
+		t.Errorf("existing image was replaced: %q, %v", data, err)
+	}
+}
+
+func TestRunInvalidOrder(t *testing.T) {
+	job := testJob(t.TempDir(), 0)
+	job.Order.Items[0].Quantity = 0
+	results := run(Config{Workers: 1, Create: func(Job) (io.WriteCloser, error) {
+		t.Error("Create called for an invalid order")
+		return nil, errors.New("unexpected")
+	}}, job)
+	if len(results) != 1 || !errors.Is(results[0].Err, ErrInvalidOrder) {
+		t.Errorf("got results %+v, want ErrInvalidOrder", results)
+	}
+}
+
+type recordingWriter struct {
+	io.Writer
+	closed, aborted bool
+}
+
+func (w *recordingWriter) Close() error { w.closed = true; return nil }
+func (w *recordingWriter) Abort() error { w.aborted = true; return nil }
+
+func TestRunAbortsCustomDestination(t *testing.T) {
+	var ok, failed recordingWriter
+	ok.Writer, failed.Writer = io.Discard, io.Discard
+	good := testJob("", 0)
+	bad := testJob("", 1)
+	bad.Options = oversized()
+	results := run(Config{Workers: 1, Create: func(job Job) (io.WriteCloser, error) {
+		if job.Index == 0 {
+			return &ok, nil
+		}
+		return &failed, nil
+	}}, good, bad)
+	if len(results) != 2 {
+		t.Fatalf("got %d results", len(results))
+	}
+	if !ok.closed || ok.aborted {
+		t.Errorf("successful destination: closed %v, aborted %v", ok.closed, ok.aborted)
+	}
+	if failed.closed || !failed.aborted {
+		t.Errorf("failed destination: closed %v, aborted %v", failed.closed, failed.aborted)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	var m Manifest
+	m.Add(Result{Index: 1})
+	m.Add(Result{Index: 0, Error: "boom"})
+	if m.Succeeded != 1 || m.Failed != 1 {
+		t.Errorf("got %d succeeded, %d failed", m.Succeeded, m.Failed)
+	}
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := m.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if m.Results[0].Index != 0 {
+		t.Errorf("results are not in input order")
+	}
+}
@@ -0,0 +1,130 @@
+// Package cache stores rendered order summary images keyed by a hash of everything
+// that affects their pixels.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"net/http"
+	"strings"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+// keyVersion is mixed into every key so a change to the canonical form or to the
+// renderer output invalidates previously cached images
+const keyVersion = 4
+
+// Cache stores rendered images by key
+type Cache interface {
+	// Get returns the cached data for key and whether it was found
+	Get(key string) ([]byte, bool, error)
+	// Set stores data under key
+	Set(key string, data []byte) error
+}
+
+// Key returns a stable hex-encoded SHA-256 hash of the canonicalized order and options
+func Key(order ordersummary.OrderSummary, opts ordersummary.Options) (string, error) {
+	format, err := ordersummary.ParseFormat(string(opts.Format))
+	if err != nil {
+		return "", err
+	}
+	quality := 0
+	if format == ordersummary.FormatJPEG {
+		quality = opts.JPEGQuality
+		if quality <= 0 {
+			quality = ordersummary.DefaultJPEGQuality
+		}
+	}
+	theme := opts.Theme
+	if theme == (ordersummary.Theme{}) {
+		theme = ordersummary.DefaultTheme()
+	}
+
+	// Only normalize what cannot change the output. The currency and the time
+	// zone of CreatedAt are drawn as given, so they are hashed as given.
+	if len(order.Items) == 0 {
+		order.Items = nil
+	}
+
+	canonical := struct {
+		Version     int                       `json:"v"`
+		Order       ordersummary.OrderSummary `json:"order"`
+		Layout      ordersummary.Layout       `json:"layout"`
+		Text        ordersummary.TextContent  `json:"text"`
+		Footer      string                    `json:"footer"`
+		Theme       ordersummary.Theme        `json:"theme"`
+		Format      ordersummary.Format       `json:"format"`
+		JPEGQuality int                       `json:"jpegQuality"`
+		Language    string                    `json:"language,omitempty"`
+		Logo        string                    `json:"logo,omitempty"`
+	}{keyVersion, order, opts.Layout, opts.Text, opts.Footer, theme, format, quality, opts.Language, imageDigest(opts.Logo)}
+
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// imageDigest hashes the size and pixels of img, or returns "" when it is nil
+func imageDigest(img image.Image) string {
+	if img == nil {
+		return ""
+	}
+	b := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+	h := sha256.New()
+	fmt.Fprintf(h, "%dx%d:", b.Dx(), b.Dy())
+	h.Write(nrgba.Pix)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Render returns the image for order from c, rendering and storing it on a miss.
+// It reports the cache key and whether the image was served from the cache.
+func Render(c Cache, order ordersummary.OrderSummary, opts ordersummary.Options) (data []byte, key string, hit bool, err error) {
+	key, err = Key(order, opts)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if data, ok, err := c.Get(key); err == nil && ok {
+		return data, key, true, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := ordersummary.Render(order, &buf, opts); err != nil {
+		return nil, key, false, err
+	}
+	data = buf.Bytes()
+	if err := c.Set(key, data); err != nil {
+		return data, key, false, err
+	}
+	return data, key, false, nil
+}
+
+// ETag returns the strong entity tag for a cache key
+func ETag(key string) string {
+	return `"` + key + `"`
+}
+
+// NotModified reports whether the If-None-Match header of r matches etag
+func NotModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
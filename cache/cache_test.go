@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+func testOrder() ordersummary.OrderSummary {
+	return ordersummary.OrderSummary{
+		Items:    []ordersummary.Item{{Name: "Orchid", Quantity: 1, Price: 349.99}},
+		Subtotal: 349.99,
+		Total:    349.99,
+		Currency: "INR",
+	}
+}
+
+// withLayout sets the default layout, which Render requires
+func withLayout(opts ordersummary.Options) ordersummary.Options {
+	opts.Layout = ordersummary.DefaultLayout()
+	return opts
+}
+
+func mustKey(t *testing.T, order ordersummary.OrderSummary, opts ordersummary.Options) string {
+	t.Helper()
+	key, err := Key(order, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyIsStable(t *testing.T) {
+	a := mustKey(t, testOrder(), withLayout(ordersummary.Options{}))
+	if b := mustKey(t, testOrder(), withLayout(ordersummary.Options{Theme: ordersummary.DefaultTheme(), Format: "png"})); a != b {
+		t.Errorf("equivalent options give keys %s and %s", a, b)
+	}
+}
+
+// TestKeyDiffersWithOutput renders pairs of orders whose output differs and
+// checks that their keys differ too
+func TestKeyDiffersWithOutput(t *testing.T) {
+	lower := testOrder()
+	lower.Currency = "inr"
+	spaced := testOrder()
+	spaced.Currency = "INR "
+
+	at := time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC)
+	utc, ist := testOrder(), testOrder()
+	utc.CreatedAt = at
+	ist.CreatedAt = at.In(time.FixedZone("IST", 5*3600+1800))
+	dated := withLayout(ordersummary.Options{Footer: `{{date .CreatedAt "2006-01-02"}}`})
+
+	for _, tt := range []struct {
+		name string
+		a, b ordersummary.OrderSummary
+		opts ordersummary.Options
+	}{
+		{"currency case", testOrder(), lower, withLayout(ordersummary.Options{})},
+		{"currency space", testOrder(), spaced, withLayout(ordersummary.Options{Format: ordersummary.FormatText})},
+		{"time zone", utc, ist, dated},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var a, b bytes.Buffer
+			if _, err := ordersummary.Render(tt.a, &a, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ordersummary.Render(tt.b, &b, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(a.Bytes(), b.Bytes()) {
+				t.Fatal("orders render the same output")
+			}
+			if mustKey(t, tt.a, tt.opts) == mustKey(t, tt.b, tt.opts) {
+				t.Error("orders with different output share a key")
+			}
+		})
+	}
+}
+
+func TestRenderCaches(t *testing.T) {
+	c := NewLRU(1 << 20)
+	opts := withLayout(ordersummary.Options{})
+	first, key, hit, err := Render(c, testOrder(), opts)
+	if err != nil || hit {
+		t.Fatalf("first render: hit %v, err %v", hit, err)
+	}
+	second, key2, hit, err := Render(c, testOrder(), opts)
+	if err != nil || !hit || key2 != key || !bytes.Equal(first, second) {
+		t.Fatalf("second render: hit %v, same key %v, same data %v, err %v", hit, key2 == key, bytes.Equal(first, second), err)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := ETag("abc")
+	for header, want := range map[string]bool{
+		"":           false,
+		`"abc"`:      true,
+		`W/"abc"`:    true,
+		`"x", "abc"`: true,
+		`"abd"`:      false,
+		"*":          true,
+	} {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			r.Header.Set("If-None-Match", header)
+		}
+		if got := NotModified(r, etag); got != want {
+			t.Errorf("NotModified(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
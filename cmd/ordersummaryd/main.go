@@ -0,0 +1,261 @@
+// Command ordersummaryd serves order summary images over HTTP.
+//
+// Given a webhook secret, it also renders orders posted by Shopify or WooCommerce
+// to /webhooks/{platform} and stores the images in -sink-dir or an S3 bucket.
+// S3 credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN.
+//
+// Given -queue, it accepts render jobs on /jobs and renders them in the
+// background with -workers workers, storing images in the same sink and posting
+// the outcome to each job's callback URL. The queue is either "memory" or a Redis
+// URL shared by several processes, such as redis://localhost:6379/0; a process
+// started with -workers 0 only accepts jobs. Callback URLs must be on public
+// hosts, or on the hosts given with -callback-host.
+//
+// Logs are written to stderr with log/slog, as logfmt text or JSON lines, and
+// -metrics serves Prometheus metrics on /metrics.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/biswaz/img-maker/cache"
+	"github.com/biswaz/img-maker/metrics"
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/queue"
+	"github.com/biswaz/img-maker/server"
+	"github.com/biswaz/img-maker/sink"
+	"github.com/biswaz/img-maker/webhook"
+	"github.com/biswaz/img-maker/worker"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	maxBody := flag.Int64("max-body", server.DefaultMaxBodyBytes, "maximum request body size in bytes")
+	maxItems := flag.Int("max-items", server.DefaultMaxItems, "maximum number of items per order")
+	footer := flag.String("footer", "Powered by Zoko", "default footer text")
+	cacheBytes := flag.Int64("cache-bytes", 64<<20, "size of the in-memory render cache in bytes, 0 to disable")
+	cacheDir := flag.String("cache-dir", "", "directory for an on-disk render cache, used instead of the in-memory cache")
+	shopifySecret := flag.String("shopify-secret", os.Getenv("SHOPIFY_WEBHOOK_SECRET"), "Shopify webhook signing secret (default $SHOPIFY_WEBHOOK_SECRET)")
+	wooSecret := flag.String("woocommerce-secret", os.Getenv("WOOCOMMERCE_WEBHOOK_SECRET"), "WooCommerce webhook signing secret (default $WOOCOMMERCE_WEBHOOK_SECRET)")
+	sinkDir := flag.String("sink-dir", "", "directory to store images rendered from webhooks")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3-compatible endpoint to store images rendered from webhooks, such as http://localhost:9000")
+	s3Region := flag.String("s3-region", "us-east-1", "S3 region")
+	s3Bucket := flag.String("s3-bucket", "", "S3 bucket")
+	s3Prefix := flag.String("s3-prefix", "", "prefix for S3 object keys")
+	s3Presign := flag.Duration("s3-presign", 0, "report presigned URLs valid this long instead of plain object URLs")
+	queueURL := flag.String("queue", "", `job queue for /jobs: "memory" or a redis:// URL; jobs are disabled when empty`)
+	workers := flag.Int("workers", 2, "number of jobs rendered at once, 0 to only accept jobs")
+	maxAttempts := flag.Int("max-attempts", worker.DefaultMaxAttempts, "times a job is tried before it is dead-lettered")
+	callbackSecret := flag.String("callback-secret", os.Getenv("ORDERSUMMARY_CALLBACK_SECRET"), "secret signing job callbacks (default $ORDERSUMMARY_CALLBACK_SECRET)")
+	logFormat := flag.String("log-format", "text", "log format: text or json")
+	logLevel := flag.String("log-level", "info", "minimum log level: debug, info, warn or error")
+	serveMetrics := flag.Bool("metrics", false, "serve Prometheus metrics on /metrics")
+	var locales, callbackHosts stringList
+	flag.Var(&locales, "locale-file", "JSON locale file to add to the message catalog (repeatable)")
+	flag.Var(&callbackHosts, "callback-host", "host jobs may post callbacks to, such as hooks.example.com or *.example.com (repeatable); any public host when unset")
+	flag.Parse()
+
+	logger, err := newLogger(*logFormat, *logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	var m *metrics.Metrics
+	if *serveMetrics {
+		m = metrics.New()
+		ordersummary.SetObserver(m)
+	}
+
+	catalog := ordersummary.NewCatalog()
+	for _, path := range locales {
+		if err := catalog.LoadFile(path); err != nil {
+			fatal("failed to load locale", "path", path, "err", err)
+		}
+	}
+
+	var renderCache cache.Cache
+	switch {
+	case *cacheDir != "":
+		disk, err := cache.NewDisk(*cacheDir)
+		if err != nil {
+			fatal("failed to open cache", "dir", *cacheDir, "err", err)
+		}
+		renderCache = disk
+	case *cacheBytes > 0:
+		renderCache = cache.NewLRU(*cacheBytes)
+	}
+
+	var store sink.Sink
+	switch {
+	case *s3Endpoint != "":
+		store = &sink.S3{
+			Endpoint:      *s3Endpoint,
+			Region:        *s3Region,
+			Bucket:        *s3Bucket,
+			Prefix:        *s3Prefix,
+			AccessKey:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:     os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:  os.Getenv("AWS_SESSION_TOKEN"),
+			PresignExpiry: *s3Presign,
+			HTTPClient:    &http.Client{Timeout: 30 * time.Second},
+		}
+	case *sinkDir != "":
+		store = sink.Dir{Root: *sinkDir}
+	}
+
+	var jobs queue.Queue
+	switch *queueURL {
+	case "":
+	case "memory":
+		jobs = queue.NewMemory()
+	default:
+		redis, err := queue.ParseRedisURL(*queueURL)
+		if err != nil {
+			fatal("invalid -queue", "err", err)
+		}
+		pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = redis.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			fatal("failed to reach the job queue", "addr", redis.Addr, "err", err)
+		}
+		jobs = redis
+	}
+	if jobs != nil && store == nil {
+		fatal("jobs need -sink-dir or -s3-endpoint to store images")
+	}
+
+	srv := server.New(server.Config{
+		MaxBodyBytes:  *maxBody,
+		MaxItems:      *maxItems,
+		Catalog:       catalog,
+		Footer:        *footer,
+		Cache:         renderCache,
+		Queue:         jobs,
+		CallbackHosts: callbackHosts,
+		Metrics:       m,
+	})
+	handler := srv.Handler()
+	if *shopifySecret != "" || *wooSecret != "" {
+		webhooks, err := webhook.NewHandler(webhook.Config{
+			Secrets: map[string]string{webhook.Shopify.Name: *shopifySecret, webhook.WooCommerce.Name: *wooSecret},
+			Sink:    store,
+			Options: func(order ordersummary.OrderSummary) (ordersummary.Options, error) {
+				text, err := catalog.TextContent(ordersummary.DefaultLocale, len(order.Items), nil)
+				if err != nil {
+					return ordersummary.Options{}, err
+				}
+				return ordersummary.Options{Layout: ordersummary.DefaultLayout(), Text: text, Footer: *footer}, nil
+			},
+		})
+		if errors.Is(err, webhook.ErrNoSink) {
+			fatal("webhooks need -sink-dir or -s3-endpoint to store images")
+		}
+		if err != nil {
+			fatal("failed to set up webhooks", "err", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/webhooks/", webhooks)
+		mux.Handle("/", handler)
+		handler = mux
+	}
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := srv.WarmUp(); err != nil {
+			slog.Error("warm-up failed", "err", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workersDone := make(chan struct{})
+	if jobs != nil && *workers > 0 {
+		w := worker.New(worker.Config{
+			Queue:          jobs,
+			Sink:           store,
+			Prepare:        srv.Prepare,
+			Concurrency:    *workers,
+			MaxAttempts:    *maxAttempts,
+			CallbackSecret: *callbackSecret,
+			CallbackHosts:  callbackHosts,
+		})
+		go func() {
+			defer close(workersDone)
+			if err := w.Run(ctx); err != nil {
+				slog.Error("workers stopped", "err", err)
+				stop()
+			}
+		}()
+		slog.Info("started workers", "workers", *workers)
+	} else {
+		close(workersDone)
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.SetReady(false)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("shutdown failed", "err", err)
+		}
+	}()
+
+	slog.Info("listening", "addr", *addr, "metrics", *serveMetrics)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("server failed", "err", err)
+	}
+	// Let the workers finish the jobs they hold
+	<-workersDone
+}
+
+// newLogger returns a logger writing to stderr in the given format and level
+func newLogger(format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid -log-level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid -log-format %q, use text or json", format)
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// stringList collects repeated string flags
+type stringList []string
+
+func (l *stringList) String() string { return "" }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
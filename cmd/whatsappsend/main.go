@@ -0,0 +1,109 @@
+// Command whatsappsend renders an order summary and sends it as a WhatsApp image
+// message through the Cloud API. With -fake it talks to a local stub instead,
+// which needs no account and logs what the stub received:
+//
+//	go run ./cmd/whatsappsend -fake -fail 2 -in order.json -to 15550001234
+//	WHATSAPP_TOKEN=... go run ./cmd/whatsappsend -phone-id 1234 -in order.json -to 15550001234
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/whatsapp"
+	"github.com/biswaz/img-maker/whatsapp/whatsapptest"
+)
+
+func main() {
+	input := flag.String("in", "", "order JSON file")
+	to := flag.String("to", "", "recipient phone number in international format")
+	caption := flag.String("caption", "", "image caption (default: the summary's alt text)")
+	format := flag.String("format", "png", "image format: png or jpeg")
+	locale := flag.String("locale", ordersummary.DefaultLocale, "locale for labels")
+	footer := flag.String("footer", "Powered by Zoko", "footer text")
+	phoneID := flag.String("phone-id", "", "business phone number ID")
+	token := flag.String("token", os.Getenv("WHATSAPP_TOKEN"), "access token (default $WHATSAPP_TOKEN)")
+	baseURL := flag.String("base-url", whatsapp.DefaultBaseURL, "Cloud API base URL")
+	fake := flag.Bool("fake", false, "send to a local stub server instead of the Cloud API")
+	fail := flag.Int("fail", 0, "with -fake, fail this many requests with 503 first to exercise retries")
+	timeout := flag.Duration("timeout", time.Minute, "give up after this long, including retries")
+	flag.Parse()
+
+	if *input == "" || *to == "" {
+		fatal("-in and -to are required")
+	}
+	if !*fake && (*phoneID == "" || *token == "") {
+		fatal("-phone-id and -token are required unless -fake is set")
+	}
+	data, err := os.ReadFile(*input)
+	if err != nil {
+		fatal("failed to read the order", "err", err)
+	}
+	var order ordersummary.OrderSummary
+	if err := json.Unmarshal(data, &order); err != nil {
+		fatal("failed to parse the order", "path", *input, "err", err)
+	}
+	if err := order.Validate(); err != nil {
+		fatal("invalid order", "path", *input, "err", err)
+	}
+	text, err := ordersummary.TextContentForLocale(*locale, len(order.Items))
+	if err != nil {
+		fatal("failed to load labels", "locale", *locale, "err", err)
+	}
+	opts := ordersummary.Options{
+		Layout:   ordersummary.DefaultLayout(),
+		Text:     text,
+		Footer:   *footer,
+		Format:   ordersummary.Format(*format),
+		Language: *locale,
+	}
+
+	var client whatsapp.Messenger = &whatsapp.Client{BaseURL: *baseURL, PhoneNumberID: *phoneID, Token: *token, HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+	if *fake {
+		srv := whatsapptest.NewServer()
+		srv.FailNext(*fail, http.StatusServiceUnavailable, "")
+		client = srv.Client()
+		err = send(client, *to, order, opts, *caption, *timeout)
+		report(srv)
+		srv.Close()
+	} else {
+		err = send(client, *to, order, opts, *caption, *timeout)
+	}
+	if err != nil {
+		fatal("send failed", "err", err)
+	}
+}
+
+func send(m whatsapp.Messenger, to string, order ordersummary.OrderSummary, opts ordersummary.Options, caption string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	sent, err := whatsapp.SendOrderSummary(ctx, m, to, order, opts, caption)
+	if err != nil {
+		return err
+	}
+	slog.Info("sent", "message", sent.MessageID, "format", sent.Format, "bytes", sent.Bytes, "media", sent.MediaID)
+	return nil
+}
+
+// report logs what the stub received
+func report(srv *whatsapptest.Server) {
+	slog.Info("stub handled requests", "count", srv.Requests())
+	for _, u := range srv.Uploads() {
+		slog.Info("stub upload", "id", u.ID, "mime_type", u.MimeType, "bytes", len(u.Data))
+	}
+	for _, m := range srv.Messages() {
+		slog.Info("stub message", "id", m.ID, "to", m.To, "media", m.MediaID, "caption", m.Caption)
+	}
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
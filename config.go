@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+// fileConfig is the JSON config file accepted by -config. Flags take precedence over it.
+type fileConfig struct {
+	Layout      *ordersummary.Layout `json:"layout"`
+	Theme       *ordersummary.Theme  `json:"theme"`
+	Locale      string               `json:"locale"`
+	LocaleFiles []string             `json:"localeFiles"`
+	Text        map[string]string    `json:"text"`
+	Footer      *string              `json:"footer"`
+	Format      string               `json:"format"`
+	JPEGQuality int                  `json:"jpegQuality"`
+}
+
+// config holds the resolved command-line options
+type config struct {
+	Input    string
+	Output   string
+	Sample   bool
+	Verbose  bool
+	LogJSON  bool
+	Workers  int
+	Manifest string
+	AltText  bool
+	HitMap   bool
+
+	Layout      ordersummary.Layout
+	Theme       ordersummary.Theme
+	Locale      string
+	Text        map[string]string
+	Footer      string
+	Format      ordersummary.Format
+	JPEGQuality int
+	Logo        image.Image
+
+	catalog *ordersummary.Catalog
+}
+
+func parseFlags(args []string) (*config, error) {
+	fs := flag.NewFlagSet("img-maker", flag.ContinueOnError)
+	configPath := fs.String("config", "", "JSON config file with layout, theme, locale, text, footer and format")
+	input := fs.String("in", "-", "order JSON or NDJSON file, - for stdin")
+	output := fs.String("o", "order_summary.png", "output path, - for stdout; {index} and {id} are replaced per order")
+	sample := fs.Bool("sample", false, "render the built-in sample order instead of reading input")
+	verbose := fs.Bool("v", false, "log every rendered order")
+	logJSON := fs.Bool("log-json", false, "write logs to stderr as JSON lines")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "number of orders rendered concurrently")
+	manifest := fs.String("manifest", "", "write a JSON manifest of outputs and timings to this path")
+	altText := fs.Bool("alt-text", false, "add alt text with the item count, the first item names in full and the total to the manifest")
+	hitMap := fs.Bool("hitmap", false, "add the pixel regions of item rows and totals to the manifest")
+	format := fs.String("format", "", "output format: png, jpeg, text or html (default from the output extension)")
+	quality := fs.Int("quality", 0, "JPEG quality 1-100")
+	theme := fs.String("theme", "", "built-in theme name: "+strings.Join(ordersummary.ThemeNames(), ", "))
+	locale := fs.String("locale", "", "locale for labels, e.g. en, hi, ta, ar, es")
+	footer := fs.String("footer", "Powered by Zoko", "footer text")
+	logo := fs.String("logo", "", "PNG or JPEG image drawn above the header")
+	width := fs.Int("width", 0, "image width in pixels")
+	margin := fs.Int("margin", 0, "outer margin in pixels")
+	scale := fs.Float64("scale", 0, "device pixels per layout pixel, such as 2 for retina displays")
+	maxLines := fs.Int("max-lines", 0, "truncate item names to this many lines, 0 for no limit")
+	var localeFiles, texts, hyphenation stringList
+	fs.Var(&localeFiles, "locale-file", "JSON locale file to add to the catalog (repeatable)")
+	fs.Var(&hyphenation, "hyphenation", "hyphenation dictionary for a language as lang=path, one hyphenated word per line (repeatable)")
+	fs.Var(&texts, "text", "override a label as key=value, e.g. header='Receipt' (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	cfg := &config{
+		Input:    *input,
+		Output:   *output,
+		Sample:   *sample,
+		Verbose:  *verbose,
+		LogJSON:  *logJSON,
+		Workers:  *workers,
+		Manifest: *manifest,
+		AltText:  *altText,
+		HitMap:   *hitMap,
+		Layout:   ordersummary.DefaultLayout(),
+		Locale:   ordersummary.DefaultLocale,
+		Text:     make(map[string]string),
+		Footer:   *footer,
+		catalog:  ordersummary.NewCatalog(),
+	}
+
+	if *configPath != "" {
+		fc, err := loadFileConfig(*configPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg.apply(fc, set)
+		localeFiles = append(fc.LocaleFiles, localeFiles...)
+	}
+
+	if set["format"] {
+		cfg.Format = ordersummary.Format(*format)
+	}
+	if set["quality"] {
+		cfg.JPEGQuality = *quality
+	}
+	if set["theme"] {
+		t, ok := ordersummary.ThemeByName(*theme)
+		if !ok {
+			return nil, fmt.Errorf("unknown theme %q", *theme)
+		}
+		cfg.Theme = t
+	}
+	if set["locale"] {
+		cfg.Locale = *locale
+	}
+	if set["width"] {
+		cfg.Layout.Width = *width
+	}
+	if set["margin"] {
+		cfg.Layout.Margin = *margin
+	}
+	if set["scale"] {
+		cfg.Layout.Scale = *scale
+	}
+	if set["max-lines"] {
+		cfg.Layout.MaxItemLines = *maxLines
+	}
+	for _, kv := range texts {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("-text %q: expected key=value", kv)
+		}
+		cfg.Text[key] = value
+	}
+
+	for _, kv := range hyphenation {
+		lang, path, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("-hyphenation %q: expected lang=path", kv)
+		}
+		if err := loadHyphenation(lang, path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range localeFiles {
+		if err := cfg.catalog.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if *logo != "" {
+		img, err := loadImage(*logo)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Logo = img
+	}
+
+	if cfg.Format == "" {
+		cfg.Format = formatFromPath(cfg.Output)
+	}
+	f, err := ordersummary.ParseFormat(string(cfg.Format))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Format = f
+
+	if err := cfg.Layout.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Resolve the labels once up front so an unknown locale or key is a usage error
+	if _, err := cfg.catalog.TextContent(cfg.Locale, 1, cfg.Text); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadFileConfig(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fc fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &fc, nil
+}
+
+// loadImage decodes the PNG or JPEG image at path
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+// loadHyphenation registers the dictionary at path as the hyphenator for lang
+func loadHyphenation(lang, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d, err := ordersummary.LoadDictionary(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	ordersummary.RegisterHyphenator(lang, d)
+	return nil
+}
+
+// apply copies the config file values that were not also given as flags
+func (cfg *config) apply(fc *fileConfig, set map[string]bool) {
+	if fc.Layout != nil {
+		cfg.Layout = fc.Layout.WithDefaults()
+	}
+	if fc.Theme != nil {
+		cfg.Theme = *fc.Theme
+	}
+	if fc.Locale != "" {
+		cfg.Locale = fc.Locale
+	}
+	for k, v := range fc.Text {
+		cfg.Text[k] = v
+	}
+	if fc.Footer != nil && !set["footer"] {
+		cfg.Footer = *fc.Footer
+	}
+	if fc.Format != "" {
+		cfg.Format = ordersummary.Format(fc.Format)
+	}
+	if fc.JPEGQuality != 0 {
+		cfg.JPEGQuality = fc.JPEGQuality
+	}
+}
+
+// options builds the render options for an order, pluralizing labels by its item count
+func (cfg *config) options(order ordersummary.OrderSummary) (ordersummary.Options, error) {
+	text, err := cfg.catalog.TextContent(cfg.Locale, len(order.Items), cfg.Text)
+	if err != nil {
+		return ordersummary.Options{}, err
+	}
+	return ordersummary.Options{
+		Layout:      cfg.Layout,
+		Text:        text,
+		Footer:      cfg.Footer,
+		Theme:       cfg.Theme,
+		Format:      cfg.Format,
+		JPEGQuality: cfg.JPEGQuality,
+		Language:    cfg.Locale,
+		AltText:     cfg.AltText,
+		HitMap:      cfg.HitMap,
+		Logo:        cfg.Logo,
+	}, nil
+}
+
+// orderStream decodes orders one at a time from the input, which may hold a single
+// JSON document or a stream of them such as NDJSON
+type orderStream struct {
+	dec    *json.Decoder
+	closer io.Closer
+	sample bool
+	n      int
+}
+
+func openOrders(cfg *config, stdin io.Reader) (*orderStream, error) {
+	if cfg.Sample {
+		return &orderStream{sample: true}, nil
+	}
+	if cfg.Input == "-" {
+		return &orderStream{dec: json.NewDecoder(stdin)}, nil
+	}
+	f, err := os.Open(cfg.Input)
+	if err != nil {
+		return nil, err
+	}
+	return &orderStream{dec: json.NewDecoder(f), closer: f}, nil
+}
+
+// Next returns the next order, or io.EOF once the input is exhausted
+func (s *orderStream) Next() (ordersummary.OrderSummary, error) {
+	var order ordersummary.OrderSummary
+	if s.sample {
+		if s.n > 0 {
+			return order, io.EOF
+		}
+		s.n++
+		return sampleOrder(), nil
+	}
+
+	err := s.dec.Decode(&order)
+	if errors.Is(err, io.EOF) {
+		if s.n == 0 {
+			return order, errors.New("no orders in input")
+		}
+		return order, io.EOF
+	}
+	if err != nil {
+		return order, fmt.Errorf("order %d: %w", s.n+1, err)
+	}
+	s.n++
+	return order, nil
+}
+
+func (s *orderStream) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+func hasPlaceholder(path string) bool {
+	return strings.Contains(path, "{index}") || strings.Contains(path, "{id}")
+}
+
+// outputPath expands the {index} and {id} placeholders for the order at index i.
+// Orders without an ID use their index for {id}.
+func outputPath(pattern string, order ordersummary.OrderSummary, i int) string {
+	id := order.OrderID
+	if id == "" {
+		id = strconv.Itoa(i + 1)
+	}
+	return strings.NewReplacer(
+		"{index}", strconv.Itoa(i+1),
+		"{id}", sanitizeFilename(id),
+	).Replace(pattern)
+}
+
+// sanitizeFilename makes an order ID safe to use as one path element, so an ID
+// cannot name a parent directory or a path outside the -o pattern
+func sanitizeFilename(s string) string {
+	if s == "" || s == "." || s == ".." {
+		return strings.Repeat("_", max(len(s), 1))
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == filepath.Separator || r < ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func formatFromPath(path string) ordersummary.Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return ordersummary.FormatJPEG
+	case ".txt":
+		return ordersummary.FormatText
+	case ".html", ".htm":
+		return ordersummary.FormatHTML
+	}
+	return ordersummary.FormatPNG
+}
+
+// stringList collects repeated string flags
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
@@ -0,0 +1,186 @@
+// Package golden compares rendered images against checked-in golden images with
+// a perceptual tolerance, so small anti-aliasing changes do not count as failures.
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Default tolerances
+const (
+	// DefaultThreshold is the perceptual color distance, from 0 to 1, below which
+	// two pixels count as equal
+	DefaultThreshold = 0.1
+	// DefaultMaxDiffRatio is the fraction of pixels allowed to differ. The threshold
+	// already absorbs anti-aliasing noise, so by default any visible change fails.
+	DefaultMaxDiffRatio = 0
+)
+
+// ErrMismatch is returned by Check when an image differs from its golden
+var ErrMismatch = errors.New("image differs from golden")
+
+// Options sets the comparison tolerances
+type Options struct {
+	// Threshold is DefaultThreshold when zero
+	Threshold    float64
+	MaxDiffRatio float64
+}
+
+func (o Options) threshold() float64 {
+	if o.Threshold == 0 {
+		return DefaultThreshold
+	}
+	return o.Threshold
+}
+
+// Result describes the difference between two images
+type Result struct {
+	// DiffPixels is the number of pixels that differ beyond the threshold
+	DiffPixels int
+	// Total is the number of pixels compared
+	Total int
+	// SizeMismatch is set when the images have different dimensions
+	SizeMismatch bool
+	// Diff highlights differing pixels in red over a faded copy of the golden
+	Diff *image.RGBA
+}
+
+// Ratio returns the fraction of pixels that differ
+func (r Result) Ratio() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.DiffPixels) / float64(r.Total)
+}
+
+// Compare compares got against want pixel by pixel
+func Compare(got, want image.Image, opts Options) Result {
+	gb, wb := got.Bounds(), want.Bounds()
+	if gb.Dx() != wb.Dx() || gb.Dy() != wb.Dy() {
+		return Result{SizeMismatch: true, Total: wb.Dx() * wb.Dy(), DiffPixels: wb.Dx() * wb.Dy()}
+	}
+
+	// Deltas are compared squared, scaled to the largest possible distance
+	limit := maxDelta * opts.threshold() * opts.threshold()
+	res := Result{Total: wb.Dx() * wb.Dy(), Diff: image.NewRGBA(image.Rect(0, 0, wb.Dx(), wb.Dy()))}
+	for y := 0; y < wb.Dy(); y++ {
+		for x := 0; x < wb.Dx(); x++ {
+			g := got.At(gb.Min.X+x, gb.Min.Y+y)
+			w := want.At(wb.Min.X+x, wb.Min.Y+y)
+			if colorDelta(g, w) > limit {
+				res.DiffPixels++
+				res.Diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+				continue
+			}
+			// Fade unchanged pixels so the differences stand out
+			l := uint8(255 - (255-luma(w))/10)
+			res.Diff.SetRGBA(x, y, color.RGBA{l, l, l, 255})
+		}
+	}
+	return res
+}
+
+// maxDelta is the largest value colorDelta can return, between black and white
+const maxDelta = 35215
+
+// colorDelta returns the squared distance between two colors in YIQ space,
+// weighted towards brightness as the eye is. Colors are blended over white first.
+func colorDelta(a, b color.Color) float64 {
+	r1, g1, b1 := overWhite(a)
+	r2, g2, b2 := overWhite(b)
+	y := rgbToY(r1, g1, b1) - rgbToY(r2, g2, b2)
+	i := rgbToI(r1, g1, b1) - rgbToI(r2, g2, b2)
+	q := rgbToQ(r1, g1, b1) - rgbToQ(r2, g2, b2)
+	return 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+}
+
+func overWhite(c color.Color) (r, g, b float64) {
+	cr, cg, cb, ca := c.RGBA()
+	white := float64(0xffff - ca)
+	return (float64(cr) + white) / 257, (float64(cg) + white) / 257, (float64(cb) + white) / 257
+}
+
+func rgbToY(r, g, b float64) float64 { return r*0.29889531 + g*0.58662247 + b*0.11448223 }
+func rgbToI(r, g, b float64) float64 { return r*0.59597799 - g*0.27417610 - b*0.32180189 }
+func rgbToQ(r, g, b float64) float64 { return r*0.21147017 - g*0.52261711 + b*0.31114694 }
+
+func luma(c color.Color) uint8 {
+	r, g, b := overWhite(c)
+	return uint8(rgbToY(r, g, b))
+}
+
+// Check compares got with the golden PNG at path. With update set, the golden is
+// written instead. On a mismatch the diff image is written to diffPath, when set,
+// and the returned error wraps ErrMismatch.
+func Check(path string, got image.Image, update bool, diffPath string, opts Options) (Result, error) {
+	if update {
+		return Result{}, WritePNG(path, got)
+	}
+
+	want, err := ReadPNG(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Result{}, fmt.Errorf("%s: no golden image, run with -update to create it", path)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Compare(got, want, opts)
+	switch {
+	case res.SizeMismatch:
+		err = fmt.Errorf("%w: %s: size %v, golden is %v", ErrMismatch, path, got.Bounds().Size(), want.Bounds().Size())
+	case res.Ratio() > opts.MaxDiffRatio:
+		err = fmt.Errorf("%w: %s: %d of %d pixels (%.3f%%) differ", ErrMismatch, path, res.DiffPixels, res.Total, 100*res.Ratio())
+	default:
+		return res, nil
+	}
+
+	if diffPath != "" {
+		// Without a diff image, keep the rendered image for inspection
+		var out image.Image = got
+		if res.Diff != nil {
+			out = res.Diff
+		}
+		if werr := WritePNG(diffPath, out); werr != nil {
+			return res, errors.Join(err, werr)
+		}
+	}
+	return res, err
+}
+
+// ReadPNG decodes the PNG file at path
+func ReadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// WritePNG encodes img to path, creating its directory if needed
+func WritePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("encode %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,60 @@
+package golden
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+)
+
+func filled(c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestCompare(t *testing.T) {
+	white := filled(color.White)
+	nearWhite := filled(color.RGBA{250, 250, 250, 255})
+	changed := filled(color.White)
+	changed.Set(3, 4, color.Black)
+
+	if res := Compare(nearWhite, white, Options{}); res.DiffPixels != 0 {
+		t.Errorf("anti-aliasing noise counted as %d differing pixels", res.DiffPixels)
+	}
+	res := Compare(changed, white, Options{})
+	if res.DiffPixels != 1 || res.Total != 100 {
+		t.Errorf("got %d of %d pixels differing, want 1 of 100", res.DiffPixels, res.Total)
+	}
+	if got := res.Diff.RGBAAt(3, 4); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("differing pixel is %v in the diff, want red", got)
+	}
+	if res := Compare(image.NewRGBA(image.Rect(0, 0, 5, 5)), white, Options{}); !res.SizeMismatch {
+		t.Error("images of different sizes compared equal")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "want.png")
+	diffPath := filepath.Join(dir, "want.diff.png")
+	white := filled(color.White)
+
+	if _, err := Check(path, white, false, diffPath, Options{}); err == nil {
+		t.Fatal("missing golden passed")
+	}
+	if _, err := Check(path, white, true, "", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Check(path, white, false, diffPath, Options{}); err != nil {
+		t.Errorf("identical image failed: %v", err)
+	}
+	if _, err := Check(path, filled(color.Black), false, diffPath, Options{}); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("got %v, want ErrMismatch", err)
+	}
+	if _, err := ReadPNG(diffPath); err != nil {
+		t.Errorf("diff image was not written: %v", err)
+	}
+}
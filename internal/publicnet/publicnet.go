@@ -0,0 +1,47 @@
+// Package publicnet keeps outgoing requests to user-supplied URLs on the public
+// internet, away from loopback, private and link-local services.
+package publicnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrNotPublic is returned for connections to an address that is not public
+var ErrNotPublic = errors.New("publicnet: address is not public")
+
+// sharedAddrSpace is the carrier-grade NAT range, which is not routable publicly
+var sharedAddrSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Addr reports whether addr is reachable on the public internet rather than on
+// the machine or network the process runs in
+func Addr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsUnspecified() && !addr.IsLoopback() && !addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() && !sharedAddrSpace.Contains(addr)
+}
+
+// Control is a net.Dialer Control function that refuses connections to addresses
+// that are not public. It runs after the host name is resolved, so a name that
+// was public when checked cannot be rebound to an internal address before the
+// connection is made.
+func Control(network, address string, c syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrNotPublic, address)
+	}
+	if !Addr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrNotPublic, addrPort.Addr())
+	}
+	return nil
+}
+
+// Dialer returns a dialer that connects only to public addresses
+func Dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{Timeout: timeout, Control: Control}
+}
@@ -0,0 +1,79 @@
+package publicnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.215.14":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"::ffff:127.0.0.1":     false,
+		"10.0.0.5":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"fd00::1":              false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"100.64.0.1":           false,
+		"100.127.255.254":      false,
+		"100.128.0.1":          true,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"224.0.0.1":            false,
+		"ff02::1":              false,
+		"::ffff:93.184.215.14": true,
+	} {
+		if got := Addr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Addr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+	if Addr(netip.Addr{}) {
+		t.Error("the zero Addr is public")
+	}
+}
+
+func TestControl(t *testing.T) {
+	for address, want := range map[string]error{
+		"93.184.215.14:443":  nil,
+		"[2606:4700::1]:443": nil,
+		"127.0.0.1:80":       ErrNotPublic,
+		"[::1]:80":           ErrNotPublic,
+		"10.0.0.5:8080":      ErrNotPublic,
+		"localhost:80":       ErrNotPublic,
+	} {
+		if err := Control("tcp", address, nil); !errors.Is(err, want) {
+			t.Errorf("Control(%s) = %v, want %v", address, err, want)
+		}
+	}
+}
+
+// TestDialer resolves a name to loopback and checks the connection is refused
+// after resolution
+func TestDialer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dialer(time.Second).DialContext(ctx, "tcp", net.JoinHostPort("localhost", port))
+	if err == nil {
+		conn.Close()
+	}
+	if !errors.Is(err, ErrNotPublic) {
+		t.Errorf("dialing localhost: got %v, want ErrNotPublic", err)
+	}
+}
@@ -0,0 +1,257 @@
+// Package sigv4 signs and verifies requests with AWS Signature Version 4, as used
+// by S3 and S3-compatible stores such as MinIO.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+
+	// UnsignedPayload is the payload hash of presigned requests
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// MaxPresignExpiry is the longest a presigned URL may be valid
+	MaxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// Credentials hold an access key pair and an optional session token
+type Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// Signer signs requests for one region and service
+type Signer struct {
+	Credentials
+	Region  string
+	Service string
+}
+
+// HashPayload returns the hex SHA-256 of a request body
+func HashPayload(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign adds the date, payload hash and Authorization headers to req, signing
+// the host and every x-amz-* and content header set on it
+func (s Signer) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(timeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || strings.HasPrefix(lower, "content-") || lower == "cache-control" {
+			headers = append(headers, lower)
+		}
+	}
+	sort.Strings(headers)
+
+	scope := s.scope(now)
+	sig := s.signature(now, scope, canonicalRequest(req, req.URL.Query(), headers, payloadHash))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, s.AccessKey, scope, strings.Join(headers, ";"), sig))
+}
+
+// Presign returns u with query parameters that authorize method on it until
+// expires has passed. Only the host header is signed.
+func (s Signer) Presign(method string, u *url.URL, expires time.Duration, now time.Time) (string, error) {
+	if expires <= 0 || expires > MaxPresignExpiry {
+		return "", fmt.Errorf("presign expiry %v must be between 1s and %v", expires, MaxPresignExpiry)
+	}
+	now = now.UTC()
+	scope := s.scope(now)
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", algorithm)
+	q.Set("X-Amz-Credential", s.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", now.Format(timeFormat))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if s.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	req := &http.Request{Method: method, URL: u, Host: u.Host, Header: http.Header{}}
+	sig := s.signature(now, scope, canonicalRequest(req, q, []string{"host"}, UnsignedPayload))
+
+	signed := *u
+	signed.RawQuery = canonicalQuery(q) + "&X-Amz-Signature=" + sig
+	return signed.String(), nil
+}
+
+func (s Signer) scope(now time.Time) string {
+	return now.Format(dateFormat) + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+func (s Signer) signature(now time.Time, scope, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := algorithm + "\n" + now.Format(timeFormat) + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	return hex.EncodeToString(hmacSHA256(signingKey(s.SecretKey, now.Format(dateFormat), s.Region, s.Service), stringToSign))
+}
+
+// signingKey derives the key for one day, region and service from a secret key
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalRequest builds the string whose hash is signed, from the request
+// method, path, query q and the named headers
+func canonicalRequest(req *http.Request, q url.Values, headers []string, payloadHash string) string {
+	var sb strings.Builder
+	sb.WriteString(req.Method + "\n")
+	sb.WriteString(escapePath(req.URL.Path) + "\n")
+	sb.WriteString(canonicalQuery(q) + "\n")
+	for _, name := range headers {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		sb.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	sb.WriteString("\n" + strings.Join(headers, ";") + "\n")
+	sb.WriteString(payloadHash)
+	return sb.String()
+}
+
+// canonicalQuery sorts and escapes q, leaving out any signature
+func canonicalQuery(q url.Values) string {
+	var pairs []string
+	for k, vs := range q {
+		if k == "X-Amz-Signature" {
+			continue
+		}
+		for _, v := range vs {
+			pairs = append(pairs, escape(k, true)+"="+escape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func escapePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return escape(p, false)
+}
+
+// escape percent-encodes everything but unreserved characters, and slashes
+// unless encodeSlash is set, as SigV4 requires
+func escape(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// Verification errors
+var (
+	ErrMissingAuth = errors.New("sigv4: request is not signed")
+	ErrSignature   = errors.New("sigv4: signature does not match")
+	ErrExpired     = errors.New("sigv4: request has expired")
+)
+
+// Verify checks a request signed with Sign or a URL from Presign. Secret returns
+// the secret key for an access key. Header-signed bodies are checked against
+// payloadHash, the hash of the body actually received.
+func Verify(req *http.Request, payloadHash string, secret func(accessKey string) (string, bool), now time.Time) error {
+	q := req.URL.Query()
+	var credential, signedHeaders, sig, date, hash string
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		fields, ok := strings.CutPrefix(auth, algorithm+" ")
+		if !ok {
+			return ErrMissingAuth
+		}
+		for _, f := range strings.Split(fields, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(f), "=")
+			switch k {
+			case "Credential":
+				credential = v
+			case "SignedHeaders":
+				signedHeaders = v
+			case "Signature":
+				sig = v
+			}
+		}
+		date = req.Header.Get("X-Amz-Date")
+		hash = req.Header.Get("X-Amz-Content-Sha256")
+		if hash != payloadHash {
+			return fmt.Errorf("%w: payload hash", ErrSignature)
+		}
+	} else if q.Get("X-Amz-Algorithm") == algorithm {
+		credential = q.Get("X-Amz-Credential")
+		signedHeaders = q.Get("X-Amz-SignedHeaders")
+		sig = q.Get("X-Amz-Signature")
+		date = q.Get("X-Amz-Date")
+		hash = UnsignedPayload
+		t, err := time.Parse(timeFormat, date)
+		secs, serr := strconv.Atoi(q.Get("X-Amz-Expires"))
+		if err != nil || serr != nil {
+			return ErrMissingAuth
+		}
+		if now.After(t.Add(time.Duration(secs) * time.Second)) {
+			return ErrExpired
+		}
+	} else {
+		return ErrMissingAuth
+	}
+
+	parts := strings.SplitN(credential, "/", 5)
+	if len(parts) != 5 || sig == "" || signedHeaders == "" {
+		return ErrMissingAuth
+	}
+	t, err := time.Parse(timeFormat, date)
+	if err != nil || t.Format(dateFormat) != parts[1] {
+		return ErrMissingAuth
+	}
+	key, ok := secret(parts[0])
+	if !ok {
+		return fmt.Errorf("%w: unknown access key", ErrSignature)
+	}
+
+	s := Signer{Credentials: Credentials{AccessKey: parts[0], SecretKey: key}, Region: parts[2], Service: parts[3]}
+	want := s.signature(t, s.scope(t), canonicalRequest(req, q, strings.Split(signedHeaders, ";"), hash))
+	if !hmac.Equal([]byte(want), []byte(sig)) {
+		return ErrSignature
+	}
+	return nil
+}
@@ -2,30 +2,44 @@ package main
 
 import "github.com/biswaz/img-maker/ordersummary"
 
+// sampleOrder returns the demo order rendered by -sample
+func sampleOrder() ordersummary.OrderSummary {
+	order := ordersummary.OrderSummary{
+		Subtotal: 2357.97,
+		Discount: 100.00,
+		Shipping: 50.00,
+		Taxes:    235.80,
+		Total:    2643.77,
+		Currency: "INR",
+	}
+	order.Items = append(order.Items, Items[:5]...)
+	return order
+}
+
 var Items = []ordersummary.Item{
-{Name: "Phalaenopsis Amabilis 'Moth Orchid' - Large White Blooms, Ceramic Pot, 2-3 Flower Spikes", Quantity: 2, Price: 349.99},
-{Name: "Dendrobium Nobile 'Noble Dendrobium' - Pink and White Flowers, Hanging Basket, Mature Plant", Quantity: 1, Price: 279.50},
-{Name: "Cattleya Labiata 'Corsage Orchid' - Fragrant Purple Blooms, Terracotta Pot, Blooming Size", Quantity: 1, Price: 399.99},
-{Name: "Vanda Coerulea 'Blue Orchid' - Rare Blue Flowers, Mounted on Driftwood, Young Plant", Quantity: 1, Price: 599.00},
-{Name: "Oncidium Varicosum 'Dancing Lady Orchid' - Yellow Flowers, Plastic Pot, 2 Pseudobulbs Oncidium Varicosum 'Dancing Lady Orchid' - Yellow Flowers, Plastic Pot, 2 Pseudobulbs Oncidium Varicosum 'Dancing Lady Orchid' - Yellow Flowers, Plastic Pot, 2 Pseudobulbs Oncidium Varicosum 'Dancing Lady Orchid' - Yellow Flowers, Plastic Pot, 2 Pseudobulbs", Quantity: 3, Price: 189.75},
-{Name: "Paphiopedilum Maudiae 'Slipper Orchid' - Green and White Flowers, Clay Pot, Blooming Size", Quantity: 2, Price: 299.50},
-{Name: "Cymbidium Hybrid 'Boat Orchid' - Large Pink Sprays, Wooden Basket, 3-4 Flower Spikes", Quantity: 1, Price: 449.99},
-{Name: "Miltonia Moreliana 'Pansy Orchid' - Purple Flowers, Clear Plastic Pot, Mature Plant", Quantity: 2, Price: 224.50},
-{Name: "Brassia Verrucosa 'Spider Orchid' - Star-shaped Flowers, Hanging Basket, Young Plant", Quantity: 1, Price: 179.99},
-{Name: "Zygopetalum Mackayi 'Fragrant Orchid' - Purple and Green Blooms, Ceramic Pot, Blooming Size", Quantity: 2, Price: 289.75},
-{Name: "Epidendrum Radicans 'Reed-Stem Orchid' - Orange Clusters, Terracotta Pot, Mature Plant", Quantity: 3, Price: 149.99},
-{Name: "Lycaste Skinneri 'Monk Orchid' - Large Pink Flowers, Plastic Pot, 2-3 Pseudobulbs", Quantity: 1, Price: 329.50},
-{Name: "Masdevallia Coccinea 'Flag Orchid' - Bright Red Flowers, Miniature Plant, Mounted", Quantity: 2, Price: 199.99},
-{Name: "Odontoglossum Crispum 'Crispum Orchid' - White Ruffled Flowers, Clear Pot, Young Plant", Quantity: 1, Price: 274.75},
-{Name: "Phragmipedium Besseae 'Tropical Slipper Orchid' - Red Flowers, Hydroponic Setup, Mature", Quantity: 1, Price: 499.99},
-{Name: "Renanthera Imschootiana 'Fire Orchid' - Bright Red Sprays, Mounted on Cork, Blooming Size", Quantity: 2, Price: 399.50},
-{Name: "Stanhopea Tigrina 'Bucket Orchid' - Fragrant Tiger-striped Flowers, Slatted Basket, Mature", Quantity: 1, Price: 349.99},
-{Name: "Brassavola Nodosa 'Lady of the Night' - White Fragrant Flowers, Clay Pot, Blooming Size", Quantity: 2, Price: 229.75},
-{Name: "Coelogyne Cristata 'Necklace Orchid' - White Fringed Flowers, Wooden Basket, Large Plant", Quantity: 1, Price: 379.50},
-{Name: "Encyclia Cochleata 'Cockleshell Orchid' - Green and Purple Flowers, Plastic Pot, Mature", Quantity: 2, Price: 199.99},
-{Name: "Gongora Galeata 'Cradle Orchid' - Pink Speckled Flowers, Hanging Basket, Young Plant", Quantity: 1, Price: 249.50},
-{Name: "Maxillaria Tenuifolia 'Coconut Orchid' - Red Star-shaped Flowers, Terracotta Pot, Fragrant", Quantity: 3, Price: 169.75},
-{Name: "Peristeria Elata 'Dove Orchid' - White Dove-like Flowers, Ceramic Pot, Blooming Size", Quantity: 1, Price: 449.99},
-{Name: "Psychopsis Papilio 'Butterfly Orchid' - Brown and Yellow Flowers, Clear Pot, Mature Plant", Quantity: 2, Price: 299.50},
-{Name: "Sobralia Macrantha 'Cattleya of the Poor' - Large Purple Flowers, Large Pot, Specimen Size", Quantity: 1, Price: 599.99},
-}
\ No newline at end of file
+	{Name: "Phalaenopsis Amabilis 'Moth Orchid' - Large White Blooms, Ceramic Pot, 2-3 Flower Spikes", Quantity: 2, Price: 349.99},
+	{Name: "Dendrobium Nobile 'Noble Dendrobium' - Pink and White Flowers, Hanging Basket, Mature Plant", Quantity: 1, Price: 279.50},
+	{Name: "Cattleya Labiata 'Corsage Orchid' - Fragrant Purple Blooms, Terracotta Pot, Blooming Size", Quantity: 1, Price: 399.99},
+	{Name: "Vanda Coerulea 'Blue Orchid' - Rare Blue Flowers, Mounted on Driftwood, Young Plant", Quantity: 1, Price: 599.00},
+	{Name: "Oncidium Varicosum 'Dancing Lady Orchid' - Yellow Flowers, Plastic Pot, 2 Pseudobulbs Oncidium Varicosum 'Dancing Lady Orchid' - Yellow Flowers, Plastic Pot, 2 Pseudobulbs Oncidium Varicosum 'Dancing Lady Orchid' - Yellow Flowers, Plastic Pot, 2 Pseudobulbs Oncidium Varicosum 'Dancing Lady Orchid' - Yellow Flowers, Plastic Pot, 2 Pseudobulbs", Quantity: 3, Price: 189.75},
+	{Name: "Paphiopedilum Maudiae 'Slipper Orchid' - Green and White Flowers, Clay Pot, Blooming Size", Quantity: 2, Price: 299.50},
+	{Name: "Cymbidium Hybrid 'Boat Orchid' - Large Pink Sprays, Wooden Basket, 3-4 Flower Spikes", Quantity: 1, Price: 449.99},
+	{Name: "Miltonia Moreliana 'Pansy Orchid' - Purple Flowers, Clear Plastic Pot, Mature Plant", Quantity: 2, Price: 224.50},
+	{Name: "Brassia Verrucosa 'Spider Orchid' - Star-shaped Flowers, Hanging Basket, Young Plant", Quantity: 1, Price: 179.99},
+	{Name: "Zygopetalum Mackayi 'Fragrant Orchid' - Purple and Green Blooms, Ceramic Pot, Blooming Size", Quantity: 2, Price: 289.75},
+	{Name: "Epidendrum Radicans 'Reed-Stem Orchid' - Orange Clusters, Terracotta Pot, Mature Plant", Quantity: 3, Price: 149.99},
+	{Name: "Lycaste Skinneri 'Monk Orchid' - Large Pink Flowers, Plastic Pot, 2-3 Pseudobulbs", Quantity: 1, Price: 329.50},
+	{Name: "Masdevallia Coccinea 'Flag Orchid' - Bright Red Flowers, Miniature Plant, Mounted", Quantity: 2, Price: 199.99},
+	{Name: "Odontoglossum Crispum 'Crispum Orchid' - White Ruffled Flowers, Clear Pot, Young Plant", Quantity: 1, Price: 274.75},
+	{Name: "Phragmipedium Besseae 'Tropical Slipper Orchid' - Red Flowers, Hydroponic Setup, Mature", Quantity: 1, Price: 499.99},
+	{Name: "Renanthera Imschootiana 'Fire Orchid' - Bright Red Sprays, Mounted on Cork, Blooming Size", Quantity: 2, Price: 399.50},
+	{Name: "Stanhopea Tigrina 'Bucket Orchid' - Fragrant Tiger-striped Flowers, Slatted Basket, Mature", Quantity: 1, Price: 349.99},
+	{Name: "Brassavola Nodosa 'Lady of the Night' - White Fragrant Flowers, Clay Pot, Blooming Size", Quantity: 2, Price: 229.75},
+	{Name: "Coelogyne Cristata 'Necklace Orchid' - White Fringed Flowers, Wooden Basket, Large Plant", Quantity: 1, Price: 379.50},
+	{Name: "Encyclia Cochleata 'Cockleshell Orchid' - Green and Purple Flowers, Plastic Pot, Mature", Quantity: 2, Price: 199.99},
+	{Name: "Gongora Galeata 'Cradle Orchid' - Pink Speckled Flowers, Hanging Basket, Young Plant", Quantity: 1, Price: 249.50},
+	{Name: "Maxillaria Tenuifolia 'Coconut Orchid' - Red Star-shaped Flowers, Terracotta Pot, Fragrant", Quantity: 3, Price: 169.75},
+	{Name: "Peristeria Elata 'Dove Orchid' - White Dove-like Flowers, Ceramic Pot, Blooming Size", Quantity: 1, Price: 449.99},
+	{Name: "Psychopsis Papilio 'Butterfly Orchid' - Brown and Yellow Flowers, Clear Pot, Mature Plant", Quantity: 2, Price: 299.50},
+	{Name: "Sobralia Macrantha 'Cattleya of the Poor' - Large Purple Flowers, Large Pot, Specimen Size", Quantity: 1, Price: 599.99},
+}
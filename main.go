@@ -1,63 +1,201 @@
+// Command img-maker renders order summary images from JSON orders.
+//
+// Orders are read from a file or stdin, either as a single JSON document or as
+// newline-delimited JSON with one order per line. Multiple orders are rendered
+// concurrently by a pool of -workers:
+//
+//	img-maker -in orders.ndjson -o 'receipts/{id}.png' -locale hi -theme dark -manifest manifest.json
+//	cat order.json | img-maker -format jpeg -o - > order.jpg
+//	img-maker -sample -o summary.txt   # plain text for screen readers and feature phones
+//	img-maker -in order.json -o receipt.html   # HTML email with inline styles
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"time"
 
+	"github.com/biswaz/img-maker/batch"
 	"github.com/biswaz/img-maker/ordersummary"
 )
 
+// Exit codes
+const (
+	exitOK      = 0
+	exitFailure = 1 // rendering or I/O failed
+	exitUsage   = 2 // invalid flags or configuration
+	exitInvalid = 3 // an input order could not be decoded or failed validation
+)
+
+// errNeedPlaceholder is returned when several orders would be written to the same path
+var errNeedPlaceholder = errors.New("input has several orders: -o must contain {index} or {id}")
+
+// errDuplicatePath is returned when two orders expand -o to the same path, as
+// orders sharing an ID do with {id}
+var errDuplicatePath = errors.New("several orders would be written to the same path")
+
 func main() {
-	log.Println("Starting the generator")
-
-	order := ordersummary.OrderSummary{
-		Subtotal: 2357.97,
-		Discount: 100.00,
-		Shipping: 50.00,
-		Taxes:    235.80,
-		Total:    2643.77,
-		Currency: "INR",
-	}
-
-	order.Items = append(order.Items, Items[:5]...)
-
-	layout := ordersummary.Layout{
-		Width:          700,
-		Margin:         25,
-		HeaderHeight:   80,
-		ItemSpacing:    8,
-		SectionSpacing: 20,
-		FontSizes: ordersummary.FontSizes{
-			Header:    24,
-			Subheader: 18,
-			Item:      12,
-			Total:     14,
-		},
-	}
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) int {
+	slog.SetDefault(newLogger(os.Stderr, false))
 
-	start := time.Now()
+	cfg, err := parseFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		slog.Error("invalid arguments", "err", err)
+		return exitUsage
+	}
+	if cfg.LogJSON {
+		slog.SetDefault(newLogger(os.Stderr, true))
+	}
 
-	outputFile, err := os.OpenFile("order_summary.png", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	orders, err := openOrders(cfg, stdin)
 	if err != nil {
-		log.Fatalf("Error creating/opening output file: %v", err)
+		slog.Error("failed to open input", "err", err)
+		return exitInvalid
 	}
-	defer outputFile.Close()
+	defer orders.Close()
 
-	textContent := ordersummary.TextContent{
-		HeaderText:   "Order Summary",
-		ItemsText:    "Items",
-		SubtotalText: "Subtotal:",
-		ShippingText: "Shipping:",
-		TaxesText:    "Taxes:",
-		TotalText:    "Total:",
-		DiscountText: "Discount:",
+	if cfg.Output == "-" {
+		return renderToStdout(cfg, orders, stdout)
 	}
+	return renderBatch(cfg, orders)
+}
 
-	err = ordersummary.GenerateOrderSummary(order, outputFile, layout, textContent, "Powered by Zoko")
+// renderToStdout renders the only order in the input to stdout
+func renderToStdout(cfg *config, orders *orderStream, stdout io.Writer) int {
+	order, err := orders.Next()
 	if err != nil {
-		log.Fatalf("Failed to generate order summary: %v", err)
+		slog.Error("failed to read order", "err", err)
+		return exitInvalid
+	}
+	if _, err := orders.Next(); !errors.Is(err, io.EOF) {
+		slog.Error("input has several orders: cannot write them all to stdout")
+		return exitUsage
+	}
+	if err := order.Validate(); err != nil {
+		slog.Error("invalid order", "order", 1, "id", order.OrderID, "err", err)
+		return exitInvalid
 	}
 
-	log.Printf("Time taken: %v", time.Since(start))
+	opts, err := cfg.options(order)
+	if err != nil {
+		slog.Error("invalid options", "err", err)
+		return exitUsage
+	}
+	if _, err := ordersummary.Render(order, stdout, opts); err != nil {
+		slog.Error("render failed", "order", 1, "id", order.OrderID, "kind", ordersummary.ErrorKind(err), "err", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// renderBatch streams the input orders through a worker pool, writing each to its output path
+func renderBatch(cfg *config, orders *orderStream) int {
+	jobs := make(chan batch.Job)
+	var inputErr error
+	go func() {
+		defer close(jobs)
+		paths := make(map[string]int)
+		for i := 0; ; i++ {
+			order, err := orders.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				inputErr = err
+				return
+			}
+			if i == 1 && !hasPlaceholder(cfg.Output) {
+				inputErr = errNeedPlaceholder
+				return
+			}
+			opts, err := cfg.options(order)
+			if err != nil {
+				inputErr = err
+				return
+			}
+			path := outputPath(cfg.Output, order, i)
+			if prev, ok := paths[path]; ok {
+				inputErr = fmt.Errorf("%w: orders %d and %d: %s", errDuplicatePath, prev+1, i+1, path)
+				return
+			}
+			paths[path] = i
+			jobs <- batch.Job{
+				Index:   i,
+				ID:      order.OrderID,
+				Order:   order,
+				Options: opts,
+				Path:    path,
+			}
+		}
+	}()
+
+	manifest := batch.Manifest{Started: time.Now(), Workers: cfg.Workers}
+	code := exitOK
+	for res := range batch.Run(context.Background(), batch.Config{Workers: cfg.Workers}, jobs) {
+		manifest.Add(res)
+		switch {
+		case errors.Is(res.Err, batch.ErrInvalidOrder):
+			slog.Error("invalid order", "order", res.Index+1, "id", res.ID, "err", res.Err)
+			code = exitInvalid
+		case res.Err != nil:
+			slog.Error("render failed", "order", res.Index+1, "id", res.ID, "kind", ordersummary.ErrorKind(res.Err), "err", res.Err)
+			if code == exitOK {
+				code = exitFailure
+			}
+		case cfg.Verbose:
+			slog.Info("rendered order", "order", res.Index+1, "id", res.ID, "path", res.Path, "duration", res.Duration)
+		}
+	}
+	manifest.Finished = time.Now()
+	manifest.Duration = manifest.Finished.Sub(manifest.Started)
+	if cfg.Verbose {
+		slog.Info("finished", "succeeded", manifest.Succeeded, "failed", manifest.Failed,
+			"workers", manifest.Workers, "duration", manifest.Duration)
+	}
+
+	if cfg.Manifest != "" {
+		if err := manifest.WriteFile(cfg.Manifest); err != nil {
+			slog.Error("failed to write manifest", "path", cfg.Manifest, "err", err)
+			if code == exitOK {
+				code = exitFailure
+			}
+		}
+	}
+
+	switch {
+	case errors.Is(inputErr, errNeedPlaceholder):
+		slog.Error("invalid arguments", "err", inputErr)
+		return exitUsage
+	case inputErr != nil:
+		slog.Error("invalid input", "err", inputErr)
+		return exitInvalid
+	}
+	return code
+}
+
+// newLogger returns a logger for the command's diagnostics. Text logs leave out
+// the time, which is noise on a terminal; JSON logs keep it for collectors.
+func newLogger(w io.Writer, json bool) *slog.Logger {
+	if json {
+		return slog.New(slog.NewJSONHandler(w, nil))
+	}
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	for in, want := range map[string]string{
+		"1042":          "1042",
+		"#1042":         "#1042",
+		"../etc/passwd": ".._etc_passwd",
+		`..\windows`:    ".._windows",
+		"a/b":           "a_b",
+		"tab\there":     "tab_here",
+		"":              "_",
+		".":             "_",
+		"..":            "__",
+		"...":           "...",
+	} {
+		if got := sanitizeFilename(in); got != want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, id string
+		index       int
+		want        string
+	}{
+		{"out/{id}.png", "#7", 0, "out/#7.png"},
+		{"out/{index}.png", "#7", 0, "out/1.png"},
+		{"out/{index}-{id}.png", "#7", 4, "out/5-#7.png"},
+		{"out/{id}.png", "", 2, "out/3.png"},
+		{"out/{id}/summary.png", "..", 0, "out/__/summary.png"},
+		{"out/{id}.png", "../../etc/cron.d/x", 0, "out/.._.._etc_cron.d_x.png"},
+		{"out/{id}-{id}.png", "a", 0, "out/a-a.png"},
+		{"summary.png", "a", 0, "summary.png"},
+	} {
+		order := ordersummary.OrderSummary{OrderID: tc.id}
+		if got := outputPath(tc.pattern, order, tc.index); got != tc.want {
+			t.Errorf("outputPath(%q, %q, %d) = %q, want %q", tc.pattern, tc.id, tc.index, got, tc.want)
+		}
+	}
+}
+
+// writeOrders writes orders to a temporary NDJSON file and returns its path
+func writeOrders(t *testing.T, orders ...ordersummary.OrderSummary) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "orders.ndjson")
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	for _, o := range orders {
+		if err := enc.Encode(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func withID(id string) ordersummary.OrderSummary {
+	order := sampleOrder()
+	order.OrderID = id
+	return order
+}
+
+// TestRunExitCodes checks the exit code reported for each kind of failure
+func TestRunExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	// blocker is a file where a failing render expects a directory
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := sampleOrder()
+	invalid.Items[0].Quantity = -1
+	badJSON := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(badJSON, []byte(`{"orderId": `), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"sample", []string{"-sample", "-o", filepath.Join(out, "sample.png")}, exitOK},
+		{"several orders", []string{"-in", writeOrders(t, withID("a"), withID("b")), "-o", filepath.Join(out, "{id}.png")}, exitOK},
+		{"help", []string{"-h"}, exitOK},
+		{"unknown flag", []string{"-nope"}, exitUsage},
+		{"unexpected argument", []string{"-sample", "extra"}, exitUsage},
+		{"unknown theme", []string{"-sample", "-theme", "nope", "-o", filepath.Join(out, "theme.png")}, exitUsage},
+		{"no placeholder", []string{"-in", writeOrders(t, withID("a"), withID("b")), "-o", filepath.Join(out, "same.png")}, exitUsage},
+		{"several orders to stdout", []string{"-in", writeOrders(t, withID("a"), withID("b")), "-o", "-"}, exitUsage},
+		{"missing input", []string{"-in", filepath.Join(dir, "missing.json"), "-o", filepath.Join(out, "{id}.png")}, exitInvalid},
+		{"malformed input", []string{"-in", badJSON, "-o", filepath.Join(out, "{id}.png")}, exitInvalid},
+		{"invalid order", []string{"-in", writeOrders(t, invalid), "-o", filepath.Join(out, "invalid.png")}, exitInvalid},
+		{"duplicate IDs", []string{"-in", writeOrders(t, withID("a"), withID("a")), "-o", filepath.Join(out, "dup-{id}.png")}, exitInvalid},
+		{"unwritable output", []string{"-sample", "-o", filepath.Join(blocker, "sample.png")}, exitFailure},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := run(tc.args, strings.NewReader(""), io.Discard); got != tc.want {
+				t.Errorf("run(%q) = %d, want %d", tc.args, got, tc.want)
+			}
+		})
+	}
+
+	for _, name := range []string{"sample.png", "a.png", "b.png"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("expected output: %v", err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package metrics exports rendering metrics in the Prometheus format.
+//
+// Install a Metrics with ordersummary.SetObserver to record every render in the
+// process, and serve its Handler to let Prometheus scrape them.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+// Metrics holds the rendering metrics and the registry they are exported from
+type Metrics struct {
+	// Registry also holds the Go runtime and process collectors; register
+	// application metrics here to serve them from the same Handler
+	Registry *prometheus.Registry
+
+	renderDuration *prometheus.HistogramVec
+	renderItems    *prometheus.HistogramVec
+	outputBytes    *prometheus.HistogramVec
+	renderErrors   *prometheus.CounterVec
+	cacheRequests  *prometheus.CounterVec
+}
+
+// New creates the metrics in a new registry
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ordersummary_render_duration_seconds",
+			Help:    "Time taken to render an order summary, including failed renders.",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"backend", "format"}),
+		renderItems: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ordersummary_render_items",
+			Help:    "Number of items in each rendered order.",
+			Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200},
+		}, []string{"backend"}),
+		outputBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ordersummary_render_output_bytes",
+			Help:    "Size of each rendered order summary.",
+			Buckets: prometheus.ExponentialBuckets(1<<10, 4, 8),
+		}, []string{"format"}),
+		renderErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ordersummary_render_errors_total",
+			Help: "Failed renders by backend and kind of error.",
+		}, []string{"backend", "kind"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ordersummary_cache_requests_total",
+			Help: "Render cache lookups by result, hit or miss.",
+		}, []string{"result"}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.renderDuration,
+		m.renderItems,
+		m.outputBytes,
+		m.renderErrors,
+		m.cacheRequests,
+	)
+	// Report the cache series at zero before the first lookup
+	m.cacheRequests.WithLabelValues("hit")
+	m.cacheRequests.WithLabelValues("miss")
+	return m
+}
+
+// ObserveRender records a finished render; it makes Metrics an ordersummary.Observer
+func (m *Metrics) ObserveRender(s ordersummary.RenderStats) {
+	format := string(s.Format)
+	if format == "" {
+		format = "unknown"
+	}
+	m.renderDuration.WithLabelValues(s.Backend, format).Observe(s.Duration.Seconds())
+	if s.Err != nil {
+		m.renderErrors.WithLabelValues(s.Backend, ordersummary.ErrorKind(s.Err)).Inc()
+		return
+	}
+	m.renderItems.WithLabelValues(s.Backend).Observe(float64(s.Items))
+	m.outputBytes.WithLabelValues(format).Observe(float64(s.Bytes))
+}
+
+// ObserveCache records a render cache lookup
+func (m *Metrics) ObserveCache(hit bool) {
+	if hit {
+		m.cacheRequests.WithLabelValues("hit").Inc()
+		return
+	}
+	m.cacheRequests.WithLabelValues("miss").Inc()
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+var order = ordersummary.OrderSummary{
+	OrderID: "#1042",
+	Items: []ordersummary.Item{
+		{Name: "Phalaenopsis Orchid", Quantity: 1, Price: 349.99},
+		{Name: "Ceramic Pot", Quantity: 2, Price: 120},
+	},
+	Subtotal: 589.99,
+	Total:    589.99,
+	Currency: "INR",
+}
+
+// scrape fetches the exposition text from the handler
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 {
+		t.Fatalf("scrape returned %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestObserveRender(t *testing.T) {
+	m := New()
+	ordersummary.SetObserver(m)
+	t.Cleanup(func() { ordersummary.SetObserver(nil) })
+
+	var buf bytes.Buffer
+	if _, err := ordersummary.Render(order, &buf, ordersummary.Options{Layout: ordersummary.DefaultLayout(), Format: ordersummary.FormatPNG}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ordersummary.Render(order, &buf, ordersummary.Options{Layout: ordersummary.DefaultLayout(), Format: "gif"}); err == nil {
+		t.Fatal("rendering a GIF succeeded")
+	}
+	m.ObserveCache(true)
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`ordersummary_render_duration_seconds_count{backend="raw",format="png"} 1`,
+		`ordersummary_render_duration_seconds_count{backend="raw",format="unknown"} 1`,
+		`ordersummary_render_items_count{backend="raw"} 1`,
+		`ordersummary_render_items_sum{backend="raw"} 2`,
+		`ordersummary_render_output_bytes_count{format="png"} 1`,
+		`ordersummary_render_errors_total{backend="raw",kind="unsupported_format"} 1`,
+		`ordersummary_cache_requests_total{result="hit"} 1`,
+		`ordersummary_cache_requests_total{result="miss"} 0`,
+		`go_goroutines `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape is missing %q", want)
+		}
+	}
+	if strings.Contains(body, `ordersummary_render_output_bytes_count{format="unknown"}`) {
+		t.Error("a failed render was counted in the output size")
+	}
+}
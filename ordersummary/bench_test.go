@@ -0,0 +1,105 @@
+package ordersummary
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"testing"
+)
+
+// Compare runs before and after a change with benchstat:
+//
+//	go test -run '^$' -bench Render -count 10 ./ordersummary > old.txt
+//	go test -run '^$' -bench Render -count 10 ./ordersummary > new.txt
+//	benchstat old.txt new.txt
+func BenchmarkRender(b *testing.B) {
+	small := benchOrder(3)
+	large := benchOrder(40)
+
+	wide := DefaultLayout()
+	wide.Width = 2400
+	wide.Margin = 60
+
+	shadow := DefaultLayout()
+	shadow.Shadow = Shadow{Offset: 4, Blur: 12}
+
+	dashed := DefaultLayout()
+	dashed.Divider = Divider{Style: DividerDashed, Thickness: 2}
+
+	retina := DefaultLayout()
+	retina.Scale = 2
+
+	fonts := NewFontCache()
+	for _, bm := range []struct {
+		name   string
+		order  OrderSummary
+		layout Layout
+		format Format
+	}{
+		{"small", small, DefaultLayout(), FormatJPEG},
+		{"large", large, DefaultLayout(), FormatJPEG},
+		{"wide", large, wide, FormatJPEG},
+		{"shadow", large, shadow, FormatJPEG},
+		{"dashed", large, dashed, FormatJPEG},
+		{"retina", large, retina, FormatJPEG},
+		{"png", large, DefaultLayout(), FormatPNG},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			text, err := TextContentForLocale(DefaultLocale, len(bm.order.Items))
+			if err != nil {
+				b.Fatal(err)
+			}
+			opts := Options{Layout: bm.layout, Text: text, Format: bm.format, Fonts: fonts}
+			// Load the fonts outside the timed loop
+			if _, err := Render(bm.order, io.Discard, opts); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := Render(bm.order, io.Discard, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFillRect measures the direct Pix fill behind the card and dividers
+// against draw.Draw, which it replaced
+func BenchmarkFillRect(b *testing.B) {
+	img := whiteImage(1400, 2000)
+	theme := DefaultTheme()
+	for name, c := range map[string]color.RGBA{"opaque": theme.Card, "translucent": theme.Shadow} {
+		b.Run(name, func(b *testing.B) {
+			for range b.N {
+				fillRect(img, img.Bounds(), c)
+			}
+		})
+		b.Run(name+"/draw", func(b *testing.B) {
+			src := image.NewUniform(c)
+			for range b.N {
+				draw.Draw(img, img.Bounds(), src, image.Point{}, draw.Over)
+			}
+		})
+	}
+}
+
+// benchOrder builds an order with n items whose names wrap onto one to three lines
+func benchOrder(n int) OrderSummary {
+	names := []string{
+		"Phalaenopsis Orchid",
+		"Dendrobium Nobile 'Noble Dendrobium' – Pink and White Flowers, Hanging Basket, Mature Plant",
+		"Oncidium Varicosum 'Dancing Lady Orchid' – Yellow Flowers, Plastic Pot, 2 Pseudobulbs, " +
+			"Oncidium Varicosum 'Dancing Lady Orchid' – Yellow Flowers, Plastic Pot",
+	}
+	o := OrderSummary{OrderID: "BENCH-1", Currency: "INR"}
+	for i := range n {
+		item := Item{Name: names[i%len(names)], Quantity: i%3 + 1, Price: 99.5 + float64(i)}
+		o.Items = append(o.Items, item)
+		o.Subtotal += item.Price * float64(item.Quantity)
+	}
+	o.Total = o.Subtotal
+	return o
+}
@@ -0,0 +1,339 @@
+package ordersummary
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/font"
+)
+
+// BoxKind identifies what a box in a laid out order summary holds
+type BoxKind string
+
+// Box kinds
+const (
+	// BoxSection groups other boxes and may fill its bounds
+	BoxSection BoxKind = "section"
+	// BoxText is a single line of text drawn on a baseline
+	BoxText BoxKind = "text"
+	// BoxLine is a horizontal divider
+	BoxLine BoxKind = "line"
+	// BoxImage draws an image scaled to its bounds
+	BoxImage BoxKind = "image"
+)
+
+// Paint names the theme color a box is drawn with
+type Paint string
+
+// Paints
+const (
+	PaintNone       Paint = ""
+	PaintBackground Paint = "background"
+	PaintCard       Paint = "card"
+	PaintText       Paint = "text"
+	PaintDivider    Paint = "divider"
+	PaintFooter     Paint = "footer"
+)
+
+// Color returns the theme color for p
+func (t Theme) Color(p Paint) color.RGBA {
+	switch p {
+	case PaintBackground:
+		return t.Background
+	case PaintCard:
+		return t.Card
+	case PaintText:
+		return t.Text
+	case PaintDivider:
+		return t.Divider
+	case PaintFooter:
+		return t.Footer
+	}
+	return color.RGBA{}
+}
+
+// Box is a positioned element of a laid out order summary. Coordinates are in
+// device pixels, with Layout.Scale already applied.
+type Box struct {
+	Kind BoxKind
+	// Role names the part of the summary the box shows, such as "header",
+	// "item-name" or "total"
+	Role string
+	// Item is the index of the order item the box belongs to, or -1
+	Item   int
+	Bounds image.Rectangle
+	// Paint fills a section or line, or colors text; sections without one are not drawn
+	Paint Paint
+	// Radius rounds the corners of a filled section
+	Radius int
+	// Shadow is drawn under a filled section
+	Shadow Shadow
+	// Divider styles a line
+	Divider Divider
+	// Text boxes draw Text on Baseline at Size points
+	Text     string
+	Baseline int
+	Size     float64
+	Bold     bool
+	// Image is drawn scaled to Bounds by image boxes
+	Image    image.Image
+	Children []*Box
+}
+
+// Walk calls fn for b and then for each of its descendants in drawing order
+func (b *Box) Walk(fn func(*Box)) {
+	fn(b)
+	for _, c := range b.Children {
+		c.Walk(fn)
+	}
+}
+
+// Document is an order summary laid out and ready to draw
+type Document struct {
+	// Width and Height are the size of the image in device pixels
+	Width  int
+	Height int
+	// Scale is the number of device pixels per layout pixel
+	Scale float64
+	Root  *Box
+	// TruncatedItems holds the indexes of items whose names were cut short with an ellipsis
+	TruncatedItems []int
+}
+
+// logoHeight is the tallest a logo is drawn, in layout pixels
+const logoHeight = 48
+
+// Measure lays out the order as Render would and returns the positioned boxes and
+// the final image size, without drawing anything
+func Measure(order OrderSummary, opts Options) (*Document, error) {
+	doc, _, _, err := measure(context.Background(), order, opts)
+	return doc, err
+}
+
+// measure validates the options, expands the text and lays out the order. It
+// returns the faces used so drawing can share their glyph caches.
+func measure(ctx context.Context, order OrderSummary, opts Options) (*Document, *faceSet, TextContent, error) {
+	if err := opts.Layout.Validate(); err != nil {
+		return nil, nil, TextContent{}, err
+	}
+	textContent, footer, err := ExpandTextContent(order, opts.Text, opts.Footer)
+	if err != nil {
+		return nil, nil, TextContent{}, err
+	}
+	_, span := startSpan(ctx, SpanLoadFonts)
+	faces, err := newFaceSet(opts.Fonts, opts.Layout.scale())
+	span.End(err)
+	if err != nil {
+		return nil, nil, TextContent{}, err
+	}
+
+	ctx, span = startSpan(ctx, SpanLayout, Attr{"items", len(order.Items)})
+	doc, err := layoutDocument(ctx, order, opts.Layout, textContent, footer, opts.Logo, faces, hyphenatorFor(opts.Language))
+	if err == nil {
+		span.SetAttributes(Attr{"width", doc.Width}, Attr{"height", doc.Height})
+	}
+	span.End(err)
+	if err != nil {
+		return nil, nil, TextContent{}, err
+	}
+	return doc, faces, textContent, nil
+}
+
+// boxBuilder creates text boxes measured with a render's faces
+type boxBuilder struct {
+	faces *faceSet
+}
+
+// lineHeight returns the height of a line of text at size
+func (bb boxBuilder) lineHeight(size float64, bold bool) int {
+	return int(math.Ceil(getTextHeight(bb.faces.face(size, bold))))
+}
+
+// text creates a text box whose baseline starts at (x, y). Align is 0 for left,
+// 0.5 for centered and 1 for right aligned text, with x the anchor.
+func (bb boxBuilder) text(role string, item int, s string, x, y int, align float64, size float64, bold bool, paint Paint) *Box {
+	width := measureTextWidth(s, bb.faces.measureFace(size, bold))
+	x -= int(float64(width) * align)
+	m := bb.faces.face(size, bold).Metrics()
+	return &Box{
+		Kind:     BoxText,
+		Role:     role,
+		Item:     item,
+		Bounds:   image.Rect(x, y-m.Ascent.Ceil(), x+width, y+m.Descent.Ceil()),
+		Paint:    paint,
+		Text:     s,
+		Baseline: y,
+		Size:     size,
+		Bold:     bold,
+	}
+}
+
+func section(role string, item int, children ...*Box) *Box {
+	b := &Box{Kind: BoxSection, Role: role, Item: item, Children: children}
+	b.fit()
+	return b
+}
+
+// add appends children and grows the section to cover them
+func (b *Box) add(children ...*Box) {
+	b.Children = append(b.Children, children...)
+	b.fit()
+}
+
+func (b *Box) fit() {
+	for _, c := range b.Children {
+		b.Bounds = b.Bounds.Union(c.Bounds)
+	}
+}
+
+// layoutDocument positions every part of the order summary. The layout is given
+// in layout pixels and converted to device pixels here.
+func layoutDocument(ctx context.Context, order OrderSummary, layout Layout, textContent TextContent, footer string, logo image.Image, faces *faceSet, hyph Hyphenator) (*Document, error) {
+	scale := layout.scale()
+	layout = layout.device()
+
+	// Size the price column to the widest amount, shrinking the item font if
+	// needed, and wrap the item names to the remaining width
+	_, span := startSpan(ctx, SpanMeasureText)
+	layout, cols, err := fitColumns(order, layout, faces)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	measureItem := faces.measureFace(layout.FontSizes.Item, false)
+	measure := func(s string) float64 { return float64(measureTextWidth(s, measureItem)) }
+	itemNames := make([][]string, len(order.Items))
+	var truncated []int
+	lineCount := 0
+	for i, item := range order.Items {
+		lines, cut := itemLines(formatItem(item), float64(cols.item), measure, hyph, layout.MaxItemLines)
+		if cut {
+			truncated = append(truncated, i)
+		}
+		itemNames[i] = lines
+		lineCount += len(lines)
+	}
+	span.SetAttributes(Attr{"lines", lineCount}, Attr{"truncated_items", len(truncated)})
+	span.End(nil)
+
+	bb := boxBuilder{faces: faces}
+	fs := layout.FontSizes
+	width, margin := layout.Width, layout.Margin
+	left, right, indent := margin*2, width-margin*2, margin*3
+	divider := func(y int) *Box {
+		return &Box{
+			Kind:    BoxLine,
+			Role:    "divider",
+			Item:    -1,
+			Bounds:  image.Rect(left, y, right+1, y+layout.Divider.height()),
+			Paint:   PaintDivider,
+			Divider: layout.Divider,
+		}
+	}
+
+	card := &Box{Kind: BoxSection, Role: "card", Item: -1, Paint: PaintCard, Radius: layout.px(cardRadius), Shadow: layout.Shadow}
+	y := margin + layout.SectionSpacing
+
+	// Header, with the logo above the title
+	header := section("header", -1)
+	if logo != nil {
+		if b := logoBox(logo, width/2, y, layout.px(logoHeight), right-left); b != nil {
+			header.add(b)
+			y = b.Bounds.Max.Y + layout.SectionSpacing
+		}
+	}
+	headerHeight := bb.lineHeight(fs.Header, true)
+	header.add(bb.text("title", -1, textContent.HeaderText, width/2, y+headerHeight, 0.5, fs.Header, true, PaintText))
+	y += headerHeight + layout.SectionSpacing
+	card.add(header, divider(y))
+	y += layout.Divider.height() + layout.SectionSpacing
+
+	// Items, each with its name wrapped beside the price
+	items := section("items", -1, bb.text("items-heading", -1, textContent.ItemsText, left, y, 0, fs.Subheader, true, PaintText))
+	y += bb.lineHeight(fs.Subheader, true) + layout.ItemSpacing
+
+	itemHeight := bb.lineHeight(fs.Item, false)
+	for i, item := range order.Items {
+		lines := itemNames[i]
+		row := section("item", i)
+		for n, line := range lines {
+			row.add(bb.text("item-name", i, line, indent, y, 0, fs.Item, false, PaintText))
+			if n == 0 {
+				price := formatMoney(order.Currency, item.Price*float64(item.Quantity))
+				row.add(bb.text("item-price", i, price, right, y, 1, fs.Item, false, PaintText))
+			}
+			y += itemHeight
+			if n < len(lines)-1 {
+				y += layout.ItemSpacing
+			}
+		}
+		// Rows span the content width so the whole line is a hit target
+		row.Bounds.Min.X, row.Bounds.Max.X = left, right+1
+		items.add(row)
+		y += layout.ItemSpacing
+	}
+	y += layout.SectionSpacing
+	card.add(items, divider(y))
+	y += layout.Divider.height() + layout.SectionSpacing
+
+	// Totals, with the grand total in bold below its own divider. Amounts use the
+	// item font size so they line up with the prices above.
+	totals := section("totals", -1)
+	totalHeight := bb.lineHeight(fs.Total, true)
+	totalRow := func(role, label string, value float64, bold bool) *Box {
+		row := section(role, -1,
+			bb.text("label", -1, label, indent, y, 0, fs.Item, bold, PaintText),
+			bb.text("amount", -1, formatMoney(order.Currency, value), right, y, 1, fs.Item, bold, PaintText),
+		)
+		row.Bounds.Min.X, row.Bounds.Max.X = left, right+1
+		return row
+	}
+	for _, row := range []struct {
+		role  string
+		label string
+		value float64
+	}{
+		{"subtotal", textContent.SubtotalText, order.Subtotal},
+		{"discount", textContent.DiscountText, order.Discount},
+		{"shipping", textContent.ShippingText, order.Shipping},
+		{"taxes", textContent.TaxesText, order.Taxes},
+	} {
+		totals.add(totalRow(row.role, row.label, row.value, false))
+		y += totalHeight + layout.SectionSpacing
+	}
+	totals.add(divider(y))
+	y += layout.Divider.height() + layout.SectionSpacing
+	totals.add(totalRow("total", textContent.TotalText, order.Total, true))
+	y += totalHeight
+	card.add(totals)
+
+	height := y + margin
+	card.Bounds = image.Rect(margin, margin, width-margin, height-margin)
+	root := &Box{Kind: BoxSection, Role: "page", Item: -1, Bounds: image.Rect(0, 0, width, height), Paint: PaintBackground, Children: []*Box{card}}
+
+	// Footer, centered in the bottom margin in a slightly smaller font
+	if footer != "" {
+		size := fs.Item * 0.8
+		root.Children = append(root.Children, bb.text("footer", -1, footer, width/2, height-bb.lineHeight(size, false), 0.5, size, false, PaintFooter))
+	}
+
+	return &Document{Width: width, Height: height, Scale: scale, Root: root, TruncatedItems: truncated}, nil
+}
+
+// logoBox fits img within maxWidth by maxHeight, centered on x with its top at y
+func logoBox(img image.Image, x, y, maxHeight, maxWidth int) *Box {
+	b := img.Bounds()
+	if b.Empty() {
+		return nil
+	}
+	f := math.Min(float64(maxHeight)/float64(b.Dy()), float64(maxWidth)/float64(b.Dx()))
+	w, h := int(math.Round(float64(b.Dx())*f)), int(math.Round(float64(b.Dy())*f))
+	return &Box{Kind: BoxImage, Role: "logo", Item: -1, Bounds: image.Rect(x-w/2, y, x-w/2+w, y+h), Image: img}
+}
+
+func getTextHeight(face font.Face) float64 {
+	metrics := face.Metrics()
+	return float64(metrics.Height) / 64
+}
@@ -0,0 +1,72 @@
+package ordersummary
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// TestMeasureMatchesRender checks that Measure predicts the size of the image
+// Render draws for the same order and options
+func TestMeasureMatchesRender(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 96, 48))
+	for i := range logo.Pix {
+		logo.Pix[i] = 0xff
+	}
+	for _, tc := range []struct {
+		name   string
+		modify func(o *OrderSummary, opts *Options)
+	}{
+		{"default", func(o *OrderSummary, opts *Options) {}},
+		{"long name", func(o *OrderSummary, opts *Options) {
+			o.Items[1].Name = strings.Repeat("Hand-painted ceramic planter ", 8)
+		}},
+		{"max lines", func(o *OrderSummary, opts *Options) {
+			o.Items[1].Name = strings.Repeat("Hand-painted ceramic planter ", 8)
+			opts.Layout.MaxItemLines = 2
+		}},
+		{"many items", func(o *OrderSummary, opts *Options) {
+			for i := 0; i < 20; i++ {
+				o.Items = append(o.Items, Item{Name: "Orchid food", Quantity: i + 1, Price: 9.5})
+			}
+		}},
+		{"scale", func(o *OrderSummary, opts *Options) { opts.Layout.Scale = 1.5 }},
+		{"narrow", func(o *OrderSummary, opts *Options) { opts.Layout.Width = 320 }},
+		{"footer", func(o *OrderSummary, opts *Options) { opts.Footer = "Powered by Zoko" }},
+		{"logo", func(o *OrderSummary, opts *Options) { opts.Logo = logo }},
+		{"shadow", func(o *OrderSummary, opts *Options) {
+			opts.Layout.Shadow = Shadow{Offset: 4, Blur: 8}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			order := testOrder()
+			opts := Options{Layout: DefaultLayout(), Text: plainText, Format: FormatPNG}
+			tc.modify(&order, &opts)
+
+			doc, err := Measure(order, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			res, err := Render(order, &buf, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Width != res.Width || doc.Height != res.Height {
+				t.Errorf("Measure gives %dx%d, Render drew %dx%d", doc.Width, doc.Height, res.Width, res.Height)
+			}
+			img, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := img.Bounds().Size(); size.X != doc.Width || size.Y != doc.Height {
+				t.Errorf("image is %v, Measure gives %dx%d", size, doc.Width, doc.Height)
+			}
+			if !doc.Root.Bounds.In(image.Rect(0, 0, doc.Width, doc.Height)) {
+				t.Errorf("boxes cover %v, outside the %dx%d image", doc.Root.Bounds, doc.Width, doc.Height)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package ordersummary
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultPriceGutter is the space, in pixels, kept between item names and prices
+// when Layout.PriceGutter is unset
+const DefaultPriceGutter = 12
+
+// columns holds the measured widths of the item name and price columns
+type columns struct {
+	item  int
+	price int
+}
+
+// fitColumns measures the widest formatted amount in the order and sizes the price
+// column to fit it. When the remaining item column is narrower than the layout's
+// minimum, the item font is scaled down until it fits; the returned layout carries
+// the adjusted font size. If the column is still too narrow at MinFontSize, item
+// names are kept to one line so they end in an ellipsis rather than running down
+// the image a word at a time.
+func fitColumns(order OrderSummary, layout Layout, faces *faceSet) (Layout, columns, error) {
+	gutter := layout.priceGutter()
+	minItem := layout.minItemColumnWidth()
+	// Item text starts at 3 margins and prices end 2 margins from the right edge
+	available := layout.Width - layout.Margin*5
+
+	size := layout.FontSizes.Item
+	for {
+		price := widestAmount(order, size, faces)
+		item := available - price - gutter
+		if item >= minItem {
+			layout.FontSizes.Item = size
+			return layout, columns{item: item, price: price}, nil
+		}
+		if size <= MinFontSize {
+			if item <= 0 {
+				return layout, columns{}, newError("fit price column", ErrInvalidLayout,
+					fmt.Errorf("amounts need %dpx, leaving no room for item names at the minimum font size", price))
+			}
+			layout.FontSizes.Item = size
+			layout.MaxItemLines = 1
+			return layout, columns{item: item, price: price}, nil
+		}
+		size = math.Max(MinFontSize, math.Floor(size*0.9*2)/2)
+	}
+}
+
+// widestAmount returns the width of the widest formatted amount drawn at the item font size
+func widestAmount(order OrderSummary, size float64, faces *faceSet) int {
+	regular := faces.measureFace(size, false)
+	widest := 0
+	for _, item := range order.Items {
+		widest = max(widest, measureTextWidth(formatMoney(order.Currency, item.Price*float64(item.Quantity)), regular))
+	}
+	for _, v := range []float64{order.Subtotal, order.Discount, order.Shipping, order.Taxes} {
+		widest = max(widest, measureTextWidth(formatMoney(order.Currency, v), regular))
+	}
+	// The total is drawn in bold
+	widest = max(widest, measureTextWidth(formatMoney(order.Currency, order.Total), faces.measureFace(size, true)))
+	return widest
+}
+
+func (l Layout) priceGutter() int {
+	if l.PriceGutter == 0 {
+		return DefaultPriceGutter
+	}
+	return l.PriceGutter
+}
+
+func (l Layout) minItemColumnWidth() int {
+	if l.MinItemColumnWidth == 0 {
+		return DefaultMinItemColumnWidth
+	}
+	return l.MinItemColumnWidth
+}
@@ -0,0 +1,109 @@
+package ordersummary
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// bigOrder has amounts long enough to crowd the item column
+func bigOrder() OrderSummary {
+	order := testOrder()
+	order.Items[0].Price = 123456789.5
+	order.Subtotal = 123457029.5
+	order.Total = 123457029.5
+	return order
+}
+
+func newTestFaces(t *testing.T) *faceSet {
+	t.Helper()
+	faces, err := newFaceSet(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return faces
+}
+
+func TestFitColumns(t *testing.T) {
+	faces := newTestFaces(t)
+	order := testOrder()
+	layout := DefaultLayout()
+
+	got, cols, err := fitColumns(order, layout, faces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	price := widestAmount(order, layout.FontSizes.Item, faces)
+	if cols.price != price || cols.item != layout.Width-layout.Margin*5-DefaultPriceGutter-price {
+		t.Errorf("columns = %+v, want a %dpx price column and the rest for names", cols, price)
+	}
+	if got.FontSizes.Item != layout.FontSizes.Item || got.MaxItemLines != 0 {
+		t.Errorf("fitting columns changed the layout: %+v", got)
+	}
+}
+
+// TestFitColumnsShrinksFont sets the minimum item column so that the amounts fit
+// only after a given number of 0.9x steps
+func TestFitColumnsShrinksFont(t *testing.T) {
+	faces := newTestFaces(t)
+	order := bigOrder()
+	base := DefaultLayout()
+	available := base.Width - base.Margin*5 - DefaultPriceGutter
+
+	for _, want := range []float64{10.5, 9, 8, 7, MinFontSize} {
+		layout := base
+		layout.MinItemColumnWidth = available - widestAmount(order, want, faces)
+		got, cols, err := fitColumns(order, layout, faces)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.FontSizes.Item != want {
+			t.Errorf("item font = %v, want %v", got.FontSizes.Item, want)
+		}
+		if cols.item < layout.MinItemColumnWidth {
+			t.Errorf("item column %d is narrower than the minimum %d", cols.item, layout.MinItemColumnWidth)
+		}
+		if got.MaxItemLines != 0 {
+			t.Errorf("font %v: names limited to %d lines though the column fits", want, got.MaxItemLines)
+		}
+	}
+}
+
+func TestFitColumnsTruncatesBelowMinFont(t *testing.T) {
+	faces := newTestFaces(t)
+	order := bigOrder()
+	layout := DefaultLayout()
+	layout.MinItemColumnWidth = layout.Width - layout.Margin*5 - DefaultPriceGutter - widestAmount(order, MinFontSize, faces) + 1
+
+	got, cols, err := fitColumns(order, layout, faces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.FontSizes.Item != MinFontSize {
+		t.Errorf("item font = %v, want MinFontSize", got.FontSizes.Item)
+	}
+	if got.MaxItemLines != 1 || cols.item != layout.MinItemColumnWidth-1 {
+		t.Errorf("got %d lines in a %dpx column, want 1 line in %dpx", got.MaxItemLines, cols.item, layout.MinItemColumnWidth-1)
+	}
+
+	order.Items[1].Name = strings.Repeat("Hand-painted ceramic planter with drainage tray ", 20)
+	doc, err := Measure(order, Options{Layout: layout, Text: plainText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.TruncatedItems) != 1 || doc.TruncatedItems[0] != 1 {
+		t.Errorf("got truncated items %v, want [1]", doc.TruncatedItems)
+	}
+}
+
+func TestFitColumnsWithoutRoom(t *testing.T) {
+	faces := newTestFaces(t)
+	order := bigOrder()
+	layout := DefaultLayout()
+	// The amounts take every pixel between the margins and the gutter
+	layout.Width = layout.Margin*5 + DefaultPriceGutter + widestAmount(order, MinFontSize, faces)
+
+	if _, _, err := fitColumns(order, layout, faces); !errors.Is(err, ErrInvalidLayout) {
+		t.Errorf("got %v, want ErrInvalidLayout", err)
+	}
+}
@@ -0,0 +1,48 @@
+package ordersummary
+
+import "errors"
+
+// Sentinel errors identifying the kind of a rendering failure. Errors returned by
+// the package wrap one of these and can be checked with errors.Is.
+var (
+	ErrFontLoad          = errors.New("font load failed")
+	ErrInvalidLayout     = errors.New("invalid layout")
+	ErrTemplate          = errors.New("invalid text template")
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	ErrCanvasTooLarge    = errors.New("canvas too large")
+	ErrEncode            = errors.New("encode failed")
+)
+
+// MaxCanvasPixels limits the size of the image a single render may allocate
+const MaxCanvasPixels = 50_000_000
+
+// Error describes a failed rendering operation. It wraps both its Kind sentinel
+// and the underlying cause, so errors.Is and errors.As see through to either.
+type Error struct {
+	// Op is the operation that failed, such as "parse font gobold" or "encode png"
+	Op string
+	// Kind is one of the package's sentinel errors
+	Kind error
+	// Err is the underlying cause, if any
+	Err error
+}
+
+func (e *Error) Error() string {
+	msg := "ordersummary: " + e.Op + ": " + e.Kind.Error()
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the kind and the cause
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func newError(op string, kind, err error) *Error {
+	return &Error{Op: op, Kind: kind, Err: err}
+}
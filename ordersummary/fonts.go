@@ -0,0 +1,98 @@
+package ordersummary
+
+import (
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// FontCache holds parsed fonts so they can be shared between renders and goroutines
+type FontCache struct {
+	mu    sync.Mutex
+	fonts map[string]*truetype.Font
+}
+
+// NewFontCache creates an empty font cache
+func NewFontCache() *FontCache {
+	return &FontCache{fonts: make(map[string]*truetype.Font)}
+}
+
+// defaultFontCache is used by renders that do not set Options.Fonts
+var defaultFontCache = NewFontCache()
+
+// Font returns the font registered under name, parsing data on first use
+func (c *FontCache) Font(name string, data []byte) (*truetype.Font, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f, ok := c.fonts[name]; ok {
+		return f, nil
+	}
+	f, err := truetype.Parse(data)
+	if err != nil {
+		return nil, newError("parse font "+name, ErrFontLoad, err)
+	}
+	c.fonts[name] = f
+	return f, nil
+}
+
+// faceSet creates the font faces used by a single render. Faces keep glyph caches
+// that are not safe for concurrent use, so every render gets its own set.
+type faceSet struct {
+	regular *truetype.Font
+	bold    *truetype.Font
+	// dpi renders point sizes at the layout's scale, 72 at 1x
+	dpi   float64
+	faces map[faceKey]font.Face
+}
+
+type faceKey struct {
+	size    float64
+	bold    bool
+	hinting font.Hinting
+}
+
+func newFaceSet(cache *FontCache, scale float64) (*faceSet, error) {
+	if cache == nil {
+		cache = defaultFontCache
+	}
+	regular, err := cache.Font("goregular", goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := cache.Font("gobold", gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	return &faceSet{regular: regular, bold: bold, dpi: 72 * scale, faces: make(map[faceKey]font.Face)}, nil
+}
+
+// face returns the hinted regular or bold face at size used for drawing
+func (fs *faceSet) face(size float64, bold bool) font.Face {
+	return fs.get(faceKey{size, bold, font.HintingFull})
+}
+
+// measureFace returns the unhinted face at size used for measuring text. Hinted
+// advances need the glyph program for every lookup, which makes wrapping slow.
+func (fs *faceSet) measureFace(size float64, bold bool) font.Face {
+	return fs.get(faceKey{size, bold, font.HintingNone})
+}
+
+func (fs *faceSet) get(key faceKey) font.Face {
+	if f, ok := fs.faces[key]; ok {
+		return f
+	}
+	ttf := fs.regular
+	if key.bold {
+		ttf = fs.bold
+	}
+	f := truetype.NewFace(ttf, &truetype.Options{
+		Size:    key.size,
+		DPI:     fs.dpi,
+		Hinting: key.hinting,
+	})
+	fs.faces[key] = f
+	return f
+}
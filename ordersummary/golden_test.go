@@ -0,0 +1,149 @@
+package ordersummary
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/biswaz/img-maker/internal/golden"
+)
+
+// Regenerate the goldens after an intended change to the output with
+//
+//	go test ./ordersummary -run Golden -update
+var update = flag.Bool("update", false, "write the rendered images as the new goldens under testdata/golden")
+
+// goldenFooter is fixed so goldens do not depend on the CLI or server defaults
+const goldenFooter = "Powered by Zoko"
+
+// goldenFixture is an order under testdata/golden/orders with the settings it is
+// rendered with
+type goldenFixture struct {
+	name   string
+	Locale string       `json:"locale"`
+	Layout *Layout      `json:"layout"`
+	Order  OrderSummary `json:"order"`
+}
+
+func (f goldenFixture) layout() Layout {
+	if f.Layout == nil {
+		return DefaultLayout()
+	}
+	return f.Layout.WithDefaults()
+}
+
+// TestGolden renders every fixture through each renderer and compares the result
+// with the golden image beside it. A failed comparison writes a diff image, with
+// differing pixels in red, next to the golden as <name>.diff.png.
+func TestGolden(t *testing.T) {
+	fixtures := loadGoldenFixtures(t)
+	for _, r := range []struct {
+		name   string
+		render func(t *testing.T, f goldenFixture) image.Image
+	}{
+		{"raw", renderGoldenRaw},
+		{"gg", renderGoldenGG},
+	} {
+		for _, f := range fixtures {
+			t.Run(r.name+"/"+f.name, func(t *testing.T) {
+				got := r.render(t, f)
+				path := filepath.Join("testdata", "golden", r.name, f.name+".png")
+				diffPath := strings.TrimSuffix(path, ".png") + ".diff.png"
+				res, err := golden.Check(path, got, *update, diffPath, golden.Options{})
+				if err != nil {
+					t.Errorf("%v; %d of %d pixels differ (%.3f%%), diff image at %s",
+						err, res.DiffPixels, res.Total, 100*res.Ratio(), diffPath)
+					return
+				}
+				os.Remove(diffPath)
+			})
+		}
+	}
+}
+
+func loadGoldenFixtures(t *testing.T) []goldenFixture {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "golden", "orders", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no golden fixtures")
+	}
+	sort.Strings(paths)
+
+	var fixtures []goldenFixture
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f goldenFixture
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if err := f.Order.Validate(); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		f.name = strings.TrimSuffix(filepath.Base(path), ".json")
+		if f.Locale == "" {
+			f.Locale = DefaultLocale
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures
+}
+
+func renderGoldenRaw(t *testing.T, f goldenFixture) image.Image {
+	t.Helper()
+	text, err := TextContentForLocale(f.Locale, len(f.Order.Items))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	_, err = Render(f.Order, &buf, Options{
+		Layout:   f.layout(),
+		Text:     text,
+		Footer:   goldenFooter,
+		Format:   FormatPNG,
+		Language: f.Locale,
+	})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// renderGoldenGG draws with the gg renderer, which only writes to files
+func renderGoldenGG(t *testing.T, f goldenFixture) image.Image {
+	t.Helper()
+	out, err := os.Create(filepath.Join(t.TempDir(), "gg.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err := GenerateOrderSummaryGG(f.Order, out, f.layout()); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if _, err := out.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
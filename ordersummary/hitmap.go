@@ -0,0 +1,82 @@
+package ordersummary
+
+import "image"
+
+// Region is an area of a rendered order summary and the data it shows
+type Region struct {
+	// Kind is "item", a total such as "subtotal" or "total", or the role of an
+	// image such as "logo"
+	Kind string `json:"kind"`
+	// X, Y, Width and Height locate the region in device pixels
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Index is the position of the item in the order for item regions
+	Index *int `json:"index,omitempty"`
+	// Item is the order item shown by an item region
+	Item *Item `json:"item,omitempty"`
+	// Label is the text drawn for a total
+	Label string `json:"label,omitempty"`
+	// Amount and Currency are the price of an item row or the value of a total
+	Amount   *float64 `json:"amount,omitempty"`
+	Currency string   `json:"currency,omitempty"`
+}
+
+// HitMap lists the regions of a rendered order summary so a UI can overlay
+// clickable areas on the image
+type HitMap struct {
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Scale  float64 `json:"scale"`
+	// OrderID identifies the order the map was made for
+	OrderID string   `json:"orderId,omitempty"`
+	Regions []Region `json:"regions"`
+}
+
+// totalRoles maps the roles of total rows to the order amount each one shows
+var totalRoles = map[string]func(OrderSummary) float64{
+	"subtotal": func(o OrderSummary) float64 { return o.Subtotal },
+	"discount": func(o OrderSummary) float64 { return o.Discount },
+	"shipping": func(o OrderSummary) float64 { return o.Shipping },
+	"taxes":    func(o OrderSummary) float64 { return o.Taxes },
+	"total":    func(o OrderSummary) float64 { return o.Total },
+}
+
+// HitMap returns the item rows, totals and images of the document, in drawing
+// order. The order must be the one the document was laid out from.
+func (d *Document) HitMap(order OrderSummary) *HitMap {
+	hm := &HitMap{Width: d.Width, Height: d.Height, Scale: d.Scale, OrderID: order.OrderID, Regions: []Region{}}
+	d.Root.Walk(func(b *Box) {
+		switch {
+		case b.Kind == BoxSection && b.Role == "item" && b.Item >= 0 && b.Item < len(order.Items):
+			index, item := b.Item, order.Items[b.Item]
+			amount := item.Price * float64(item.Quantity)
+			r := region("item", b.Bounds)
+			r.Index, r.Item, r.Amount, r.Currency = &index, &item, &amount, order.Currency
+			hm.Regions = append(hm.Regions, r)
+		case b.Kind == BoxSection && totalRoles[b.Role] != nil:
+			amount := totalRoles[b.Role](order)
+			r := region(b.Role, b.Bounds)
+			r.Label, r.Amount, r.Currency = childText(b, "label"), &amount, order.Currency
+			hm.Regions = append(hm.Regions, r)
+		case b.Kind == BoxImage:
+			hm.Regions = append(hm.Regions, region(b.Role, b.Bounds))
+		}
+	})
+	return hm
+}
+
+func region(kind string, r image.Rectangle) Region {
+	return Region{Kind: kind, X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+}
+
+// childText returns the text of the first text box with the role below b
+func childText(b *Box, role string) string {
+	for _, c := range b.Children {
+		if c.Kind == BoxText && c.Role == role {
+			return c.Text
+		}
+	}
+	return ""
+}
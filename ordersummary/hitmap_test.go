@@ -0,0 +1,92 @@
+package ordersummary
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func hitMap(t *testing.T, order OrderSummary, scale float64) *HitMap {
+	t.Helper()
+	layout := DefaultLayout()
+	layout.Scale = scale
+	doc, err := Measure(order, Options{Layout: layout, Text: plainText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc.HitMap(order)
+}
+
+func regionRect(r Region) image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+func TestHitMapItems(t *testing.T) {
+	order := testOrder()
+	order.Items[1].Name = strings.Repeat("Hand-painted ceramic planter ", 6)
+	order.Items = append(order.Items, Item{Name: "Orchid food", Quantity: 3, Price: 9.5})
+	hm := hitMap(t, order, 1)
+	canvas := image.Rect(0, 0, hm.Width, hm.Height)
+
+	var items []Region
+	for _, r := range hm.Regions {
+		if !regionRect(r).In(canvas) || r.Width <= 0 || r.Height <= 0 {
+			t.Errorf("%s region %v is empty or outside the %dx%d canvas", r.Kind, regionRect(r), hm.Width, hm.Height)
+		}
+		if r.Kind == "item" {
+			items = append(items, r)
+		}
+	}
+	if len(items) != len(order.Items) {
+		t.Fatalf("got %d item regions, want %d", len(items), len(order.Items))
+	}
+	for i, r := range items {
+		if r.Index == nil || *r.Index != i || r.Item == nil || r.Item.Name != order.Items[i].Name {
+			t.Errorf("region %d does not describe item %d", i, i)
+		}
+		if r.Amount == nil || *r.Amount != order.Items[i].Price*float64(order.Items[i].Quantity) || r.Currency != order.Currency {
+			t.Errorf("item %d has amount %v %s", i, r.Amount, r.Currency)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := regionRect(items[i-1])
+		if r.Y < prev.Max.Y {
+			t.Errorf("item %d at y=%d starts before item %d ends at y=%d", i, r.Y, i-1, prev.Max.Y)
+		}
+		if regionRect(r).Overlaps(prev) {
+			t.Errorf("items %d and %d overlap", i-1, i)
+		}
+	}
+	// The wrapped name makes its row taller than the single-line rows
+	if items[1].Height <= items[0].Height {
+		t.Errorf("wrapped item is %dpx tall, single-line item %dpx", items[1].Height, items[0].Height)
+	}
+
+	var kinds []string
+	for _, r := range hm.Regions[len(items):] {
+		kinds = append(kinds, r.Kind)
+	}
+	if got := strings.Join(kinds, ","); got != "subtotal,discount,shipping,taxes,total" {
+		t.Errorf("totals are %s", got)
+	}
+}
+
+func TestHitMapScales(t *testing.T) {
+	order := testOrder()
+	hm1, hm2 := hitMap(t, order, 1), hitMap(t, order, 2)
+	if hm2.Scale != 2 || hm2.Width != 2*hm1.Width || hm2.Height != 2*hm1.Height {
+		t.Fatalf("2x map is %dx%d at %v, want twice %dx%d", hm2.Width, hm2.Height, hm2.Scale, hm1.Width, hm1.Height)
+	}
+	if len(hm1.Regions) != len(hm2.Regions) {
+		t.Fatalf("got %d regions at 2x, %d at 1x", len(hm2.Regions), len(hm1.Regions))
+	}
+	// Lengths are rounded to device pixels separately, so allow a pixel or two
+	near := func(got, want int) bool { return got >= want-2 && got <= want+2 }
+	for i, r1 := range hm1.Regions {
+		r2 := hm2.Regions[i]
+		if r2.Kind != r1.Kind || !near(r2.X, 2*r1.X) || !near(r2.Y, 2*r1.Y) || !near(r2.Width, 2*r1.Width) || !near(r2.Height, 2*r1.Height) {
+			t.Errorf("%s region is %v at 2x, %v at 1x", r1.Kind, regionRect(r2), regionRect(r1))
+		}
+	}
+}
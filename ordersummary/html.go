@@ -0,0 +1,148 @@
+package ordersummary
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"image/color"
+	"io"
+	"math"
+)
+
+// htmlTemplate lays the summary out with tables and inline styles, which email
+// clients support far better than stylesheets or flexbox
+var htmlTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html{{if .Lang}} lang="{{.Lang}}"{{end}}>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Text.HeaderText}}</title>
+</head>
+<body style="margin:0;padding:{{.Margin}}px;background-color:{{.Theme.Background}};font-family:Helvetica,Arial,sans-serif;color:{{.Theme.Text}}">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="max-width:{{.Width}}px;margin:0 auto;background-color:{{.Theme.Card}};border-radius:{{.Radius}}px{{if .Shadow}};box-shadow:{{.Shadow}}{{end}}">
+<tr><td dir="auto" style="padding:{{.Spacing}}px {{.Margin}}px">
+<h1 style="margin:0 0 {{.Spacing}}px;text-align:center;font-size:{{.FontSizes.Header}}px">{{.Text.HeaderText}}</h1>
+<div style="border-top:{{.Divider}}"></div>
+<h2 style="margin:{{.Spacing}}px 0 {{.ItemSpacing}}px;font-size:{{.FontSizes.Subheader}}px">{{.Text.ItemsText}}</h2>
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="font-size:{{.FontSizes.Item}}px">
+{{- range .Items}}
+<tr><td dir="auto" style="padding:0 {{$.Margin}}px {{$.ItemSpacing}}px">{{.Name}}</td><td style="padding-bottom:{{$.ItemSpacing}}px;text-align:right;white-space:nowrap;vertical-align:top">{{.Price}}</td></tr>
+{{- end}}
+</table>
+<div style="margin-top:{{.Spacing}}px;border-top:{{.Divider}}"></div>
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="margin-top:{{.Spacing}}px;font-size:{{.FontSizes.Item}}px">
+{{- range .Totals}}
+<tr><td style="padding:0 {{$.Margin}}px {{$.Spacing}}px">{{.Label}}</td><td style="padding-bottom:{{$.Spacing}}px;text-align:right;white-space:nowrap">{{.Amount}}</td></tr>
+{{- end}}
+<tr><td colspan="2" style="padding-bottom:{{.Spacing}}px"><div style="border-top:{{.Divider}}"></div></td></tr>
+<tr style="font-weight:bold"><td style="padding:0 {{.Margin}}px">{{.Total.Label}}</td><td style="text-align:right;white-space:nowrap">{{.Total.Amount}}</td></tr>
+</table>
+</td></tr>
+</table>
+{{- if .Footer}}
+<p dir="auto" style="margin:{{.Spacing}}px 0 0;text-align:center;font-size:{{.FooterSize}}px;color:{{.Theme.Footer}}">{{.Footer}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// htmlRow is an item or total line of the HTML summary
+type htmlRow struct {
+	Name   string
+	Price  string
+	Label  string
+	Amount string
+}
+
+// htmlTheme holds theme colors as CSS values
+type htmlTheme struct {
+	Background, Card, Text, Footer template.CSS
+}
+
+// renderHTML is Render for FormatHTML. It mirrors the sections of the image with
+// the same text, amounts, theme colors and layout sizes.
+func renderHTML(order OrderSummary, w io.Writer, opts Options, theme Theme) (*Result, error) {
+	layout := opts.Layout
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	textContent, footer, err := ExpandTextContent(order, opts.Text, opts.Footer)
+	if err != nil {
+		return nil, err
+	}
+
+	data := struct {
+		Lang                  string
+		Text                  TextContent
+		Theme                 htmlTheme
+		Width, Margin, Radius int
+		Spacing, ItemSpacing  int
+		FontSizes             FontSizes
+		FooterSize            float64
+		Divider, Shadow       template.CSS
+		Items, Totals         []htmlRow
+		Total                 htmlRow
+		Footer                string
+	}{
+		Lang: opts.Language,
+		Text: textContent,
+		Theme: htmlTheme{
+			Background: cssColor(theme.Background),
+			Card:       cssColor(theme.Card),
+			Text:       cssColor(theme.Text),
+			Footer:     cssColor(theme.Footer),
+		},
+		Width:       layout.Width,
+		Margin:      layout.Margin,
+		Radius:      cardRadius,
+		Spacing:     layout.SectionSpacing,
+		ItemSpacing: layout.ItemSpacing,
+		FontSizes:   layout.FontSizes,
+		FooterSize:  math.Round(layout.FontSizes.Item*8) / 10,
+		Divider:     template.CSS(fmt.Sprintf("%gpx %s %s", layout.Divider.thickness(), layout.Divider.style(), cssColor(theme.Divider))),
+		Total:       htmlRow{Label: textContent.TotalText, Amount: formatMoney(order.Currency, order.Total)},
+		Footer:      footer,
+	}
+	if layout.Shadow.enabled() {
+		data.Shadow = template.CSS(fmt.Sprintf("0 %dpx %dpx %s", layout.Shadow.Offset, layout.Shadow.Blur, cssColor(theme.Shadow)))
+	}
+	for _, item := range order.Items {
+		data.Items = append(data.Items, htmlRow{Name: formatItem(item), Price: formatMoney(order.Currency, item.Price*float64(item.Quantity))})
+	}
+	for _, row := range []struct {
+		label string
+		value float64
+	}{
+		{textContent.SubtotalText, order.Subtotal},
+		{textContent.DiscountText, order.Discount},
+		{textContent.ShippingText, order.Shipping},
+		{textContent.TaxesText, order.Taxes},
+	} {
+		data.Totals = append(data.Totals, htmlRow{Label: row.label, Amount: formatMoney(order.Currency, row.value)})
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := htmlTemplate.Execute(bw, data); err != nil {
+		return nil, newError("write html", ErrEncode, err)
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, newError("write html", ErrEncode, err)
+	}
+	res := &Result{Format: FormatHTML}
+	if opts.AltText {
+		res.AltText = altText(order, textContent)
+	}
+	return res, nil
+}
+
+// cssColor formats c as a CSS color, using rgba() for translucent colors since
+// email clients rarely support eight digit hex. The result is built from numbers
+// alone, so it is safe to insert into a style attribute.
+func cssColor(c color.RGBA) template.CSS {
+	if c.A == 255 {
+		return template.CSS(formatHexColor(c))
+	}
+	// CSS colors are not premultiplied
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return template.CSS(fmt.Sprintf("rgba(%d,%d,%d,%.3g)", n.R, n.G, n.B, float64(n.A)/255))
+}
@@ -0,0 +1,76 @@
+package ordersummary
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func renderHTMLString(t *testing.T, order OrderSummary, opts Options) string {
+	t.Helper()
+	opts.Format = FormatHTML
+	if opts.Layout == (Layout{}) {
+		opts.Layout = DefaultLayout()
+	}
+	var buf bytes.Buffer
+	if _, err := Render(order, &buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestHTMLEscapesItemNames(t *testing.T) {
+	order := testOrder()
+	order.Items[0].Name = "<script>alert(1)</script>"
+	order.Items[1].Name = "A & B"
+	out := renderHTMLString(t, order, Options{Text: plainText})
+
+	for _, want := range []string{
+		"1x &lt;script&gt;alert(1)&lt;/script&gt;",
+		"2x A &amp; B",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+	for _, unwanted := range []string{"<script>", "A & B"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output contains unescaped %q", unwanted)
+		}
+	}
+}
+
+func TestHTMLEscapesLabelsAndFooter(t *testing.T) {
+	text := plainText
+	text.HeaderText = `Tom's <b>"Shop"</b>`
+	out := renderHTMLString(t, testOrder(), Options{
+		Text:     text,
+		Footer:   "<img src=x onerror=alert(1)>",
+		Language: `en" onload="alert(1)`,
+	})
+
+	if strings.Contains(out, "<b>") || strings.Contains(out, "<img") || strings.Contains(out, `onload="`) {
+		t.Errorf("output contains unescaped markup:\n%s", out)
+	}
+	if !strings.Contains(out, "<title>Tom&#39;s &lt;b&gt;&#34;Shop&#34;&lt;/b&gt;</title>") {
+		t.Errorf("header is not escaped in the title:\n%s", out)
+	}
+	if !strings.Contains(out, "&lt;img src=x onerror=alert(1)&gt;") {
+		t.Errorf("footer is not escaped:\n%s", out)
+	}
+}
+
+func TestHTMLMatchesText(t *testing.T) {
+	out := renderHTMLString(t, testOrder(), Options{Text: plainText, Footer: "Thank you"})
+	for _, want := range []string{
+		"1x Phalaenopsis Orchid</td>",
+		"INR 240.00</td>",
+		"Total:</td>",
+		"INR 589.99</td>",
+		"Thank you</p>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package ordersummary
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Layout limits enforced by Validate
+const (
+	// MinFontSize is the smallest font size, in points, that stays legible
+	MinFontSize = 6
+	// DefaultMinItemColumnWidth is the narrowest item name column, in pixels, when
+	// Layout.MinItemColumnWidth is zero
+	DefaultMinItemColumnWidth = 80
+	// MaxScale is the largest Scale, beyond which images grow impractically large
+	MaxScale = 4
+)
+
+// cardRadius is the corner radius of the content card, in layout pixels
+const cardRadius = 10
+
+// DefaultLayout returns the layout used when no layout is configured
+func DefaultLayout() Layout {
+	return Layout{
+		Width:          700,
+		Margin:         25,
+		HeaderHeight:   80,
+		ItemSpacing:    8,
+		SectionSpacing: 20,
+		FontSizes: FontSizes{
+			Header:    24,
+			Subheader: 18,
+			Item:      12,
+			Total:     14,
+		},
+	}
+}
+
+// WithDefaults returns a copy of the layout with every zero field taken from DefaultLayout
+func (l Layout) WithDefaults() Layout {
+	d := DefaultLayout()
+	if l.Width == 0 {
+		l.Width = d.Width
+	}
+	if l.Margin == 0 {
+		l.Margin = d.Margin
+	}
+	if l.HeaderHeight == 0 {
+		l.HeaderHeight = d.HeaderHeight
+	}
+	if l.ItemSpacing == 0 {
+		l.ItemSpacing = d.ItemSpacing
+	}
+	if l.SectionSpacing == 0 {
+		l.SectionSpacing = d.SectionSpacing
+	}
+	if l.FontSizes.Header == 0 {
+		l.FontSizes.Header = d.FontSizes.Header
+	}
+	if l.FontSizes.Subheader == 0 {
+		l.FontSizes.Subheader = d.FontSizes.Subheader
+	}
+	if l.FontSizes.Item == 0 {
+		l.FontSizes.Item = d.FontSizes.Item
+	}
+	if l.FontSizes.Total == 0 {
+		l.FontSizes.Total = d.FontSizes.Total
+	}
+	return l
+}
+
+// Validate reports every problem that would keep the layout from producing a readable
+// image. The returned error wraps ErrInvalidLayout.
+func (l Layout) Validate() error {
+	var errs []error
+	if l.Width <= 0 {
+		errs = append(errs, fmt.Errorf("width must be positive, got %d", l.Width))
+	}
+	if l.Margin < 0 {
+		errs = append(errs, fmt.Errorf("margin must not be negative, got %d", l.Margin))
+	} else if l.Width > 0 && l.Margin*4 >= l.Width {
+		errs = append(errs, fmt.Errorf("margin %d leaves no content area in width %d", l.Margin, l.Width))
+	}
+	if l.HeaderHeight < 0 {
+		errs = append(errs, fmt.Errorf("header height must not be negative, got %d", l.HeaderHeight))
+	}
+	if l.ItemSpacing < 0 {
+		errs = append(errs, fmt.Errorf("item spacing must not be negative, got %d", l.ItemSpacing))
+	}
+	if l.SectionSpacing < 0 {
+		errs = append(errs, fmt.Errorf("section spacing must not be negative, got %d", l.SectionSpacing))
+	}
+	if l.PriceGutter < 0 {
+		errs = append(errs, fmt.Errorf("price gutter must not be negative, got %d", l.PriceGutter))
+	}
+	if l.MinItemColumnWidth < 0 {
+		errs = append(errs, fmt.Errorf("minimum item column width must not be negative, got %d", l.MinItemColumnWidth))
+	}
+	if math.IsNaN(l.Scale) || l.Scale < 0 || l.Scale > MaxScale {
+		errs = append(errs, fmt.Errorf("scale must be between 0 and %d, got %v", MaxScale, l.Scale))
+	}
+	if err := l.Divider.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if l.Shadow.Blur < 0 {
+		errs = append(errs, fmt.Errorf("shadow blur must not be negative, got %d", l.Shadow.Blur))
+	}
+	if l.MaxItemLines < 0 {
+		errs = append(errs, fmt.Errorf("max item lines must not be negative, got %d", l.MaxItemLines))
+	}
+
+	for _, fs := range []struct {
+		name string
+		size float64
+	}{
+		{"header", l.FontSizes.Header},
+		{"subheader", l.FontSizes.Subheader},
+		{"item", l.FontSizes.Item},
+		{"total", l.FontSizes.Total},
+	} {
+		if math.IsNaN(fs.size) || math.IsInf(fs.size, 0) || fs.size < MinFontSize {
+			errs = append(errs, fmt.Errorf("%s font size must be at least %d, got %v", fs.name, MinFontSize, fs.size))
+		}
+	}
+
+	// The price column is measured at render time; here only check that the item
+	// column can reach its minimum width at all
+	if l.Margin >= 0 && l.Margin*4 < l.Width {
+		if itemWidth := l.Width - l.Margin*5 - l.priceGutter(); itemWidth < l.minItemColumnWidth() {
+			errs = append(errs, fmt.Errorf("width %d leaves %dpx for item names, need at least %d", l.Width, itemWidth, l.minItemColumnWidth()))
+		}
+	}
+
+	if len(errs) > 0 {
+		return newError("validate layout", ErrInvalidLayout, errors.Join(errs...))
+	}
+	return nil
+}
+
+// scale returns the number of device pixels per layout pixel
+func (l Layout) scale() float64 {
+	if l.Scale == 0 {
+		return 1
+	}
+	return l.Scale
+}
+
+// px converts a length in layout pixels to device pixels
+func (l Layout) px(v int) int {
+	return int(math.Round(float64(v) * l.scale()))
+}
+
+// device returns the layout with every length converted to device pixels. Font
+// sizes stay in points and are scaled by the faces' DPI instead. It must only be
+// applied once.
+func (l Layout) device() Layout {
+	d := l
+	d.Width = l.px(l.Width)
+	d.Margin = l.px(l.Margin)
+	d.HeaderHeight = l.px(l.HeaderHeight)
+	d.ItemSpacing = l.px(l.ItemSpacing)
+	d.SectionSpacing = l.px(l.SectionSpacing)
+	d.PriceGutter = l.px(l.priceGutter())
+	d.MinItemColumnWidth = l.px(l.minItemColumnWidth())
+	d.Divider.Thickness = l.Divider.thickness() * l.scale()
+	d.Shadow.Offset = l.px(l.Shadow.Offset)
+	d.Shadow.Blur = l.px(l.Shadow.Blur)
+	return d
+}
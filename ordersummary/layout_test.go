@@ -0,0 +1,157 @@
+package ordersummary
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidateRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(l *Layout)
+		want   string
+	}{
+		{"zero width", func(l *Layout) { l.Width = 0 }, "width must be positive"},
+		{"negative width", func(l *Layout) { l.Width = -700 }, "width must be positive"},
+		{"negative margin", func(l *Layout) { l.Margin = -1 }, "margin must not be negative"},
+		{"margin filling the width", func(l *Layout) { l.Margin = 175 }, "leaves no content area"},
+		{"negative header height", func(l *Layout) { l.HeaderHeight = -1 }, "header height must not be negative"},
+		{"negative item spacing", func(l *Layout) { l.ItemSpacing = -1 }, "item spacing must not be negative"},
+		{"negative section spacing", func(l *Layout) { l.SectionSpacing = -1 }, "section spacing must not be negative"},
+		{"negative price gutter", func(l *Layout) { l.PriceGutter = -1 }, "price gutter must not be negative"},
+		{"negative item column", func(l *Layout) { l.MinItemColumnWidth = -1 }, "minimum item column width must not be negative"},
+		{"item column wider than the canvas", func(l *Layout) { l.MinItemColumnWidth = 701 }, "for item names, need at least 701"},
+		{"gutter leaving no item column", func(l *Layout) { l.PriceGutter = 600 }, "for item names, need at least"},
+		{"negative scale", func(l *Layout) { l.Scale = -1 }, "scale must be between"},
+		{"NaN scale", func(l *Layout) { l.Scale = math.NaN() }, "scale must be between"},
+		{"scale above MaxScale", func(l *Layout) { l.Scale = MaxScale + 0.5 }, "scale must be between"},
+		{"unknown divider", func(l *Layout) { l.Divider.Style = "wavy" }, "unknown divider style"},
+		{"negative divider", func(l *Layout) { l.Divider.Thickness = -1 }, "divider thickness must not be negative"},
+		{"negative shadow blur", func(l *Layout) { l.Shadow.Blur = -1 }, "shadow blur must not be negative"},
+		{"negative max lines", func(l *Layout) { l.MaxItemLines = -1 }, "max item lines must not be negative"},
+		{"zero header font", func(l *Layout) { l.FontSizes.Header = 0 }, "header font size must be at least"},
+		{"small subheader font", func(l *Layout) { l.FontSizes.Subheader = MinFontSize - 1 }, "subheader font size must be at least"},
+		{"NaN item font", func(l *Layout) { l.FontSizes.Item = math.NaN() }, "item font size must be at least"},
+		{"infinite total font", func(l *Layout) { l.FontSizes.Total = math.Inf(1) }, "total font size must be at least"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := DefaultLayout()
+			tc.modify(&l)
+			err := l.Validate()
+			if !errors.Is(err, ErrInvalidLayout) {
+				t.Fatalf("got %v, want ErrInvalidLayout", err)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got %q, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	l := DefaultLayout()
+	l.Margin = -1
+	l.ItemSpacing = -1
+	l.FontSizes.Item = 1
+	err := l.Validate()
+	for _, want := range []string{"margin", "item spacing", "item font size"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestValidateAccepts(t *testing.T) {
+	for name, modify := range map[string]func(l *Layout){
+		"default":                  func(l *Layout) {},
+		"zero scale":               func(l *Layout) { l.Scale = 0 },
+		"largest scale":            func(l *Layout) { l.Scale = MaxScale },
+		"narrow with small gutter": func(l *Layout) { l.Width = 300; l.PriceGutter = 4 },
+		"item column just fitting": func(l *Layout) {
+			l.MinItemColumnWidth = l.Width - l.Margin*5 - DefaultPriceGutter
+		},
+	} {
+		l := DefaultLayout()
+		modify(&l)
+		if err := l.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	if got := (Layout{}).WithDefaults(); got != DefaultLayout() {
+		t.Errorf("empty layout with defaults = %+v, want %+v", got, DefaultLayout())
+	}
+
+	set := Layout{
+		Width:              480,
+		Margin:             10,
+		HeaderHeight:       60,
+		ItemSpacing:        4,
+		SectionSpacing:     12,
+		FontSizes:          FontSizes{Header: 20, Subheader: 16, Item: 10, Total: 12},
+		PriceGutter:        6,
+		MinItemColumnWidth: 120,
+		MaxItemLines:       2,
+		Scale:              2,
+		Divider:            Divider{Style: DividerDashed, Thickness: 2},
+		Shadow:             Shadow{Offset: 2, Blur: 4},
+	}
+	if got := set.WithDefaults(); got != set {
+		t.Errorf("WithDefaults changed explicit fields:\n got %+v\nwant %+v", got, set)
+	}
+
+	partial := Layout{Width: 480, FontSizes: FontSizes{Item: 10}}
+	got := partial.WithDefaults()
+	d := DefaultLayout()
+	if got.Width != 480 || got.FontSizes.Item != 10 {
+		t.Errorf("WithDefaults replaced explicit fields: %+v", got)
+	}
+	if got.Margin != d.Margin || got.FontSizes.Header != d.FontSizes.Header || got.FontSizes.Total != d.FontSizes.Total {
+		t.Errorf("WithDefaults left zero fields unset: %+v", got)
+	}
+}
+
+func TestDevice(t *testing.T) {
+	l := DefaultLayout()
+	if got := l.device(); got.Width != l.Width || got.Margin != l.Margin || got.PriceGutter != DefaultPriceGutter ||
+		got.MinItemColumnWidth != DefaultMinItemColumnWidth {
+		t.Errorf("zero scale changed lengths: %+v", got)
+	}
+
+	l.Scale = 1.5
+	got := l.device()
+	if got.Width != 1050 || got.Margin != 38 || got.ItemSpacing != 12 || got.PriceGutter != 18 || got.MinItemColumnWidth != 120 {
+		t.Errorf("1.5x lengths = %+v", got)
+	}
+	if got.FontSizes != l.FontSizes {
+		t.Errorf("device changed font sizes to %+v; they are scaled by DPI", got.FontSizes)
+	}
+	if got.Divider.Thickness != l.Divider.thickness()*1.5 {
+		t.Errorf("divider thickness = %v", got.Divider.Thickness)
+	}
+}
+
+func TestFaceSetDPI(t *testing.T) {
+	for _, tc := range []struct {
+		scale float64
+		dpi   float64
+	}{
+		{0, 72},
+		{1, 72},
+		{2, 144},
+	} {
+		l := DefaultLayout()
+		l.Scale = tc.scale
+		fs, err := newFaceSet(nil, l.scale())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fs.dpi != tc.dpi {
+			t.Errorf("scale %v: DPI = %v, want %v", tc.scale, fs.dpi, tc.dpi)
+		}
+	}
+}
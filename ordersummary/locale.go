@@ -0,0 +1,355 @@
+package ordersummary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Plural categories used by the message catalog, following CLDR names
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// DefaultLocale is the locale used when a message is missing from the requested one
+const DefaultLocale = "en"
+
+// LocaleMessages defines the translated messages for a single locale. Message keys
+// are "header", "items", "count", "subtotal", "shipping", "taxes", "total" and "discount".
+// Keys listed in Plurals are chosen by item count and take precedence over Messages.
+type LocaleMessages struct {
+	Locale   string                       `json:"locale"`
+	Messages map[string]string            `json:"messages"`
+	Plurals  map[string]map[string]string `json:"plurals"`
+}
+
+// Catalog holds translated TextContent messages keyed by locale
+type Catalog struct {
+	mu      sync.RWMutex
+	locales map[string]LocaleMessages
+}
+
+// NewCatalog creates a catalog containing the built-in locales
+func NewCatalog() *Catalog {
+	c := &Catalog{locales: make(map[string]LocaleMessages)}
+	for _, m := range builtinLocales {
+		c.Add(m)
+	}
+	return c
+}
+
+// Add registers messages for a locale, replacing any existing messages for it
+func (c *Catalog) Add(m LocaleMessages) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.locales[normalizeLocale(m.Locale)] = m
+}
+
+// LoadJSON reads a LocaleMessages document from r and adds it to the catalog
+func (c *Catalog) LoadJSON(r io.Reader) error {
+	var m LocaleMessages
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return fmt.Errorf("decode locale: %w", err)
+	}
+	if m.Locale == "" {
+		return fmt.Errorf("decode locale: missing locale tag")
+	}
+	for key, s := range m.Messages {
+		if err := checkMessage(key, s); err != nil {
+			return fmt.Errorf("locale %s: %w", m.Locale, err)
+		}
+	}
+	for key, forms := range m.Plurals {
+		for _, s := range forms {
+			if err := checkMessage(key, s); err != nil {
+				return fmt.Errorf("locale %s: %w", m.Locale, err)
+			}
+		}
+	}
+	c.Add(m)
+	return nil
+}
+
+// LoadFile adds the locale defined in the JSON file at path to the catalog
+func (c *Catalog) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.LoadJSON(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Locales returns the tags of all locales in the catalog
+func (c *Catalog) Locales() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tags := make([]string, 0, len(c.locales))
+	for tag := range c.locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// TextContent builds the text content for locale. itemCount selects the plural form of
+// the item count and overrides replaces individual messages by key.
+// Messages missing from the locale fall back to its base language and then to DefaultLocale.
+func (c *Catalog) TextContent(locale string, itemCount int, overrides map[string]string) (TextContent, error) {
+	for key := range overrides {
+		if !isMessageKey(key) {
+			return TextContent{}, fmt.Errorf("unknown message key %q", key)
+		}
+	}
+
+	chain, err := c.fallbackChain(locale)
+	if err != nil {
+		return TextContent{}, err
+	}
+
+	var textContent TextContent
+	for _, field := range textFields {
+		if s, ok := overrides[field.key]; ok {
+			*field.ptr(&textContent) = s
+			continue
+		}
+		*field.ptr(&textContent) = lookupMessage(chain, field.key, itemCount)
+	}
+	return textContent, nil
+}
+
+func (c *Catalog) fallbackChain(locale string) ([]LocaleMessages, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tag := normalizeLocale(locale)
+	tags := []string{tag}
+	if base := baseLanguage(tag); base != tag {
+		tags = append(tags, base)
+	}
+
+	var chain []LocaleMessages
+	for _, t := range tags {
+		if m, ok := c.locales[t]; ok {
+			chain = append(chain, m)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("unknown locale %q", locale)
+	}
+	if m, ok := c.locales[DefaultLocale]; ok && baseLanguage(tag) != DefaultLocale {
+		chain = append(chain, m)
+	}
+	return chain, nil
+}
+
+func lookupMessage(chain []LocaleMessages, key string, count int) string {
+	for _, m := range chain {
+		if forms, ok := m.Plurals[key]; ok {
+			if s, ok := forms[pluralCategory(baseLanguage(normalizeLocale(m.Locale)), count)]; ok {
+				return s
+			}
+			if s, ok := forms[PluralOther]; ok {
+				return s
+			}
+		}
+		if s, ok := m.Messages[key]; ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// pluralCategory returns the CLDR plural category of n for a base language
+func pluralCategory(lang string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	switch lang {
+	case "ar":
+		switch mod := n % 100; {
+		case n == 0:
+			return PluralZero
+		case n == 1:
+			return PluralOne
+		case n == 2:
+			return PluralTwo
+		case mod >= 3 && mod <= 10:
+			return PluralFew
+		case mod >= 11:
+			return PluralMany
+		}
+		return PluralOther
+	case "hi":
+		if n == 0 || n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	default:
+		if n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}
+}
+
+// checkMessage reports an unknown key or a message that is not a valid template,
+// so a bad locale file fails when it is loaded rather than on the first render
+func checkMessage(key, s string) error {
+	if !isMessageKey(key) {
+		return fmt.Errorf("unknown message key %q", key)
+	}
+	if _, err := compileTextTemplate(key, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+func isMessageKey(key string) bool {
+	for _, field := range textFields {
+		if field.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeLocale(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+func baseLanguage(tag string) string {
+	if i := strings.IndexByte(tag, '-'); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+var (
+	defaultCatalogOnce sync.Once
+	defaultCatalog     *Catalog
+)
+
+// DefaultCatalog returns the shared catalog of built-in locales
+func DefaultCatalog() *Catalog {
+	defaultCatalogOnce.Do(func() {
+		defaultCatalog = NewCatalog()
+	})
+	return defaultCatalog
+}
+
+// TextContentForLocale builds the text content for locale from the default catalog
+func TextContentForLocale(locale string, itemCount int) (TextContent, error) {
+	return DefaultCatalog().TextContent(locale, itemCount, nil)
+}
+
+// builtinLocales are the translations shipped with the package. Item counts are
+// filled in by template expansion, so plural forms refer to {{len .Items}}.
+var builtinLocales = []LocaleMessages{
+	{
+		Locale: "en",
+		Messages: map[string]string{
+			"header":   "Order Summary",
+			"items":    "Items",
+			"subtotal": "Subtotal:",
+			"shipping": "Shipping:",
+			"taxes":    "Taxes:",
+			"total":    "Total:",
+			"discount": "Discount:",
+		},
+		Plurals: map[string]map[string]string{
+			"count": {
+				PluralOne:   "{{len .Items}} item",
+				PluralOther: "{{len .Items}} items",
+			},
+		},
+	},
+	{
+		Locale: "hi",
+		Messages: map[string]string{
+			"header":   "ऑर्डर सारांश",
+			"items":    "आइटम",
+			"subtotal": "उप-योग:",
+			"shipping": "शिपिंग:",
+			"taxes":    "कर:",
+			"total":    "कुल:",
+			"discount": "छूट:",
+		},
+		Plurals: map[string]map[string]string{
+			"count": {
+				PluralOne:   "{{len .Items}} आइटम",
+				PluralOther: "{{len .Items}} आइटम",
+			},
+		},
+	},
+	{
+		Locale: "ta",
+		Messages: map[string]string{
+			"header":   "ஆர்டர் சுருக்கம்",
+			"items":    "பொருட்கள்",
+			"subtotal": "துணைத் தொகை:",
+			"shipping": "அனுப்புதல் கட்டணம்:",
+			"taxes":    "வரிகள்:",
+			"total":    "மொத்தம்:",
+			"discount": "தள்ளுபடி:",
+		},
+		Plurals: map[string]map[string]string{
+			"count": {
+				PluralOne:   "{{len .Items}} பொருள்",
+				PluralOther: "{{len .Items}} பொருட்கள்",
+			},
+		},
+	},
+	{
+		Locale: "ar",
+		Messages: map[string]string{
+			"header":   "ملخص الطلب",
+			"items":    "المنتجات",
+			"subtotal": "المجموع الفرعي:",
+			"shipping": "الشحن:",
+			"taxes":    "الضرائب:",
+			"total":    "الإجمالي:",
+			"discount": "الخصم:",
+		},
+		Plurals: map[string]map[string]string{
+			"count": {
+				PluralZero:  "لا توجد منتجات",
+				PluralOne:   "منتج واحد",
+				PluralTwo:   "منتجان",
+				PluralFew:   "{{len .Items}} منتجات",
+				PluralMany:  "{{len .Items}} منتجًا",
+				PluralOther: "{{len .Items}} منتج",
+			},
+		},
+	},
+	{
+		Locale: "es",
+		Messages: map[string]string{
+			"header":   "Resumen del pedido",
+			"items":    "Artículos",
+			"subtotal": "Subtotal:",
+			"shipping": "Envío:",
+			"taxes":    "Impuestos:",
+			"total":    "Total:",
+			"discount": "Descuento:",
+		},
+		Plurals: map[string]map[string]string{
+			"count": {
+				PluralOne:   "{{len .Items}} artículo",
+				PluralOther: "{{len .Items}} artículos",
+			},
+		},
+	},
+}
@@ -0,0 +1,165 @@
+package ordersummary
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPluralCategory(t *testing.T) {
+	for _, tc := range []struct {
+		lang string
+		n    int
+		want string
+	}{
+		{"en", 0, PluralOther},
+		{"en", 1, PluralOne},
+		{"en", 2, PluralOther},
+		{"en", 21, PluralOther},
+		{"es", 1, PluralOne},
+		{"es", 5, PluralOther},
+		{"hi", 0, PluralOne},
+		{"hi", 1, PluralOne},
+		{"hi", 2, PluralOther},
+		{"ar", 0, PluralZero},
+		{"ar", 1, PluralOne},
+		{"ar", 2, PluralTwo},
+		{"ar", 3, PluralFew},
+		{"ar", 10, PluralFew},
+		{"ar", 11, PluralMany},
+		{"ar", 99, PluralMany},
+		{"ar", 100, PluralOther},
+		{"ar", 101, PluralOther},
+		{"ar", 102, PluralOther},
+		{"ar", 103, PluralFew},
+		{"ar", 111, PluralMany},
+		{"xx", 1, PluralOne},
+	} {
+		if got := pluralCategory(tc.lang, tc.n); got != tc.want {
+			t.Errorf("pluralCategory(%s, %d) = %s, want %s", tc.lang, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestTextContentPlurals(t *testing.T) {
+	for _, tc := range []struct {
+		locale string
+		n      int
+		want   string
+	}{
+		{"en", 1, "{{len .Items}} item"},
+		{"en", 3, "{{len .Items}} items"},
+		{"ar", 0, "لا توجد منتجات"},
+		{"ar", 1, "منتج واحد"},
+		{"ar", 2, "منتجان"},
+		{"ar", 3, "{{len .Items}} منتجات"},
+		{"ar", 11, "{{len .Items}} منتجًا"},
+		{"ar", 100, "{{len .Items}} منتج"},
+	} {
+		text, err := TextContentForLocale(tc.locale, tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text.ItemCountText != tc.want {
+			t.Errorf("%s with %d items: count = %q, want %q", tc.locale, tc.n, text.ItemCountText, tc.want)
+		}
+	}
+}
+
+func TestTextContentDefaultHeading(t *testing.T) {
+	for _, n := range []int{1, 2} {
+		text, err := TextContentForLocale(DefaultLocale, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text.ItemsText != "Items" {
+			t.Errorf("heading for %d items = %q, want Items", n, text.ItemsText)
+		}
+	}
+}
+
+func TestTextContentFallback(t *testing.T) {
+	c := NewCatalog()
+	c.Add(LocaleMessages{Locale: "ta-IN", Messages: map[string]string{"header": "சுருக்கம்"}})
+	c.Add(LocaleMessages{Locale: "ta", Messages: map[string]string{"header": "ஆர்டர் சுருக்கம்", "total": "மொத்தம்:"}})
+
+	text, err := c.TextContent("ta_IN", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.HeaderText != "சுருக்கம்" {
+		t.Errorf("header = %q, want the ta-IN message", text.HeaderText)
+	}
+	if text.TotalText != "மொத்தம்:" {
+		t.Errorf("total = %q, want the ta message", text.TotalText)
+	}
+	if text.DiscountText != "Discount:" {
+		t.Errorf("discount = %q, want the en message", text.DiscountText)
+	}
+
+	if _, err := c.TextContent("fr", 1, nil); err == nil {
+		t.Error("TextContent accepted an unknown locale")
+	}
+	if text, err := c.TextContent("es-MX", 1, nil); err != nil || text.HeaderText != "Resumen del pedido" {
+		t.Errorf("es-MX: got %q, %v, want the es messages", text.HeaderText, err)
+	}
+}
+
+func TestTextContentOverrides(t *testing.T) {
+	text, err := DefaultCatalog().TextContent("hi", 2, map[string]string{"header": "Your order", "count": "two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.HeaderText != "Your order" || text.ItemCountText != "two" {
+		t.Errorf("overrides not applied: header %q, count %q", text.HeaderText, text.ItemCountText)
+	}
+	if text.TotalText != "कुल:" {
+		t.Errorf("total = %q, want the hi message", text.TotalText)
+	}
+	if _, err := DefaultCatalog().TextContent("hi", 2, map[string]string{"heading": "x"}); err == nil {
+		t.Error("TextContent accepted an unknown override key")
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	c := NewCatalog()
+	err := c.LoadJSON(strings.NewReader(`{
+		"locale": "fr",
+		"messages": {"header": "Récapitulatif", "items": "Articles"},
+		"plurals": {"count": {"one": "{{len .Items}} article", "other": "{{len .Items}} articles"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := c.TextContent("fr", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.HeaderText != "Récapitulatif" || text.ItemCountText != "{{len .Items}} articles" || text.TotalText != "Total:" {
+		t.Errorf("got %+v", text)
+	}
+}
+
+func TestLoadJSONRejects(t *testing.T) {
+	for name, doc := range map[string]string{
+		"malformed":            `{"locale": "fr"`,
+		"no locale":            `{"messages": {"header": "Récapitulatif"}}`,
+		"unknown message key":  `{"locale": "fr", "messages": {"heading": "Récapitulatif"}}`,
+		"unknown plural key":   `{"locale": "fr", "plurals": {"headings": {"other": "x"}}}`,
+		"bad message template": `{"locale": "fr", "messages": {"header": "{{.OrderID"}}`,
+		"bad plural template":  `{"locale": "fr", "plurals": {"count": {"other": "{{nope .Items}}"}}}`,
+	} {
+		c := NewCatalog()
+		err := c.LoadJSON(strings.NewReader(doc))
+		if err == nil {
+			t.Errorf("%s: LoadJSON accepted %s", name, doc)
+			continue
+		}
+		if strings.HasPrefix(name, "bad") && !errors.Is(err, ErrTemplate) {
+			t.Errorf("%s: got %v, want ErrTemplate", name, err)
+		}
+		if len(c.Locales()) != len(builtinLocales) {
+			t.Errorf("%s: rejected locale was added", name)
+		}
+	}
+}
@@ -1,180 +1,106 @@
 package ordersummary
 
 import (
-	"fmt"
+	"context"
+	"image"
 	"image/color"
-	"log"
+	"io"
 	"os"
-	"strings"
+	"time"
 
 	"github.com/fogleman/gg"
-	"github.com/golang/freetype/truetype"
-	"golang.org/x/image/font/gofont/gobold"
-	"golang.org/x/image/font/gofont/goregular"
 )
 
+// ggTheme holds the colors used by the gg renderer
+var ggTheme = Theme{
+	Background: color.RGBA{245, 245, 245, 255},
+	Card:       color.RGBA{255, 255, 255, 255},
+	Text:       color.RGBA{0, 0, 0, 255},
+	Divider:    color.RGBA{220, 220, 220, 255},
+	Footer:     color.RGBA{128, 128, 128, 255},
+	Shadow:     color.RGBA{0, 0, 0, 40},
+}
+
 // GenerateOrderSummaryGG creates an image of the order summary using the gg package and writes it to the provided file
 func GenerateOrderSummaryGG(order OrderSummary, outputFile *os.File, layout Layout) error {
-	// Initialize the context
-	dc := gg.NewContext(layout.Width, calculateHeight(order, layout))
-
-	// Set background color
-	dc.SetColor(color.RGBA{245, 245, 245, 255})
-	dc.Clear()
-
-	// Draw main content area with rounded corners
-	dc.SetColor(color.White)
-	dc.DrawRoundedRectangle(float64(layout.Margin), float64(layout.Margin),
-		float64(layout.Width-2*layout.Margin), float64(dc.Height()-2*layout.Margin), 10)
-	dc.Fill()
-
-	// Load fonts
-	headerFont := loadFontGG(gobold.TTF, layout.FontSizes.Header)
-	itemFont := loadFontGG(goregular.TTF, layout.FontSizes.Item)
-	subheaderFont := loadFontGG(gobold.TTF, layout.FontSizes.Subheader)
-	totalFont := loadFontGG(gobold.TTF, layout.FontSizes.Total)
-
-	dc.SetColor(color.Black)
-
-	// Draw header
-	y := float64(layout.Margin + layout.HeaderHeight/2)
-	dc.SetFontFace(truetype.NewFace(headerFont, &truetype.Options{Size: layout.FontSizes.Header}))
-	dc.DrawStringAnchored("Order Summary", float64(layout.Width/2), y, 0.5, 0.5)
-
-	// Remove or comment out any SetColor calls here that might be changing the color
-
-	// Draw horizontal line
-	y += float64(layout.HeaderHeight / 2)
-	drawHorizontalLineGG(dc, layout.Margin*2, layout.Width-layout.Margin*2, int(y))
-
-	// Ensure color is set to black before drawing items
-	dc.SetColor(color.Black)
-
-	// Draw items
-	y += float64(layout.SectionSpacing)
-	dc.SetFontFace(truetype.NewFace(subheaderFont, &truetype.Options{Size: layout.FontSizes.Subheader}))
-	dc.DrawString("Items", float64(layout.Margin*2), y)
-
-	y += dc.FontHeight() + float64(layout.ItemSpacing)
-	dc.SetFontFace(truetype.NewFace(itemFont, &truetype.Options{Size: layout.FontSizes.Item}))
-
-	for _, item := range order.Items {
-		itemText := formatItem(item)
-		wrappedText := wrapTextGG(dc, itemText, float64(layout.Width-layout.Margin*5))
-
-		for i, line := range wrappedText {
-			dc.DrawString(line, float64(layout.Margin*3), y)
-
-			if i == 0 {
-				priceStr := fmt.Sprintf("%s %.2f", order.Currency, item.Price*float64(item.Quantity))
-				dc.DrawStringAnchored(priceStr, float64(layout.Width-layout.Margin*2), y, 1, 0)
-			}
-
-			y += dc.FontHeight()
-		}
-
-		y += float64(layout.ItemSpacing)
-	}
-
-	// Draw totals
-	y += float64(layout.SectionSpacing)
-	drawHorizontalLineGG(dc, layout.Margin*2, layout.Width-layout.Margin*2, int(y))
-	y += float64(layout.SectionSpacing)
-
-	dc.SetFontFace(truetype.NewFace(totalFont, &truetype.Options{Size: layout.FontSizes.Total}))
-	drawTotalLineGG(dc, "Subtotal:", order.Subtotal, y, layout, order.Currency)
-	y += dc.FontHeight() + float64(layout.SectionSpacing)
-	drawTotalLineGG(dc, "Shipping:", order.Shipping, y, layout, order.Currency)
-	y += dc.FontHeight() + float64(layout.SectionSpacing)
-	drawTotalLineGG(dc, "Taxes:", order.Taxes, y, layout, order.Currency)
-	y += dc.FontHeight() + float64(layout.SectionSpacing)
-	drawHorizontalLineGG(dc, layout.Margin*2, layout.Width-layout.Margin*2, int(y))
-	y += float64(layout.SectionSpacing)
-	drawTotalLineGG(dc, "Total:", order.Total, y, layout, order.Currency)
-
-	// Save the image
-	return dc.EncodePNG(outputFile)
+	start := time.Now()
+	ctx, span := startSpan(context.Background(), SpanRender, Attr{"backend", BackendGG}, Attr{"format", string(FormatPNG)}, Attr{"items", len(order.Items)})
+	cw := &countingWriter{w: outputFile}
+	err := generateGG(ctx, order, cw, layout)
+	span.SetAttributes(Attr{"bytes", cw.n})
+	span.End(err)
+	observe(BackendGG, FormatPNG, order, cw.n, start, err)
+	return err
 }
 
-func wrapTextGG(dc *gg.Context, text string, maxWidth float64) []string {
-	var lines []string
-	words := strings.Fields(text)
-	if len(words) == 0 {
-		return nil
+func generateGG(ctx context.Context, order OrderSummary, w io.Writer, layout Layout) error {
+	text, err := TextContentForLocale(DefaultLocale, len(order.Items))
+	if err != nil {
+		return err
 	}
-
-	var line string
-	for _, word := range words {
-		testLine := line
-		if testLine != "" {
-			testLine += " "
-		}
-		testLine += word
-
-		if width, _ := dc.MeasureString(testLine); width <= maxWidth {
-			line = testLine
-		} else {
-			lines = append(lines, line)
-			line = word
-		}
+	doc, faces, _, err := measure(ctx, order, Options{Layout: layout, Text: text})
+	if err != nil {
+		return err
 	}
 
-	if line != "" {
-		lines = append(lines, line)
+	_, span := startSpan(ctx, SpanDraw, Attr{"width", doc.Width}, Attr{"height", doc.Height})
+	if err := checkCanvas(doc.Width, doc.Height); err != nil {
+		span.End(err)
+		return err
 	}
+	dc := gg.NewContext(doc.Width, doc.Height)
+	doc.Root.Walk(func(b *Box) {
+		drawBoxGG(dc, b, ggTheme, faces)
+	})
+	span.End(nil)
 
-	return lines
-}
-
-func drawHorizontalLineGG(dc *gg.Context, x1, x2, y int) {
-	dc.SetColor(color.RGBA{220, 220, 220, 255})
-	dc.DrawLine(float64(x1), float64(y), float64(x2), float64(y))
-	dc.Stroke()
-	setTextColor(dc)
-}
-
-func setTextColor(dc *gg.Context) {
-	dc.SetColor(color.Black)
-}
-
-func drawTotalLineGG(dc *gg.Context, label string, value float64, y float64, layout Layout, currency string) {
-	dc.DrawString(label, float64(layout.Margin*3), y)
-	valueStr := fmt.Sprintf("%s %.2f", currency, value)
-	dc.DrawStringAnchored(valueStr, float64(layout.Width-layout.Margin*2), y, 1, 0)
-}
-
-func loadFontGG(fontData []byte, size float64) *truetype.Font {
-	f, err := truetype.Parse(fontData)
+	// Save the image
+	_, span = startSpan(ctx, SpanEncode, Attr{"format", string(FormatPNG)})
+	err = dc.EncodePNG(w)
 	if err != nil {
-		log.Fatalf("Failed to parse font: %v", err)
+		err = newError("encode png", ErrEncode, err)
 	}
-	return f
+	span.End(err)
+	return err
 }
 
-func calculateHeight(order OrderSummary, layout Layout) int {
-	height := layout.Margin * 2 // Top and bottom margins
-	height += layout.HeaderHeight
-	height += layout.SectionSpacing // Space after header
-
-	// Items section
-	height += int(layout.FontSizes.Subheader) // "Items" subheader
-	height += layout.ItemSpacing
-
-	// Calculate height for each item
-	dc := gg.NewContext(1, 1) // Temporary context for text measurements
-	itemFont := loadFontGG(goregular.TTF, layout.FontSizes.Item)
-	dc.SetFontFace(truetype.NewFace(itemFont, &truetype.Options{Size: layout.FontSizes.Item}))
-
-	for _, item := range order.Items {
-		itemText := formatItem(item)
-		wrappedText := wrapTextGG(dc, itemText, float64(layout.Width-layout.Margin*5))
-		height += (int(dc.FontHeight()) * len(wrappedText)) + layout.ItemSpacing
+// drawBoxGG draws a single box, leaving its children to the caller
+func drawBoxGG(dc *gg.Context, b *Box, theme Theme, faces *faceSet) {
+	r := b.Bounds
+	dc.SetColor(theme.Color(b.Paint))
+	switch b.Kind {
+	case BoxSection:
+		if b.Paint == PaintNone {
+			return
+		}
+		drawShadow(dc.Image().(*image.RGBA), r.Min.X, r.Min.Y, r.Max.X, r.Max.Y, b.Radius, b.Shadow, theme.Shadow)
+		dc.DrawRoundedRectangle(float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()), float64(b.Radius))
+		dc.Fill()
+	case BoxLine:
+		drawHorizontalLineGG(dc, r.Min.X, r.Max.X-1, r.Min.Y, b.Divider)
+	case BoxText:
+		dc.SetFontFace(faces.face(b.Size, b.Bold))
+		dc.DrawString(b.Text, float64(r.Min.X), float64(b.Baseline))
+	case BoxImage:
+		drawImage(dc.Image().(*image.RGBA), r, b.Image)
 	}
+}
 
-	// Totals section
-	height += layout.SectionSpacing * 2       // Space before and after horizontal line
-	height += int(layout.FontSizes.Total) * 4 // Four lines: Subtotal, Shipping, Taxes, Total
-	height += layout.SectionSpacing * 5       // Spacing between total lines
-
-	return height
+// drawHorizontalLineGG strokes a divider from x1 to x2 whose top edge is at y
+func drawHorizontalLineGG(dc *gg.Context, x1, x2, y int, d Divider) {
+	t := d.thickness()
+	dc.Push()
+	dc.SetLineWidth(t)
+	switch d.style() {
+	case DividerDashed:
+		dc.SetLineCapButt()
+		dc.SetDash(6*t, 4*t)
+	case DividerDotted:
+		// Round caps on near-zero dashes draw dots one line thick
+		dc.SetLineCapRound()
+		dc.SetDash(0.001, 2*t)
+	}
+	dc.DrawLine(float64(x1), float64(y)+t/2, float64(x2), float64(y)+t/2)
+	dc.Stroke()
+	dc.Pop()
 }
@@ -0,0 +1,104 @@
+package ordersummary
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Rendering backends reported in RenderStats
+const (
+	// BackendRaw is the package's own rasterizer behind Render and GenerateOrderSummary
+	BackendRaw = "raw"
+	// BackendGG is the gg renderer behind GenerateOrderSummaryGG
+	BackendGG = "gg"
+)
+
+// RenderStats describes one finished render
+type RenderStats struct {
+	Backend string
+	// Format is empty when the requested format was not recognized
+	Format Format
+	// Items is the number of items in the order
+	Items int
+	// Bytes is the size of the output written, including any partial output of a failed render
+	Bytes    int64
+	Duration time.Duration
+	// Err is the error the render returned, if any
+	Err error
+}
+
+// Observer receives the stats of every render, for metrics
+type Observer interface {
+	ObserveRender(RenderStats)
+}
+
+// ObserverFunc adapts a function to an Observer
+type ObserverFunc func(RenderStats)
+
+// ObserveRender calls f
+func (f ObserverFunc) ObserveRender(s RenderStats) { f(s) }
+
+var observer atomic.Pointer[Observer]
+
+// SetObserver installs o to receive the stats of every render in the process,
+// replacing any previous observer. A nil o stops reporting. Observers are called
+// synchronously by the rendering goroutine and must be safe for concurrent use.
+func SetObserver(o Observer) {
+	if o == nil {
+		observer.Store(nil)
+		return
+	}
+	observer.Store(&o)
+}
+
+// observe reports a render that started at start to the installed observer
+func observe(backend string, format Format, order OrderSummary, bytes int64, start time.Time, err error) {
+	o := observer.Load()
+	if o == nil {
+		return
+	}
+	(*o).ObserveRender(RenderStats{
+		Backend:  backend,
+		Format:   format,
+		Items:    len(order.Items),
+		Bytes:    bytes,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+// ErrorKind returns a short name for the sentinel an error wraps, such as
+// "font_load" or "canvas_too_large", for labelling metrics and logs. Errors that
+// wrap none of the package's sentinels are "other".
+func ErrorKind(err error) string {
+	for _, k := range []struct {
+		sentinel error
+		name     string
+	}{
+		{ErrFontLoad, "font_load"},
+		{ErrInvalidLayout, "invalid_layout"},
+		{ErrTemplate, "template"},
+		{ErrUnsupportedFormat, "unsupported_format"},
+		{ErrCanvasTooLarge, "canvas_too_large"},
+		{ErrEncode, "encode"},
+	} {
+		if errors.Is(err, k.sentinel) {
+			return k.name
+		}
+	}
+	return "other"
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
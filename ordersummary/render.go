@@ -0,0 +1,358 @@
+package ordersummary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format identifies the encoding of a rendered order summary
+type Format string
+
+// Supported output formats
+const (
+	FormatPNG  Format = "png"
+	FormatJPEG Format = "jpeg"
+	// FormatText writes the summary as plain text instead of an image
+	FormatText Format = "text"
+	// FormatHTML writes the summary as an HTML email with inline styles
+	FormatHTML Format = "html"
+)
+
+// DefaultJPEGQuality is used when Options.JPEGQuality is unset
+const DefaultJPEGQuality = 90
+
+// ParseFormat parses a format name or MIME type such as "png", "jpg", "image/jpeg",
+// "text/plain" or "text/html"
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "png", "image/png":
+		return FormatPNG, nil
+	case "jpeg", "jpg", "image/jpeg":
+		return FormatJPEG, nil
+	case "text", "txt", "text/plain":
+		return FormatText, nil
+	case "html", "htm", "text/html":
+		return FormatHTML, nil
+	}
+	return "", newError("parse format", ErrUnsupportedFormat, fmt.Errorf("%q", s))
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJPEG:
+		return "image/jpeg"
+	case FormatText:
+		return "text/plain; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	}
+	return "image/png"
+}
+
+// Extension returns the file extension of the format, including the leading dot
+func (f Format) Extension() string {
+	switch f {
+	case FormatJPEG:
+		return ".jpg"
+	case FormatText:
+		return ".txt"
+	case FormatHTML:
+		return ".html"
+	}
+	return ".png"
+}
+
+// Theme defines the colors used to draw the order summary. Colors are
+// premultiplied by their alpha, as color.RGBA requires.
+type Theme struct {
+	Background color.RGBA
+	Card       color.RGBA
+	Text       color.RGBA
+	Divider    color.RGBA
+	Footer     color.RGBA
+	// Shadow colors the card's shadow when Layout.Shadow is set
+	Shadow color.RGBA
+}
+
+var themes = map[string]Theme{
+	"light": {
+		Background: color.RGBA{245, 245, 245, 255},
+		Card:       color.RGBA{255, 255, 255, 255},
+		Text:       color.RGBA{60, 60, 60, 255},
+		Divider:    color.RGBA{220, 220, 220, 255},
+		Footer:     color.RGBA{128, 128, 128, 255},
+		Shadow:     color.RGBA{0, 0, 0, 40},
+	},
+	"dark": {
+		Background: color.RGBA{24, 24, 27, 255},
+		Card:       color.RGBA{39, 39, 42, 255},
+		Text:       color.RGBA{228, 228, 231, 255},
+		Divider:    color.RGBA{63, 63, 70, 255},
+		Footer:     color.RGBA{161, 161, 170, 255},
+		Shadow:     color.RGBA{0, 0, 0, 120},
+	},
+}
+
+// DefaultTheme returns the light theme
+func DefaultTheme() Theme {
+	return themes["light"]
+}
+
+// ThemeByName returns a built-in theme such as "light" or "dark"
+func ThemeByName(name string) (Theme, bool) {
+	t, ok := themes[strings.ToLower(name)]
+	return t, ok
+}
+
+// ThemeNames returns the names of the built-in themes
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type themeJSON struct {
+	Background string `json:"background,omitempty"`
+	Card       string `json:"card,omitempty"`
+	Text       string `json:"text,omitempty"`
+	Divider    string `json:"divider,omitempty"`
+	Footer     string `json:"footer,omitempty"`
+	Shadow     string `json:"shadow,omitempty"`
+}
+
+// MarshalJSON encodes the theme as an object of "#rrggbb" colors
+func (t Theme) MarshalJSON() ([]byte, error) {
+	return json.Marshal(themeJSON{
+		Background: formatHexColor(t.Background),
+		Card:       formatHexColor(t.Card),
+		Text:       formatHexColor(t.Text),
+		Divider:    formatHexColor(t.Divider),
+		Footer:     formatHexColor(t.Footer),
+		Shadow:     formatHexColor(t.Shadow),
+	})
+}
+
+// UnmarshalJSON accepts either the name of a built-in theme or an object of hex
+// colors. Colors missing from the object are taken from the default theme.
+func (t *Theme) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		theme, ok := ThemeByName(name)
+		if !ok {
+			return fmt.Errorf("unknown theme %q", name)
+		}
+		*t = theme
+		return nil
+	}
+
+	var v themeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	theme := DefaultTheme()
+	for _, c := range []struct {
+		hex string
+		dst *color.RGBA
+	}{
+		{v.Background, &theme.Background},
+		{v.Card, &theme.Card},
+		{v.Text, &theme.Text},
+		{v.Divider, &theme.Divider},
+		{v.Footer, &theme.Footer},
+		{v.Shadow, &theme.Shadow},
+	} {
+		if c.hex == "" {
+			continue
+		}
+		parsed, err := ParseHexColor(c.hex)
+		if err != nil {
+			return err
+		}
+		*c.dst = parsed
+	}
+	*t = theme
+	return nil
+}
+
+// ParseHexColor parses a "#rgb", "#rrggbb" or "#rrggbbaa" color. Hex colors are
+// not premultiplied, so a translucent color is premultiplied by its alpha.
+func ParseHexColor(s string) (color.RGBA, error) {
+	c := color.NRGBA{A: 255}
+	hex := strings.TrimPrefix(s, "#")
+	var err error
+	switch len(hex) {
+	case 3:
+		_, err = fmt.Sscanf(hex, "%1x%1x%1x", &c.R, &c.G, &c.B)
+		c.R, c.G, c.B = c.R*17, c.G*17, c.B*17
+	case 6:
+		_, err = fmt.Sscanf(hex, "%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 8:
+		_, err = fmt.Sscanf(hex, "%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	default:
+		err = fmt.Errorf("wrong length")
+	}
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: %v", s, err)
+	}
+	return color.RGBAModel.Convert(c).(color.RGBA), nil
+}
+
+// formatHexColor formats a premultiplied color as the hex color ParseHexColor reads
+func formatHexColor(c color.RGBA) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+// Options configures how an order summary is rendered
+type Options struct {
+	Layout      Layout
+	Text        TextContent
+	Footer      string
+	Theme       Theme
+	Format      Format
+	JPEGQuality int
+	// Fonts shares parsed fonts between renders; a package-wide cache is used when nil
+	Fonts *FontCache
+	// Language selects the hyphenator registered with RegisterHyphenator for item names
+	Language string
+	// Logo is drawn centered above the header when set
+	Logo image.Image
+	// AltText fills Result.AltText with a one-line description of the order that
+	// gives the first few item names in full, including names cut short by MaxItemLines
+	AltText bool
+	// HitMap fills Result.HitMap with the regions of the item rows, totals and
+	// images; text and HTML renders have none
+	HitMap bool
+}
+
+// Result describes a rendered order summary image
+type Result struct {
+	// Width and Height are zero for text and HTML renders
+	Width  int
+	Height int
+	Format Format
+	// Bytes is the size of the output written to w
+	Bytes int64
+	// TruncatedItems holds the indexes of items whose names were cut short with an ellipsis
+	TruncatedItems []int
+	// AltText gives the header, item count, first few item names and total when
+	// Options.AltText is set
+	AltText string
+	// HitMap locates the item rows, totals and images when Options.HitMap is set
+	HitMap *HitMap
+}
+
+// Render draws the order summary and writes it to w in the requested format.
+// FormatText and FormatHTML write the same summary as plain text or an HTML email
+// instead of drawing it.
+// A zero Theme uses DefaultTheme and an empty Format uses PNG.
+// Failures are returned as *Error wrapping one of the package's sentinel errors.
+func Render(order OrderSummary, w io.Writer, opts Options) (*Result, error) {
+	return RenderContext(context.Background(), order, w, opts)
+}
+
+// RenderContext is Render with a context carrying the parent of the render's
+// trace spans
+func RenderContext(ctx context.Context, order OrderSummary, w io.Writer, opts Options) (*Result, error) {
+	start := time.Now()
+	format, _ := ParseFormat(string(opts.Format))
+	ctx, span := startSpan(ctx, SpanRender, Attr{"backend", BackendRaw}, Attr{"format", string(format)}, Attr{"items", len(order.Items)})
+	cw := &countingWriter{w: w}
+	res, err := render(ctx, order, cw, opts)
+	if res != nil {
+		res.Bytes = cw.n
+	}
+	span.SetAttributes(Attr{"bytes", cw.n})
+	span.End(err)
+	observe(BackendRaw, format, order, cw.n, start, err)
+	return res, err
+}
+
+func render(ctx context.Context, order OrderSummary, w io.Writer, opts Options) (*Result, error) {
+	format, err := ParseFormat(string(opts.Format))
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatText {
+		return renderText(order, w, opts)
+	}
+	theme := opts.Theme
+	if theme == (Theme{}) {
+		theme = DefaultTheme()
+	}
+	if format == FormatHTML {
+		return renderHTML(order, w, opts, theme)
+	}
+
+	doc, faces, textContent, err := measure(ctx, order, opts)
+	if err != nil {
+		return nil, err
+	}
+	_, span := startSpan(ctx, SpanDraw, Attr{"width", doc.Width}, Attr{"height", doc.Height})
+	img, err := drawDocument(doc, theme, faces)
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+	_, span = startSpan(ctx, SpanEncode, Attr{"format", string(format)})
+	err = encodeImage(w, img, format, opts.JPEGQuality)
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{
+		Width:          doc.Width,
+		Height:         doc.Height,
+		Format:         format,
+		TruncatedItems: doc.TruncatedItems,
+	}
+	if opts.AltText {
+		res.AltText = altText(order, textContent)
+	}
+	if opts.HitMap {
+		res.HitMap = doc.HitMap(order)
+	}
+	return res, nil
+}
+
+func encodeImage(w io.Writer, img image.Image, format Format, quality int) error {
+	var err error
+	switch format {
+	case FormatJPEG:
+		if quality <= 0 {
+			quality = DefaultJPEGQuality
+		}
+		err = jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	default:
+		err = png.Encode(w, img)
+	}
+	if err != nil {
+		return newError("encode "+string(format), ErrEncode, err)
+	}
+	return nil
+}
+
+// checkCanvas rejects images that would exceed MaxCanvasPixels
+func checkCanvas(width, height int) error {
+	if int64(width)*int64(height) > MaxCanvasPixels {
+		return newError("allocate canvas", ErrCanvasTooLarge, fmt.Errorf("%dx%d exceeds %d pixels", width, height, MaxCanvasPixels))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package ordersummary
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want color.RGBA
+	}{
+		{"#fff", color.RGBA{255, 255, 255, 255}},
+		{"#3c3c3c", color.RGBA{60, 60, 60, 255}},
+		{"ff000080", color.RGBA{128, 0, 0, 128}},
+		{"#00000028", color.RGBA{0, 0, 0, 40}},
+		{"#ffffff00", color.RGBA{}},
+	} {
+		got, err := ParseHexColor(tt.in)
+		if err != nil {
+			t.Errorf("ParseHexColor(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseHexColor(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+		if got.R > got.A || got.G > got.A || got.B > got.A {
+			t.Errorf("ParseHexColor(%q) = %v is not premultiplied", tt.in, got)
+		}
+	}
+	for _, in := range []string{"", "#12", "#12345", "#gggggg"} {
+		if _, err := ParseHexColor(in); err == nil {
+			t.Errorf("ParseHexColor(%q) succeeded", in)
+		}
+	}
+}
+
+func TestThemeJSONRoundTrip(t *testing.T) {
+	var theme Theme
+	if err := json.Unmarshal([]byte(`{"text":"#ff000080","shadow":"#00000078"}`), &theme); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(theme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["text"] != "#ff000080" || fields["shadow"] != "#00000078" {
+		t.Errorf("got %s, want the colors as given", data)
+	}
+}
+
+func TestCSSColorIsNotPremultiplied(t *testing.T) {
+	c, err := ParseHexColor("#ff000080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(cssColor(c)), "rgba(255,0,0,0.502)"; got != want {
+		t.Errorf("cssColor = %q, want %q", got, want)
+	}
+}
+
+func TestScaleDoublesSize(t *testing.T) {
+	order := testOrder()
+	order.Items[1].Name = "Hand-painted ceramic planter with drainage tray, saucer and a matching bamboo stand"
+	render := func(scale float64) (*Result, image.Image) {
+		layout := DefaultLayout()
+		layout.Scale = scale
+		var buf bytes.Buffer
+		res, err := Render(order, &buf, Options{Layout: layout, Text: plainText, Format: FormatPNG})
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, img
+	}
+
+	res1, img1 := render(1)
+	res2, img2 := render(2)
+	if res2.Width != 2*res1.Width || res2.Height != 2*res1.Height {
+		t.Errorf("2x render is %dx%d, want twice %dx%d", res2.Width, res2.Height, res1.Width, res1.Height)
+	}
+	for _, c := range []struct {
+		res *Result
+		img image.Image
+	}{{res1, img1}, {res2, img2}} {
+		if size := c.img.Bounds().Size(); size.X != c.res.Width || size.Y != c.res.Height {
+			t.Errorf("image is %v, Result says %dx%d", size, c.res.Width, c.res.Height)
+		}
+	}
+	if res1.Width*res2.Height != res2.Width*res1.Height {
+		t.Errorf("aspect ratio changed from %dx%d to %dx%d", res1.Width, res1.Height, res2.Width, res2.Height)
+	}
+}
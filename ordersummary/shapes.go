@@ -0,0 +1,367 @@
+package ordersummary
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// DividerStyle is the pattern used to draw dividers
+type DividerStyle string
+
+// Divider styles
+const (
+	DividerSolid  DividerStyle = "solid"
+	DividerDashed DividerStyle = "dashed"
+	DividerDotted DividerStyle = "dotted"
+)
+
+// Divider configures the lines drawn between sections
+type Divider struct {
+	// Style is solid when empty
+	Style DividerStyle `json:"style,omitempty"`
+	// Thickness is the line thickness in pixels, 1 when zero
+	Thickness float64 `json:"thickness,omitempty"`
+}
+
+// Shadow configures the soft shadow drawn under the card. It is drawn only when
+// Offset or Blur is set.
+type Shadow struct {
+	// Offset moves the shadow down by this many pixels
+	Offset int `json:"offset,omitempty"`
+	// Blur is the distance, in pixels, over which the shadow fades out
+	Blur int `json:"blur,omitempty"`
+}
+
+func (d Divider) style() DividerStyle {
+	if d.Style == "" {
+		return DividerSolid
+	}
+	return d.Style
+}
+
+func (d Divider) thickness() float64 {
+	if d.Thickness == 0 {
+		return 1
+	}
+	return d.Thickness
+}
+
+// height returns the whole number of pixels the divider takes up vertically
+func (d Divider) height() int {
+	return int(math.Ceil(d.thickness()))
+}
+
+func (d Divider) validate() error {
+	switch d.style() {
+	case DividerSolid, DividerDashed, DividerDotted:
+	default:
+		return fmt.Errorf("unknown divider style %q", d.Style)
+	}
+	if math.IsNaN(d.Thickness) || math.IsInf(d.Thickness, 0) || d.Thickness < 0 {
+		return fmt.Errorf("divider thickness must not be negative, got %v", d.Thickness)
+	}
+	return nil
+}
+
+func (s Shadow) enabled() bool {
+	return s.Offset != 0 || s.Blur > 0
+}
+
+// kappa places cubic Bézier control points to approximate a quarter circle
+const kappa = 0.5522847498
+
+// shape accumulates anti-aliased paths within a region of the destination image.
+// Paths are given in image coordinates.
+type shape struct {
+	r      *vector.Rasterizer
+	bounds image.Rectangle
+}
+
+func newShape(bounds image.Rectangle) *shape {
+	return &shape{r: vector.NewRasterizer(bounds.Dx(), bounds.Dy()), bounds: bounds}
+}
+
+func (s *shape) moveTo(x, y float32) {
+	s.r.MoveTo(x-float32(s.bounds.Min.X), y-float32(s.bounds.Min.Y))
+}
+
+func (s *shape) lineTo(x, y float32) {
+	s.r.LineTo(x-float32(s.bounds.Min.X), y-float32(s.bounds.Min.Y))
+}
+
+func (s *shape) cubeTo(x1, y1, x2, y2, x, y float32) {
+	ox, oy := float32(s.bounds.Min.X), float32(s.bounds.Min.Y)
+	s.r.CubeTo(x1-ox, y1-oy, x2-ox, y2-oy, x-ox, y-oy)
+}
+
+func (s *shape) rect(x0, y0, x1, y1 float32) {
+	s.moveTo(x0, y0)
+	s.lineTo(x1, y0)
+	s.lineTo(x1, y1)
+	s.lineTo(x0, y1)
+	s.r.ClosePath()
+}
+
+func (s *shape) roundedRect(x0, y0, x1, y1, radius float32) {
+	radius = min(radius, (x1-x0)/2, (y1-y0)/2)
+	if radius <= 0 {
+		s.rect(x0, y0, x1, y1)
+		return
+	}
+	c := radius * (1 - kappa)
+	s.moveTo(x0+radius, y0)
+	s.lineTo(x1-radius, y0)
+	s.cubeTo(x1-c, y0, x1, y0+c, x1, y0+radius)
+	s.lineTo(x1, y1-radius)
+	s.cubeTo(x1, y1-c, x1-c, y1, x1-radius, y1)
+	s.lineTo(x0+radius, y1)
+	s.cubeTo(x0+c, y1, x0, y1-c, x0, y1-radius)
+	s.lineTo(x0, y0+radius)
+	s.cubeTo(x0, y0+c, x0+c, y0, x0+radius, y0)
+	s.r.ClosePath()
+}
+
+func (s *shape) circle(cx, cy, radius float32) {
+	s.roundedRect(cx-radius, cy-radius, cx+radius, cy+radius, radius)
+}
+
+// fill composites the accumulated paths over dst in color c
+func (s *shape) fill(dst draw.Image, c color.Color) {
+	draw.DrawMask(dst, s.bounds, image.NewUniform(c), image.Point{}, s.mask(), s.bounds.Min, draw.Over)
+}
+
+// mask returns the coverage of the accumulated paths
+func (s *shape) mask() *image.Alpha {
+	m := image.NewAlpha(s.bounds)
+	s.r.DrawOp = draw.Src
+	s.r.Draw(m, s.bounds, image.Opaque, image.Point{})
+	return m
+}
+
+// fillRect composites c over r, writing spans straight into img.Pix
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	if r.Empty() || c.A == 0 {
+		return
+	}
+	// A valid premultiplied color has no channel above its alpha; clamp one that
+	// does so the blend below cannot overflow
+	c.R, c.G, c.B = min(c.R, c.A), min(c.G, c.A), min(c.B, c.A)
+	n := r.Dx() * 4
+	if c.A == 0xff {
+		// Fill the first row, then copy it to the others
+		start := img.PixOffset(r.Min.X, r.Min.Y)
+		row := img.Pix[start : start+n]
+		for i := 0; i < n; i += 4 {
+			row[i], row[i+1], row[i+2], row[i+3] = c.R, c.G, c.B, c.A
+		}
+		for y := r.Min.Y + 1; y < r.Max.Y; y++ {
+			start := img.PixOffset(r.Min.X, y)
+			copy(img.Pix[start:start+n], row)
+		}
+		return
+	}
+	// Colors are premultiplied, so Over is src + dst*(1-srcAlpha)
+	a := 0xff - uint32(c.A)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		start := img.PixOffset(r.Min.X, y)
+		row := img.Pix[start : start+n]
+		for i := 0; i < n; i += 4 {
+			row[i] = c.R + uint8(uint32(row[i])*a/0xff)
+			row[i+1] = c.G + uint8(uint32(row[i+1])*a/0xff)
+			row[i+2] = c.B + uint8(uint32(row[i+2])*a/0xff)
+			row[i+3] = c.A + uint8(uint32(row[i+3])*a/0xff)
+		}
+	}
+}
+
+// scaleAlpha returns the premultiplied color c with its coverage reduced to f
+func scaleAlpha(c color.RGBA, f float64) color.RGBA {
+	return color.RGBA{
+		R: uint8(float64(c.R) * f),
+		G: uint8(float64(c.G) * f),
+		B: uint8(float64(c.B) * f),
+		A: uint8(float64(c.A) * f),
+	}
+}
+
+// drawRoundedRect fills an anti-aliased rectangle with rounded corners. Only the
+// corners are rasterized; the rest is filled a row at a time.
+func drawRoundedRect(img *image.RGBA, x0, y0, x1, y1, radius int, c color.RGBA) {
+	radius = max(min(radius, (x1-x0)/2, (y1-y0)/2), 0)
+	fillRect(img, image.Rect(x0+radius, y0, x1-radius, y0+radius), c)
+	fillRect(img, image.Rect(x0, y0+radius, x1, y1-radius), c)
+	fillRect(img, image.Rect(x0+radius, y1-radius, x1-radius, y1), c)
+	if radius == 0 {
+		return
+	}
+	drawCorner(img, x0, y0, 1, 1, radius, c)
+	drawCorner(img, x1, y0, -1, 1, radius, c)
+	drawCorner(img, x0, y1, 1, -1, radius, c)
+	drawCorner(img, x1, y1, -1, -1, radius, c)
+}
+
+// drawCorner fills the quarter circle of the given radius rounding off the corner
+// at (x, y); dx and dy point from the corner into the rectangle
+func drawCorner(img *image.RGBA, x, y, dx, dy, radius int, c color.RGBA) {
+	b := image.Rect(x, y, x+dx*radius, y+dy*radius).Canon().Intersect(img.Bounds())
+	if b.Empty() {
+		return
+	}
+	s := newShape(b)
+	fx, fy := float32(x), float32(y)
+	r, k := float32(radius), float32(radius)*(1-kappa)
+	sx, sy := float32(dx), float32(dy)
+	s.moveTo(fx+sx*r, fy)
+	s.cubeTo(fx+sx*k, fy, fx, fy+sy*k, fx, fy+sy*r)
+	s.lineTo(fx+sx*r, fy+sy*r)
+	s.r.ClosePath()
+	s.fill(img, c)
+}
+
+// drawDivider draws a horizontal divider from x0 to x1 whose top edge is at y
+func drawDivider(img *image.RGBA, x0, x1, y int, d Divider, c color.RGBA) {
+	t := float32(d.thickness())
+	if d.style() == DividerSolid {
+		// Whole rows are filled directly and a fractional last row is blended
+		rows := int(t)
+		fillRect(img, image.Rect(x0, y, x1+1, y+rows), c)
+		if frac := float64(t) - float64(rows); frac > 0 {
+			fillRect(img, image.Rect(x0, y+rows, x1+1, y+rows+1), scaleAlpha(c, frac))
+		}
+		return
+	}
+
+	b := image.Rect(x0, y, x1+1, y+d.height()).Intersect(img.Bounds())
+	if b.Empty() {
+		return
+	}
+	s := newShape(b)
+	left, right, top := float32(x0), float32(x1+1), float32(y)
+	if d.style() == DividerDashed {
+		dash, gap := 6*t, 4*t
+		for x := left; x < right; x += dash + gap {
+			s.rect(x, top, min(x+dash, right), top+t)
+		}
+	} else {
+		for x := left + t/2; x+t/2 <= right; x += 2 * t {
+			s.circle(x, top+t/2, t/2)
+		}
+	}
+	s.fill(img, c)
+}
+
+// drawShadow draws a blurred copy of the rounded rectangle, moved down by the
+// shadow's offset, for the card to be drawn over
+func drawShadow(img *image.RGBA, x0, y0, x1, y1, radius int, sh Shadow, c color.RGBA) {
+	if !sh.enabled() {
+		return
+	}
+	pad := blurExtent(sh.Blur)
+	y0, y1 = y0+sh.Offset, y1+sh.Offset
+	m := shadowMask(x1-x0, y1-y0, radius, sh.Blur)
+	m.Rect = m.Rect.Add(image.Pt(x0-pad, y0-pad))
+	b := m.Rect.Intersect(img.Bounds())
+	if b.Empty() {
+		return
+	}
+	draw.DrawMask(img, b, image.NewUniform(c), image.Point{}, m, b.Min, draw.Over)
+}
+
+// shadowMask returns the blurred coverage of a w×h rounded rectangle, padded by
+// the blur's extent on every side. Away from the corners each row and column of
+// the blurred edge is the same, so only a small rectangle is blurred and its
+// middle row and column are stretched to the full size.
+func shadowMask(w, h, radius, blur int) *image.Alpha {
+	pad := blurExtent(blur)
+	radius = max(min(radius, w/2, h/2), 0)
+	// edge is the distance from the mask's border past which nothing changes
+	edge := 2*pad + radius
+	mw, mh := min(w+2*pad, 2*edge+1), min(h+2*pad, 2*edge+1)
+
+	small := newShape(image.Rect(0, 0, mw, mh))
+	small.roundedRect(float32(pad), float32(pad), float32(mw-pad), float32(mh-pad), float32(radius))
+	sm := small.mask()
+	blurAlpha(sm, blur)
+	if mw == w+2*pad && mh == h+2*pad {
+		return sm
+	}
+
+	m := image.NewAlpha(image.Rect(0, 0, w+2*pad, h+2*pad))
+	fw, fh := m.Rect.Dx(), m.Rect.Dy()
+	for y := range fh {
+		sy := y
+		if y >= mh/2 {
+			sy = max(mh/2, mh-(fh-y))
+		}
+		src := sm.Pix[sy*sm.Stride : sy*sm.Stride+mw]
+		row := m.Pix[y*m.Stride : y*m.Stride+fw]
+		if mw == fw {
+			copy(row, src)
+			continue
+		}
+		half := mw / 2
+		copy(row, src[:half])
+		mid := src[half]
+		for x := half; x < fw-half; x++ {
+			row[x] = mid
+		}
+		copy(row[fw-half:], src[mw-half:])
+	}
+	return m
+}
+
+// blurExtent is how far blurAlpha spreads coverage for a blur radius
+func blurExtent(radius int) int {
+	return 3 * boxRadius(radius)
+}
+
+func boxRadius(radius int) int {
+	return max((radius+2)/3, 0)
+}
+
+// blurAlpha approximates a Gaussian blur extending about radius pixels with three
+// passes of a box blur in each direction
+func blurAlpha(m *image.Alpha, radius int) {
+	box := boxRadius(radius)
+	if box <= 0 {
+		return
+	}
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	buf := make([]uint8, max(w, h))
+	for range 3 {
+		for y := range h {
+			boxBlur(m.Pix[y*m.Stride:], 1, w, box, buf)
+		}
+		for x := range w {
+			boxBlur(m.Pix[x:], m.Stride, h, box, buf)
+		}
+	}
+}
+
+// boxBlur averages each of the n values spaced stride apart in pix with its
+// neighbours within radius, treating values beyond either end as zero
+func boxBlur(pix []uint8, stride, n, radius int, buf []uint8) {
+	for i := range n {
+		buf[i] = pix[i*stride]
+	}
+	window := 2*radius + 1
+	sum := 0
+	for j := 0; j <= radius && j < n; j++ {
+		sum += int(buf[j])
+	}
+	for i := range n {
+		pix[i*stride] = uint8(sum / window)
+		if j := i + radius + 1; j < n {
+			sum += int(buf[j])
+		}
+		if j := i - radius; j >= 0 {
+			sum -= int(buf[j])
+		}
+	}
+}
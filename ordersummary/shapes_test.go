@@ -0,0 +1,65 @@
+package ordersummary
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func whiteImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	return img
+}
+
+func TestFillRectBlendsOverWhite(t *testing.T) {
+	translucent, err := ParseHexColor("#ff000080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		c    color.RGBA
+	}{
+		{"premultiplied", translucent},
+		// Channels above alpha are not valid premultiplied data, but must not wrap
+		{"not premultiplied", color.RGBA{255, 0, 0, 128}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			img := whiteImage(4, 4)
+			fillRect(img, image.Rect(1, 1, 3, 3), tt.c)
+
+			want := color.RGBA{255, 127, 127, 255}
+			if got := img.RGBAAt(1, 1); got != want {
+				t.Errorf("blended pixel is %v, want %v", got, want)
+			}
+			if got := img.RGBAAt(0, 0); got != (color.RGBA{255, 255, 255, 255}) {
+				t.Errorf("pixel outside the rectangle is %v", got)
+			}
+		})
+	}
+}
+
+// TestFillRectMatchesDraw compares the direct Pix fill with draw.Over
+func TestFillRectMatchesDraw(t *testing.T) {
+	for _, c := range []color.RGBA{
+		{60, 60, 60, 255},
+		{0, 0, 0, 40},
+		{100, 50, 0, 200},
+		{0, 0, 0, 0},
+	} {
+		got := whiteImage(3, 3)
+		fillRect(got, got.Bounds(), c)
+		want := whiteImage(3, 3)
+		draw.Draw(want, want.Bounds(), image.NewUniform(c), image.Point{}, draw.Over)
+
+		g, w := got.RGBAAt(1, 1), want.RGBAAt(1, 1)
+		for _, d := range []int{int(g.R) - int(w.R), int(g.G) - int(w.G), int(g.B) - int(w.B), int(g.A) - int(w.A)} {
+			if d < -1 || d > 1 {
+				t.Errorf("fill with %v gives %v, draw.Over gives %v", c, g, w)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ordersummary
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TextTemplates holds the compiled templates for every TextContent field and the footer
+type TextTemplates struct {
+	fields [len(textFields)]*template.Template
+	footer *template.Template
+}
+
+// textFields lists the TextContent fields that may contain template expressions
+// and the message catalog key each one is translated from
+var textFields = [...]struct {
+	name string
+	key  string
+	ptr  func(*TextContent) *string
+}{
+	{"HeaderText", "header", func(t *TextContent) *string { return &t.HeaderText }},
+	{"ItemsText", "items", func(t *TextContent) *string { return &t.ItemsText }},
+	{"ItemCountText", "count", func(t *TextContent) *string { return &t.ItemCountText }},
+	{"SubtotalText", "subtotal", func(t *TextContent) *string { return &t.SubtotalText }},
+	{"ShippingText", "shipping", func(t *TextContent) *string { return &t.ShippingText }},
+	{"TaxesText", "taxes", func(t *TextContent) *string { return &t.TaxesText }},
+	{"TotalText", "total", func(t *TextContent) *string { return &t.TotalText }},
+	{"DiscountText", "discount", func(t *TextContent) *string { return &t.DiscountText }},
+}
+
+// templateFuncs returns the functions available to label templates. Only
+// formatting helpers are exposed; money uses the currency of the order being rendered.
+func templateFuncs(order OrderSummary) template.FuncMap {
+	return template.FuncMap{
+		"money": func(value float64) string {
+			return formatMoney(order.Currency, value)
+		},
+		"moneyIn": formatMoney,
+		"date": func(t time.Time, layout string) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(layout)
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}
+}
+
+// CompileTextContent parses every field of textContent and the footer as a text/template.
+// Syntax errors and unknown functions are reported here; references that depend on
+// the order, such as unknown fields or out-of-range indexes, are reported by Execute.
+func CompileTextContent(textContent TextContent, footer string) (*TextTemplates, error) {
+	var tt TextTemplates
+	for i, field := range textFields {
+		t, err := compileTextTemplate(field.name, *field.ptr(&textContent))
+		if err != nil {
+			return nil, err
+		}
+		tt.fields[i] = t
+	}
+
+	t, err := compileTextTemplate("Footer", footer)
+	if err != nil {
+		return nil, err
+	}
+	tt.footer = t
+	return &tt, nil
+}
+
+func compileTextTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(templateFuncs(OrderSummary{})).
+		Parse(text)
+	if err != nil {
+		return nil, newError("parse "+name+" template", ErrTemplate, err)
+	}
+	return t, nil
+}
+
+// Execute evaluates the templates against order and returns the expanded text content and footer
+func (tt *TextTemplates) Execute(order OrderSummary) (TextContent, string, error) {
+	funcs := templateFuncs(order)
+
+	var textContent TextContent
+	for i, field := range textFields {
+		s, err := executeTextTemplate(tt.fields[i], funcs, order)
+		if err != nil {
+			return TextContent{}, "", err
+		}
+		*field.ptr(&textContent) = s
+	}
+
+	footer, err := executeTextTemplate(tt.footer, funcs, order)
+	if err != nil {
+		return TextContent{}, "", err
+	}
+	return textContent, footer, nil
+}
+
+func executeTextTemplate(t *template.Template, funcs template.FuncMap, order OrderSummary) (string, error) {
+	// Rebind the functions on a clone so the compiled template can be shared
+	t, err := t.Clone()
+	if err != nil {
+		return "", newError("clone "+t.Name()+" template", ErrTemplate, err)
+	}
+
+	var sb strings.Builder
+	if err := t.Funcs(funcs).Execute(&sb, order); err != nil {
+		return "", newError("execute "+t.Name()+" template", ErrTemplate, err)
+	}
+	return sb.String(), nil
+}
+
+// ExpandTextContent compiles and executes the templates in textContent and footer against order.
+// Fields without template actions are returned unchanged.
+func ExpandTextContent(order OrderSummary, textContent TextContent, footer string) (TextContent, string, error) {
+	if !hasTemplateActions(textContent, footer) {
+		return textContent, footer, nil
+	}
+
+	tt, err := CompileTextContent(textContent, footer)
+	if err != nil {
+		return TextContent{}, "", err
+	}
+	return tt.Execute(order)
+}
+
+func hasTemplateActions(textContent TextContent, footer string) bool {
+	if strings.Contains(footer, "{{") {
+		return true
+	}
+	for _, field := range textFields {
+		if strings.Contains(*field.ptr(&textContent), "{{") {
+			return true
+		}
+	}
+	return false
+}
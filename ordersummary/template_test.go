@@ -0,0 +1,43 @@
+package ordersummary
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandTextContentIndexesLargerOrders(t *testing.T) {
+	order := OrderSummary{
+		Items:    []Item{{Name: "Orchid", Quantity: 1}, {Name: "Pot", Quantity: 2}},
+		Currency: "INR",
+	}
+	text, footer, err := ExpandTextContent(order, TextContent{HeaderText: "{{(index .Items 1).Name}}"}, "{{len .Items}} items")
+	if err != nil {
+		t.Fatalf("ExpandTextContent: %v", err)
+	}
+	if text.HeaderText != "Pot" || footer != "2 items" {
+		t.Errorf("got header %q, footer %q; want %q, %q", text.HeaderText, footer, "Pot", "2 items")
+	}
+}
+
+func TestExpandTextContentErrors(t *testing.T) {
+	order := OrderSummary{Items: []Item{{Name: "Orchid", Quantity: 1}}}
+	for name, text := range map[string]string{
+		"syntax":          "{{.Items",
+		"unknown func":    "{{nope .Items}}",
+		"unknown field":   "{{.Nope}}",
+		"index too large": "{{index .Items 1}}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ExpandTextContent(order, TextContent{HeaderText: text}, "")
+			if !errors.Is(err, ErrTemplate) {
+				t.Errorf("got error %v, want ErrTemplate", err)
+			}
+		})
+	}
+}
+
+func TestCompileTextContentAllowsIndexes(t *testing.T) {
+	if _, err := CompileTextContent(TextContent{ItemsText: "{{index .Items 3}}"}, ""); err != nil {
+		t.Errorf("CompileTextContent rejected a template valid for larger orders: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package ordersummary
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PlainText returns the order summary as plain text, with the same labels and
+// amounts as the image and every item name in full
+func PlainText(order OrderSummary, opts Options) (string, error) {
+	textContent, footer, err := ExpandTextContent(order, opts.Text, opts.Footer)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := writeText(&sb, order, textContent, footer); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// AltText returns a one-line description of the order summary for screen readers,
+// giving the header, item count, the first few items and the total
+func AltText(order OrderSummary, opts Options) (string, error) {
+	textContent, _, err := ExpandTextContent(order, opts.Text, opts.Footer)
+	if err != nil {
+		return "", err
+	}
+	return altText(order, textContent), nil
+}
+
+// altTextItems is the number of items named in alt text
+const altTextItems = 3
+
+// altText describes the order as the header, the item count, the first
+// altTextItems items and the total, such as "Order Summary, 5 items: 1x Orchid;
+// 2x Pot; 1x Soil; …. Total: INR 10.00". Listed names are never truncated, so the
+// alt text carries what Layout.MaxItemLines cuts from the image.
+func altText(order OrderSummary, textContent TextContent) string {
+	var sb strings.Builder
+	sb.WriteString(textContent.HeaderText)
+	sb.WriteString(", ")
+	sb.WriteString(itemCount(len(order.Items), textContent.ItemCountText))
+	for i, item := range order.Items {
+		if i == 0 {
+			sb.WriteString(": ")
+		} else {
+			sb.WriteString("; ")
+		}
+		if i == altTextItems {
+			sb.WriteString("…")
+			break
+		}
+		sb.WriteString(formatItem(item))
+	}
+	sb.WriteString(". ")
+	sb.WriteString(totalText(textContent.TotalText, order.Currency, order.Total))
+	return sb.String()
+}
+
+// itemCount returns the catalog's count message, or "N items" for text content
+// built without one
+func itemCount(n int, count string) string {
+	if count = strings.TrimSpace(count); count != "" {
+		return count
+	}
+	if n == 1 {
+		return "1 item"
+	}
+	return strconv.Itoa(n) + " items"
+}
+
+// totalText formats a total as its label and amount, such as "Total: INR 10.00"
+func totalText(label, currency string, value float64) string {
+	return strings.TrimSuffix(strings.TrimSpace(label), ":") + ": " + formatMoney(currency, value)
+}
+
+// writeText writes the summary in the order it is drawn: the header, one line per
+// item, the totals and the footer, with blank lines between sections
+func writeText(w io.Writer, order OrderSummary, textContent TextContent, footer string) error {
+	bw := bufio.NewWriter(w)
+	line := func(s string) {
+		bw.WriteString(s)
+		bw.WriteByte('\n')
+	}
+
+	line(textContent.HeaderText)
+	line("")
+	line(textContent.ItemsText)
+	for _, item := range order.Items {
+		line(formatItem(item) + ": " + formatMoney(order.Currency, item.Price*float64(item.Quantity)))
+	}
+	line("")
+	for _, row := range []struct {
+		label string
+		value float64
+	}{
+		{textContent.SubtotalText, order.Subtotal},
+		{textContent.DiscountText, order.Discount},
+		{textContent.ShippingText, order.Shipping},
+		{textContent.TaxesText, order.Taxes},
+		{textContent.TotalText, order.Total},
+	} {
+		line(totalText(row.label, order.Currency, row.value))
+	}
+	if footer != "" {
+		line("")
+		line(footer)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return newError("write text", ErrEncode, err)
+	}
+	return nil
+}
+
+// renderText is Render for FormatText
+func renderText(order OrderSummary, w io.Writer, opts Options) (*Result, error) {
+	textContent, footer, err := ExpandTextContent(order, opts.Text, opts.Footer)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeText(w, order, textContent, footer); err != nil {
+		return nil, err
+	}
+	res := &Result{Format: FormatText}
+	if opts.AltText {
+		res.AltText = altText(order, textContent)
+	}
+	return res, nil
+}
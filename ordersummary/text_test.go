@@ -0,0 +1,142 @@
+package ordersummary
+
+import (
+	"strings"
+	"testing"
+)
+
+func testOrder() OrderSummary {
+	return OrderSummary{
+		OrderID: "#1042",
+		Items: []Item{
+			{Name: "Phalaenopsis Orchid", Quantity: 1, Price: 349.99},
+			{Name: "Ceramic Pot", Quantity: 2, Price: 120},
+		},
+		Subtotal: 589.99,
+		Total:    589.99,
+		Currency: "INR",
+	}
+}
+
+// plainText is the label set GenerateOrderSummary callers pass, with no count
+// in the items heading
+var plainText = TextContent{
+	HeaderText:   "Order Summary",
+	ItemsText:    "Items",
+	SubtotalText: "Subtotal:",
+	ShippingText: "Shipping:",
+	TaxesText:    "Taxes:",
+	TotalText:    "Total:",
+	DiscountText: "Discount:",
+}
+
+func TestAltText(t *testing.T) {
+	want := "Order Summary, 2 items: 1x Phalaenopsis Orchid; 2x Ceramic Pot. Total: INR 589.99"
+	got, err := AltText(testOrder(), Options{Text: plainText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("AltText =\n%q, want\n%q", got, want)
+	}
+}
+
+func TestAltTextListsFirstItems(t *testing.T) {
+	order := testOrder()
+	for i := 0; i < 5; i++ {
+		order.Items = append(order.Items, Item{Name: "Orchid food", Quantity: 1, Price: 9.5})
+	}
+	want := "Order Summary, 7 items: 1x Phalaenopsis Orchid; 2x Ceramic Pot; 1x Orchid food; …. Total: INR 589.99"
+	got, err := AltText(order, Options{Text: plainText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("AltText =\n%q, want\n%q", got, want)
+	}
+}
+
+// TestAltTextUsesCatalogCount checks the count comes from the catalog's plural
+// forms, including the Arabic ones that do not contain the number
+func TestAltTextUsesCatalogCount(t *testing.T) {
+	for _, tc := range []struct {
+		locale string
+		items  int
+		want   string
+	}{
+		{"en", 1, "Order Summary, 1 item: "},
+		{"en", 2, "Order Summary, 2 items: "},
+		{"es", 2, "Resumen del pedido, 2 artículos: "},
+		{"ar", 1, "ملخص الطلب, منتج واحد: "},
+		{"ar", 2, "ملخص الطلب, منتجان: "},
+		{"ar", 3, "ملخص الطلب, 3 منتجات: "},
+		{"ar", 12, "ملخص الطلب, 12 منتجًا: "},
+	} {
+		order := testOrder()
+		for len(order.Items) < tc.items {
+			order.Items = append(order.Items, Item{Name: "Orchid food", Quantity: 1, Price: 9.5})
+		}
+		order.Items = order.Items[:tc.items]
+		text, err := TextContentForLocale(tc.locale, len(order.Items))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := AltText(order, Options{Text: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(got, tc.want) {
+			t.Errorf("%s with %d items: AltText = %q, want prefix %q", tc.locale, tc.items, got, tc.want)
+		}
+	}
+}
+
+// TestAltTextIgnoresDigitsInHeading checks a heading that happens to contain the
+// item count is not mistaken for it
+func TestAltTextIgnoresDigitsInHeading(t *testing.T) {
+	text := plainText
+	text.ItemsText = "Top 2 picks"
+	got, err := AltText(testOrder(), Options{Text: text})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "Order Summary, 2 items: ") {
+		t.Errorf("AltText = %q", got)
+	}
+}
+
+func TestAltTextSingleItem(t *testing.T) {
+	order := testOrder()
+	order.Items = order.Items[:1]
+	got, err := AltText(order, Options{Text: plainText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "Order Summary, 1 item: ") {
+		t.Errorf("AltText = %q", got)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	want := `Order Summary
+
+Items
+1x Phalaenopsis Orchid: INR 349.99
+2x Ceramic Pot: INR 240.00
+
+Subtotal: INR 589.99
+Discount: INR 0.00
+Shipping: INR 0.00
+Taxes: INR 0.00
+Total: INR 589.99
+
+Thank you
+`
+	got, err := PlainText(testOrder(), Options{Text: plainText, Footer: "Thank you"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("PlainText =\n%s\nwant\n%s", got, want)
+	}
+}
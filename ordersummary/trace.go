@@ -0,0 +1,76 @@
+package ordersummary
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Span names, one for each phase of a render. Spans for the phases are children
+// of SpanRender.
+const (
+	SpanRender = "ordersummary.render"
+	// SpanLoadFonts parses the fonts, or finds them in the font cache
+	SpanLoadFonts = "ordersummary.load_fonts"
+	// SpanLayout places every box of the document
+	SpanLayout = "ordersummary.layout"
+	// SpanMeasureText sizes the price column and wraps the item names, as a child of SpanLayout
+	SpanMeasureText = "ordersummary.measure_text"
+	// SpanDraw paints the document onto the canvas
+	SpanDraw = "ordersummary.draw"
+	// SpanEncode encodes the canvas as PNG or JPEG
+	SpanEncode = "ordersummary.encode"
+)
+
+// Attr is a key-value attribute of a span. Values are strings, ints, int64s,
+// float64s or bools.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// Tracer starts the spans that time each phase of a render, so slow renders can
+// be attributed to font loading, text measurement, drawing or encoding. Adapt a
+// tracing library to it and install it with SetTracer.
+type Tracer interface {
+	// Start begins a span as a child of any span in ctx, returning a context
+	// carrying the new span
+	Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span)
+}
+
+// Span is a timed phase started by a Tracer
+type Span interface {
+	SetAttributes(attrs ...Attr)
+	// End finishes the span, recording err as its failure when not nil
+	End(err error)
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attr) {}
+func (nopSpan) End(error)             {}
+
+var tracer atomic.Pointer[Tracer]
+
+// SetTracer installs t to trace every render in the process, replacing any
+// previous tracer. Renders are not traced by default or when t is nil.
+func SetTracer(t Tracer) {
+	if t == nil {
+		tracer.Store(nil)
+		return
+	}
+	tracer.Store(&t)
+}
+
+// startSpan starts a span with the installed tracer, or a no-op span
+func startSpan(ctx context.Context, name string, attrs ...Attr) (context.Context, Span) {
+	if t := tracer.Load(); t != nil {
+		return (*t).Start(ctx, name, attrs...)
+	}
+	return nopTracer{}.Start(ctx, name, attrs...)
+}
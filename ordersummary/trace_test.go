@@ -0,0 +1,204 @@
+package ordersummary_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/tracing/tracetest"
+)
+
+var traceOrder = ordersummary.OrderSummary{
+	OrderID: "#1042",
+	Items: []ordersummary.Item{
+		{Name: "Phalaenopsis Orchid", Quantity: 1, Price: 349.99},
+		{Name: "Ceramic Pot", Quantity: 2, Price: 120},
+	},
+	Subtotal: 589.99,
+	Total:    589.99,
+	Currency: "INR",
+}
+
+// spanOrder is the order a render's spans end in, each phase finishing before the
+// next starts and text measurement finishing inside layout
+var spanOrder = []string{
+	ordersummary.SpanLoadFonts,
+	ordersummary.SpanMeasureText,
+	ordersummary.SpanLayout,
+	ordersummary.SpanDraw,
+	ordersummary.SpanEncode,
+	ordersummary.SpanRender,
+}
+
+// spanParents maps each phase span to the span it must be a child of
+var spanParents = map[string]string{
+	ordersummary.SpanLoadFonts:   ordersummary.SpanRender,
+	ordersummary.SpanLayout:      ordersummary.SpanRender,
+	ordersummary.SpanMeasureText: ordersummary.SpanLayout,
+	ordersummary.SpanDraw:        ordersummary.SpanRender,
+	ordersummary.SpanEncode:      ordersummary.SpanRender,
+}
+
+// record installs a recorder for the rest of the test
+func record(t *testing.T) *tracetest.Recorder {
+	t.Helper()
+	rec := tracetest.NewRecorder()
+	ordersummary.SetTracer(rec)
+	t.Cleanup(func() { ordersummary.SetTracer(nil) })
+	return rec
+}
+
+// checkSpans checks that one render emitted its phases in order, each under its
+// parent and within the render span
+func checkSpans(t *testing.T, rec *tracetest.Recorder, backend string, size int64) {
+	t.Helper()
+	spans := rec.Spans()
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	if !slices.Equal(names, spanOrder) {
+		t.Fatalf("spans ended in order %v, want %v", names, spanOrder)
+	}
+
+	root := spans[len(spans)-1]
+	if root.Parent != "" || root.Err != nil {
+		t.Errorf("render span has parent %q, error %v", root.Parent, root.Err)
+	}
+	if root.Attrs["backend"] != backend || root.Attrs["items"] != len(traceOrder.Items) || root.Attrs["bytes"] != size {
+		t.Errorf("render span attributes %v, want backend %s, %d items, %d bytes", root.Attrs, backend, len(traceOrder.Items), size)
+	}
+	var prev tracetest.Span
+	for _, s := range spans[:len(spans)-1] {
+		if s.Parent != spanParents[s.Name] {
+			t.Errorf("%s span has parent %q, want %q", s.Name, s.Parent, spanParents[s.Name])
+		}
+		if s.Err != nil {
+			t.Errorf("%s span failed: %v", s.Name, s.Err)
+		}
+		if s.Start.Before(root.Start) || s.End.After(root.End) {
+			t.Errorf("%s span is outside the render span", s.Name)
+		}
+		if s.Parent == ordersummary.SpanRender {
+			if s.Start.Before(prev.End) {
+				t.Errorf("%s span started before %s ended", s.Name, prev.Name)
+			}
+			prev = s
+		}
+	}
+	if lines := rec.Named(ordersummary.SpanMeasureText)[0].Attrs["lines"]; lines != len(traceOrder.Items) {
+		t.Errorf("measure_text span has %v lines, want %d", lines, len(traceOrder.Items))
+	}
+}
+
+func TestTraceRender(t *testing.T) {
+	rec := record(t)
+	var buf bytes.Buffer
+	if _, err := ordersummary.Render(traceOrder, &buf, ordersummary.Options{Layout: ordersummary.DefaultLayout()}); err != nil {
+		t.Fatal(err)
+	}
+	checkSpans(t, rec, ordersummary.BackendRaw, int64(buf.Len()))
+}
+
+func TestTraceGenerateOrderSummary(t *testing.T) {
+	rec := record(t)
+	text, err := ordersummary.TextContentForLocale(ordersummary.DefaultLocale, len(traceOrder.Items))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(t.TempDir(), "raw.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := ordersummary.GenerateOrderSummary(traceOrder, f, ordersummary.DefaultLayout(), text, ""); err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSpans(t, rec, ordersummary.BackendRaw, info.Size())
+}
+
+func TestTraceGG(t *testing.T) {
+	rec := record(t)
+	f, err := os.Create(filepath.Join(t.TempDir(), "gg.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := ordersummary.GenerateOrderSummaryGG(traceOrder, f, ordersummary.DefaultLayout()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSpans(t, rec, ordersummary.BackendGG, info.Size())
+}
+
+func TestTraceParentFromContext(t *testing.T) {
+	rec := record(t)
+	ctx, parent := rec.Start(context.Background(), "request")
+	var buf bytes.Buffer
+	_, err := ordersummary.RenderContext(ctx, traceOrder, &buf, ordersummary.Options{Layout: ordersummary.DefaultLayout()})
+	parent.End(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots := rec.Named(ordersummary.SpanRender); len(roots) != 1 || roots[0].Parent != "request" {
+		t.Errorf("render span is not a child of the context's span: %+v", roots)
+	}
+}
+
+func TestTraceError(t *testing.T) {
+	rec := record(t)
+	// A valid layout whose canvas exceeds MaxCanvasPixels
+	layout := ordersummary.DefaultLayout()
+	layout.Width = 100_000
+	layout.Scale = ordersummary.MaxScale
+
+	var buf bytes.Buffer
+	if _, err := ordersummary.Render(traceOrder, &buf, ordersummary.Options{Layout: layout}); !errors.Is(err, ordersummary.ErrCanvasTooLarge) {
+		t.Fatalf("got error %v, want ErrCanvasTooLarge", err)
+	}
+	for _, name := range []string{ordersummary.SpanRender, ordersummary.SpanDraw} {
+		if spans := rec.Named(name); len(spans) != 1 || !errors.Is(spans[0].Err, ordersummary.ErrCanvasTooLarge) {
+			t.Errorf("%s span does not record the error: %+v", name, spans)
+		}
+	}
+	if spans := rec.Named(ordersummary.SpanEncode); len(spans) != 0 {
+		t.Error("encode span started after drawing failed")
+	}
+}
+
+func TestTraceOffByDefault(t *testing.T) {
+	// A recorder used as a parent but not installed sees only its own span
+	rec := tracetest.NewRecorder()
+	ctx, parent := rec.Start(context.Background(), "request")
+	var buf bytes.Buffer
+	_, err := ordersummary.RenderContext(ctx, traceOrder, &buf, ordersummary.Options{Layout: ordersummary.DefaultLayout()})
+	parent.End(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spans := rec.Spans(); len(spans) != 1 || spans[0].Name != "request" {
+		t.Errorf("got spans %+v with no tracer installed", spans)
+	}
+
+	// Uninstalling a tracer restores the default
+	installed := record(t)
+	ordersummary.SetTracer(nil)
+	if _, err := ordersummary.Render(traceOrder, &buf, ordersummary.Options{Layout: ordersummary.DefaultLayout()}); err != nil {
+		t.Fatal(err)
+	}
+	if spans := installed.Spans(); len(spans) != 0 {
+		t.Errorf("got %d spans after SetTracer(nil)", len(spans))
+	}
+}
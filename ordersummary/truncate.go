@@ -0,0 +1,43 @@
+package ordersummary
+
+import (
+	"strings"
+
+	"github.com/rivo/uniseg"
+)
+
+// Ellipsis is appended to item names cut short by Layout.MaxItemLines
+const Ellipsis = "…"
+
+// itemLines wraps an item's text and, when maxLines is positive, cuts it to that many
+// lines with an ellipsis. It reports whether the text was truncated.
+func itemLines(text string, maxWidth float64, measure func(string) float64, hyph Hyphenator, maxLines int) ([]string, bool) {
+	lines := wrapLines(text, maxWidth, measure, hyph)
+	if maxLines <= 0 || len(lines) <= maxLines {
+		return lines, false
+	}
+	lines = lines[:maxLines]
+	lines[maxLines-1] = ellipsize(lines[maxLines-1], maxWidth, measure)
+	return lines, true
+}
+
+// ellipsize appends an ellipsis to line, removing whole grapheme clusters from its
+// end until the result fits within maxWidth
+func ellipsize(line string, maxWidth float64, measure func(string) float64) string {
+	// Boundaries between grapheme clusters, so removal never splits a cluster
+	bounds := []int{0}
+	rest, state := line, -1
+	for rest != "" {
+		var cluster string
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		bounds = append(bounds, bounds[len(bounds)-1]+len(cluster))
+	}
+
+	for i := len(bounds) - 1; i >= 0; i-- {
+		head := strings.TrimRight(line[:bounds[i]], " -")
+		if measure(head+Ellipsis) <= maxWidth || i == 0 {
+			return head + Ellipsis
+		}
+	}
+	return Ellipsis
+}
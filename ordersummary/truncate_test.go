@@ -0,0 +1,49 @@
+package ordersummary
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTruncatedNameInAltText(t *testing.T) {
+	long := "Hand-painted ceramic planter with drainage tray, saucer and a matching bamboo stand for indoor orchids and succulents"
+	order := testOrder()
+	order.Items[1].Name = long
+	layout := DefaultLayout()
+	layout.MaxItemLines = 1
+
+	var buf bytes.Buffer
+	res, err := Render(order, &buf, Options{Layout: layout, Text: plainText, AltText: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.TruncatedItems) != 1 || res.TruncatedItems[0] != 1 {
+		t.Fatalf("got truncated items %v, want [1]", res.TruncatedItems)
+	}
+	if !strings.Contains(res.AltText, long) {
+		t.Errorf("alt text %q does not contain the full name of the truncated item", res.AltText)
+	}
+}
+
+func TestTruncateItemLines(t *testing.T) {
+	order := testOrder()
+	order.Items[0].Name = strings.Repeat("word ", 60)
+	layout := DefaultLayout()
+
+	doc, err := Measure(order, Options{Layout: layout, Text: plainText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout.MaxItemLines = 2
+	truncated, err := Measure(order, Options{Layout: layout, Text: plainText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.TruncatedItems) != 0 || len(truncated.TruncatedItems) != 1 {
+		t.Errorf("got truncated items %v without a limit and %v with one", doc.TruncatedItems, truncated.TruncatedItems)
+	}
+	if truncated.Height >= doc.Height {
+		t.Errorf("truncated height %d is not less than full height %d", truncated.Height, doc.Height)
+	}
+}
@@ -0,0 +1,46 @@
+package ordersummary
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Validate checks that the order can be rendered: it has at least one item, every
+// item has a name, a positive quantity and a finite non-negative price, and a currency is set
+func (o OrderSummary) Validate() error {
+	var errs []error
+	if len(o.Items) == 0 {
+		errs = append(errs, errors.New("order has no items"))
+	}
+	for i, item := range o.Items {
+		if strings.TrimSpace(item.Name) == "" {
+			errs = append(errs, fmt.Errorf("item %d: name is empty", i+1))
+		}
+		if item.Quantity <= 0 {
+			errs = append(errs, fmt.Errorf("item %d: quantity must be positive, got %d", i+1, item.Quantity))
+		}
+		if item.Price < 0 || math.IsNaN(item.Price) || math.IsInf(item.Price, 0) {
+			errs = append(errs, fmt.Errorf("item %d: invalid price %v", i+1, item.Price))
+		}
+	}
+	for _, amount := range []struct {
+		name  string
+		value float64
+	}{
+		{"subtotal", o.Subtotal},
+		{"shipping", o.Shipping},
+		{"taxes", o.Taxes},
+		{"total", o.Total},
+		{"discount", o.Discount},
+	} {
+		if math.IsNaN(amount.value) || math.IsInf(amount.value, 0) {
+			errs = append(errs, fmt.Errorf("%s is not a finite number", amount.name))
+		}
+	}
+	if strings.TrimSpace(o.Currency) == "" {
+		errs = append(errs, errors.New("currency is empty"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,287 @@
+package ordersummary
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/image/font"
+)
+
+// Hyphenator reports where a word may be hyphenated
+type Hyphenator interface {
+	// Hyphenate returns the byte offsets in word at which it may be split with a
+	// hyphen, in increasing order
+	Hyphenate(word string) []int
+}
+
+// Dictionary is a Hyphenator backed by a list of pre-hyphenated words such as "or-chid"
+type Dictionary struct {
+	// words maps a lower-cased word to the rune positions of its hyphenation points
+	words map[string][]int
+}
+
+// NewDictionary creates a dictionary from entries with hyphens marking the break points
+func NewDictionary(entries []string) *Dictionary {
+	d := &Dictionary{words: make(map[string][]int, len(entries))}
+	for _, entry := range entries {
+		d.add(entry)
+	}
+	return d
+}
+
+// LoadDictionary reads a dictionary with one hyphenated word per line. Blank lines
+// and lines starting with '#' are ignored.
+func LoadDictionary(r io.Reader) (*Dictionary, error) {
+	d := &Dictionary{words: make(map[string][]int)}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		d.add(line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read hyphenation dictionary: %w", err)
+	}
+	return d, nil
+}
+
+func (d *Dictionary) add(entry string) {
+	var word strings.Builder
+	var points []int
+	n := 0
+	for _, r := range strings.ToLower(entry) {
+		if r == '-' {
+			if n > 0 {
+				points = append(points, n)
+			}
+			continue
+		}
+		word.WriteRune(r)
+		n++
+	}
+	if word.Len() > 0 {
+		d.words[word.String()] = points
+	}
+}
+
+// Hyphenate looks up word, ignoring case and surrounding punctuation
+func (d *Dictionary) Hyphenate(word string) []int {
+	start := strings.IndexFunc(word, unicode.IsLetter)
+	if start < 0 {
+		return nil
+	}
+	end := strings.LastIndexFunc(word, unicode.IsLetter)
+	_, size := utf8.DecodeRuneInString(word[end:])
+	core := word[start : end+size]
+
+	points, ok := d.words[strings.ToLower(core)]
+	if !ok {
+		return nil
+	}
+
+	// Convert rune positions in the core to byte offsets in word
+	var offsets []int
+	n, next := 0, 0
+	for i := range core {
+		if next < len(points) && n == points[next] {
+			offsets = append(offsets, start+i)
+			next++
+		}
+		n++
+	}
+	return offsets
+}
+
+var (
+	hyphenatorsMu sync.RWMutex
+	hyphenators   = make(map[string]Hyphenator)
+)
+
+// RegisterHyphenator sets the hyphenator used for a language such as "en" or "de"
+func RegisterHyphenator(lang string, h Hyphenator) {
+	hyphenatorsMu.Lock()
+	defer hyphenatorsMu.Unlock()
+	hyphenators[normalizeLocale(lang)] = h
+}
+
+// hyphenatorFor returns the hyphenator registered for lang or its base language
+func hyphenatorFor(lang string) Hyphenator {
+	if lang == "" {
+		return nil
+	}
+	hyphenatorsMu.RLock()
+	defer hyphenatorsMu.RUnlock()
+	tag := normalizeLocale(lang)
+	if h, ok := hyphenators[tag]; ok {
+		return h
+	}
+	return hyphenators[baseLanguage(tag)]
+}
+
+// wrapText breaks text into lines that fit within maxWidth pixels when drawn with face
+func wrapText(text string, maxWidth int, face font.Face, hyph Hyphenator) []string {
+	return wrapLines(text, float64(maxWidth), func(s string) float64 {
+		return float64(measureTextWidth(s, face))
+	}, hyph)
+}
+
+// wrapLines breaks text into lines no wider than maxWidth. Newlines in the text
+// always start a new line. Words are moved whole to the next line when possible;
+// a word wider than a full line is split at a hyphenation point, after a URL or
+// compound separator, or failing that between grapheme clusters. When hyph is set
+// it is also used to fill the end of a line with part of the next word.
+func wrapLines(text string, maxWidth float64, measure func(string) float64, hyph Hyphenator) []string {
+	w := &lineWrapper{maxWidth: maxWidth, measure: measure, hyph: hyph}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	paragraphs := strings.Split(text, "\n")
+	for i, p := range paragraphs {
+		words := strings.Fields(p)
+		if len(words) == 0 {
+			// Keep intentional blank lines between paragraphs, but not trailing ones
+			if i > 0 && i < len(paragraphs)-1 {
+				w.lines = append(w.lines, "")
+			}
+			continue
+		}
+		for _, word := range words {
+			w.addWord(word)
+		}
+		w.flush()
+	}
+	return w.lines
+}
+
+type lineWrapper struct {
+	maxWidth float64
+	measure  func(string) float64
+	hyph     Hyphenator
+	lines    []string
+	line     string
+}
+
+func (w *lineWrapper) fits(s string) bool {
+	return w.measure(s) <= w.maxWidth
+}
+
+func (w *lineWrapper) flush() {
+	if w.line != "" {
+		w.lines = append(w.lines, w.line)
+		w.line = ""
+	}
+}
+
+func (w *lineWrapper) addWord(word string) {
+	var hyphenPoints []int
+	if w.hyph != nil {
+		hyphenPoints = w.hyph.Hyphenate(word)
+	}
+	emit := func(line, tail string) {
+		w.lines = append(w.lines, line)
+		w.line = ""
+		hyphenPoints = rebaseOffsets(hyphenPoints, len(word)-len(tail))
+		word = tail
+	}
+
+	for word != "" {
+		prefix := ""
+		if w.line != "" {
+			prefix = w.line + " "
+		}
+		if w.fits(prefix + word) {
+			w.line = prefix + word
+			return
+		}
+
+		// Fill the rest of the line with part of the word when it can be hyphenated
+		if w.line != "" {
+			if head, tail, ok := w.splitAt(prefix, word, hyphenPoints, true); ok {
+				emit(prefix+head, tail)
+				continue
+			}
+			// Otherwise move the word to its own line, and stop there if it fits
+			w.flush()
+			continue
+		}
+
+		// The word is wider than a whole line
+		if head, tail, ok := w.splitAt("", word, hyphenPoints, true); ok {
+			emit(head, tail)
+			continue
+		}
+		if head, tail, ok := w.splitAt("", word, separatorBreaks(word), false); ok {
+			emit(head, tail)
+			continue
+		}
+		head, tail := w.splitGraphemes(word)
+		emit(head, tail)
+	}
+}
+
+// rebaseOffsets drops the offsets up to n and shifts the rest so they index the
+// word that remains after its first n bytes were moved to a previous line
+func rebaseOffsets(offsets []int, n int) []int {
+	var rebased []int
+	for _, off := range offsets {
+		if off > n {
+			rebased = append(rebased, off-n)
+		}
+	}
+	return rebased
+}
+
+// splitAt picks the last offset at which prefix plus the head of word fits on a line,
+// appending a hyphen to the head when hyphenate is set
+func (w *lineWrapper) splitAt(prefix, word string, offsets []int, hyphenate bool) (head, tail string, ok bool) {
+	for i := len(offsets) - 1; i >= 0; i-- {
+		off := offsets[i]
+		if off <= 0 || off >= len(word) {
+			continue
+		}
+		head = word[:off]
+		if hyphenate && !strings.HasSuffix(head, "-") {
+			head += "-"
+		}
+		if w.fits(prefix + head) {
+			return head, word[off:], true
+		}
+	}
+	return "", "", false
+}
+
+// splitGraphemes returns the longest run of whole grapheme clusters that fits on a
+// line, always taking at least one so wrapping makes progress
+func (w *lineWrapper) splitGraphemes(word string) (head, tail string) {
+	end := 0
+	rest := word
+	state := -1
+	for rest != "" {
+		var cluster string
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		if end > 0 && !w.fits(word[:end+len(cluster)]) {
+			break
+		}
+		end += len(cluster)
+	}
+	return word[:end], word[end:]
+}
+
+// separatorBreaks returns the offsets just after the separators in URLs, paths and
+// compounds where a long word can be broken without adding a hyphen
+func separatorBreaks(word string) []int {
+	var offsets []int
+	for i, r := range word {
+		switch r {
+		case '/', '-', '.', '_', '?', '&', '=', '#', ':', ',', ';':
+			offsets = append(offsets, i+utf8.RuneLen(r))
+		}
+	}
+	return offsets
+}
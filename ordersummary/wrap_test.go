@@ -0,0 +1,217 @@
+package ordersummary
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rivo/uniseg"
+)
+
+// clusterWidth measures text as one unit per grapheme cluster, like a monospace
+// font where a combined character or emoji sequence takes one cell
+func clusterWidth(s string) float64 {
+	return float64(uniseg.GraphemeClusterCount(s))
+}
+
+var testDictionary = NewDictionary([]string{"or-chid", "Phal-ae-nop-sis", "é-cole"})
+
+func TestWrapLines(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		text  string
+		width float64
+		hyph  Hyphenator
+		want  []string
+	}{
+		{"empty", "", 10, nil, nil},
+		{"fits", "Orchid", 10, nil, []string{"Orchid"}},
+		{"whole words", "Orchid in a pot", 10, nil, []string{"Orchid in", "a pot"}},
+		{"extra spaces", "  Orchid   in  a pot ", 10, nil, []string{"Orchid in", "a pot"}},
+		{"newline", "Red\nBlue", 10, nil, []string{"Red", "Blue"}},
+		{"blank line", "Red\n\nBlue", 10, nil, []string{"Red", "", "Blue"}},
+		{"CRLF and trailing newline", "Red\r\nBlue\n", 10, nil, []string{"Red", "Blue"}},
+		{"newline inside a line that fits", "Red Blue\nGreen", 20, nil, []string{"Red Blue", "Green"}},
+		{"overlong SKU", "ABCDEFGHIJKLMNOPQRSTUVWXY", 10, nil, []string{"ABCDEFGHIJ", "KLMNOPQRST", "UVWXY"}},
+		{"overlong SKU after a word", "SKU ABCDEFGHIJKLMNO", 10, nil, []string{"SKU", "ABCDEFGHIJ", "KLMNO"}},
+		{"SKU separators", "SKU-1234_AB/XY", 10, nil, []string{"SKU-1234_", "AB/XY"}},
+		{"URL", "https://shop.example/orchid", 10, nil, []string{"https://", "shop.", "example/", "orchid"}},
+		{"URL with query", "example/p?id=42&c=red", 10, nil, []string{"example/p?", "id=42&c=", "red"}},
+		{"dictionary", "Phalaenopsis", 8, testDictionary, []string{"Phalae-", "nopsis"}},
+		{"dictionary rebased", "Phalaenopsis", 5, testDictionary, []string{"Phal-", "ae-", "nop-", "sis"}},
+		{"dictionary fills the line", "Blue orchid", 8, testDictionary, []string{"Blue or-", "chid"}},
+		{"dictionary keeps punctuation", "Blue orchid,", 8, testDictionary, []string{"Blue or-", "chid,"}},
+		{"no dictionary moves the word", "Blue orchid", 8, nil, []string{"Blue", "orchid"}},
+		{"unknown word falls back to graphemes", "Phalaenopsis", 5, NewDictionary(nil), []string{"Phala", "enops", "is"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := wrapLines(tc.text, tc.width, clusterWidth, tc.hyph)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wrapLines(%q, %v) = %q, want %q", tc.text, tc.width, got, tc.want)
+			}
+			for _, line := range got {
+				if clusterWidth(line) > tc.width {
+					t.Errorf("line %q is wider than %v", line, tc.width)
+				}
+			}
+		})
+	}
+}
+
+// TestWrapLinesKeepsGraphemes wraps words too long for a line and checks every
+// break falls between grapheme clusters
+func TestWrapLinesKeepsGraphemes(t *testing.T) {
+	for name, word := range map[string]string{
+		"combining marks": strings.Repeat("e\u0301", 12),
+		"ZWJ emoji":       strings.Repeat("👩‍👩‍👧", 7),
+		"flags":           strings.Repeat("🇮🇳", 7),
+		"Devanagari":      strings.Repeat("क्षत्रिय", 3),
+		"Tamil":           strings.Repeat("க்ஷ்", 5),
+	} {
+		t.Run(name, func(t *testing.T) {
+			lines := wrapLines(word, 3, clusterWidth, nil)
+			if len(lines) < 2 {
+				t.Fatalf("got %q, want the word split", lines)
+			}
+			if joined := strings.Join(lines, ""); joined != word {
+				t.Fatalf("lines %q do not join to the word", lines)
+			}
+			boundaries := graphemeBoundaries(word)
+			offset := 0
+			for _, line := range lines[:len(lines)-1] {
+				offset += len(line)
+				if !boundaries[offset] {
+					t.Errorf("split at byte %d is inside a grapheme cluster: %q", offset, lines)
+				}
+			}
+		})
+	}
+}
+
+func graphemeBoundaries(s string) map[int]bool {
+	boundaries := make(map[int]bool)
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		_, to := g.Positions()
+		boundaries[to] = true
+	}
+	return boundaries
+}
+
+func TestSplitGraphemes(t *testing.T) {
+	w := &lineWrapper{maxWidth: 3, measure: clusterWidth}
+	for _, tc := range []struct {
+		word, head, tail string
+	}{
+		{"abcdef", "abc", "def"},
+		{"ab", "ab", ""},
+		{"e\u0301e\u0301e\u0301e\u0301", "e\u0301e\u0301e\u0301", "e\u0301"},
+		{"👩‍👩‍👧👩‍👩‍👧👩‍👩‍👧👩‍👩‍👧", "👩‍👩‍👧👩‍👩‍👧👩‍👩‍👧", "👩‍👩‍👧"},
+	} {
+		head, tail := w.splitGraphemes(tc.word)
+		if head != tc.head || tail != tc.tail {
+			t.Errorf("splitGraphemes(%q) = %q, %q, want %q, %q", tc.word, head, tail, tc.head, tc.tail)
+		}
+	}
+
+	// A cluster wider than the line is still taken whole so wrapping makes progress
+	narrow := &lineWrapper{maxWidth: 0.5, measure: clusterWidth}
+	if head, tail := narrow.splitGraphemes("e\u0301x"); head != "e\u0301" || tail != "x" {
+		t.Errorf("splitGraphemes on a narrow line = %q, %q", head, tail)
+	}
+}
+
+func TestSplitAt(t *testing.T) {
+	w := &lineWrapper{maxWidth: 8, measure: clusterWidth}
+	for _, tc := range []struct {
+		name, prefix, word string
+		offsets            []int
+		hyphenate          bool
+		head, tail         string
+		ok                 bool
+	}{
+		{"last fitting offset", "", "Phalaenopsis", []int{4, 6, 9}, true, "Phalae-", "nopsis", true},
+		{"after prefix", "Blue ", "orchid", []int{2}, true, "or-", "chid", true},
+		{"nothing fits", "Blue ", "Phalaenopsis", []int{4}, true, "", "", false},
+		{"no offsets", "", "Phalaenopsis", nil, true, "", "", false},
+		{"offsets at the ends", "", "Phalaenopsis", []int{0, 12}, true, "", "", false},
+		{"separator without hyphen", "", "SKU-1234-AB", []int{4, 9}, false, "SKU-", "1234-AB", true},
+		{"no double hyphen", "", "SKU-1234-AB", []int{4}, true, "SKU-", "1234-AB", true},
+	} {
+		head, tail, ok := w.splitAt(tc.prefix, tc.word, tc.offsets, tc.hyphenate)
+		if head != tc.head || tail != tc.tail || ok != tc.ok {
+			t.Errorf("%s: splitAt = %q, %q, %v, want %q, %q, %v", tc.name, head, tail, ok, tc.head, tc.tail, tc.ok)
+		}
+	}
+}
+
+func TestAddWord(t *testing.T) {
+	w := &lineWrapper{maxWidth: 8, measure: clusterWidth, hyph: testDictionary}
+	for _, word := range []string{"A", "blue", "orchid", "Phalaenopsis"} {
+		w.addWord(word)
+	}
+	w.flush()
+	want := []string{"A blue", "orchid", "Phalae-", "nopsis"}
+	if !reflect.DeepEqual(w.lines, want) {
+		t.Errorf("lines = %q, want %q", w.lines, want)
+	}
+}
+
+func TestSeparatorBreaks(t *testing.T) {
+	for _, tc := range []struct {
+		word string
+		want []int
+	}{
+		{"orchid", nil},
+		{"a/b-c_d", []int{2, 4, 6}},
+		{"a.b?c=d&e#f", []int{2, 4, 6, 8, 10}},
+		{"x:y,z;w", []int{2, 4, 6}},
+		{"ü/x", []int{3}},
+		{"a//b", []int{2, 3}},
+	} {
+		if got := separatorBreaks(tc.word); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("separatorBreaks(%q) = %v, want %v", tc.word, got, tc.want)
+		}
+	}
+}
+
+func TestDictionaryHyphenate(t *testing.T) {
+	for _, tc := range []struct {
+		word string
+		want []int
+	}{
+		{"orchid", []int{2}},
+		{"Orchid", []int{2}},
+		{"ORCHID", []int{2}},
+		{"orchid,", []int{2}},
+		{"(orchid)", []int{3}},
+		{"phalaenopsis", []int{4, 6, 9}},
+		{"école", []int{2}},
+		{"«école»", []int{4}},
+		{"orchids", nil},
+		{"rose", nil},
+		{"1234", nil},
+		{"", nil},
+	} {
+		if got := testDictionary.Hyphenate(tc.word); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Hyphenate(%q) = %v, want %v", tc.word, got, tc.want)
+		}
+	}
+}
+
+func TestLoadDictionary(t *testing.T) {
+	d, err := LoadDictionary(strings.NewReader("# orchids\n\nor-chid\n  phal-ae-nop-sis  \n-pot-\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for word, want := range map[string][]int{
+		"orchid":       {2},
+		"phalaenopsis": {4, 6, 9},
+		"pot":          nil,
+		"# orchids":    nil,
+	} {
+		if got := d.Hyphenate(word); !reflect.DeepEqual(got, want) {
+			t.Errorf("Hyphenate(%q) = %v, want %v", word, got, want)
+		}
+	}
+}
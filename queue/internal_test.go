@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"bufio"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadReply(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want any
+	}{
+		{"+OK\r\n", "OK"},
+		{":42\r\n", int64(42)},
+		{":-1\r\n", int64(-1)},
+		{"$5\r\nhello\r\n", "hello"},
+		{"$0\r\n\r\n", ""},
+		{"$7\r\nline\r\n2\r\n", "line\r\n2"},
+		{"$-1\r\n", nil},
+		{"*-1\r\n", nil},
+		{"*0\r\n", []any{}},
+		{"*3\r\n$3\r\nkey\r\n:1\r\n$-1\r\n", []any{"key", int64(1), nil}},
+		{"*2\r\n*1\r\n+a\r\n$1\r\nb\r\n", []any{[]any{"a"}, "b"}},
+	} {
+		got, err := readReply(bufio.NewReader(strings.NewReader(tc.in)))
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %#v, want %#v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestReadReplyErrors(t *testing.T) {
+	_, err := readReply(bufio.NewReader(strings.NewReader("-ERR unknown command 'nope'\r\n")))
+	var redisErr RedisError
+	if !errors.As(err, &redisErr) || string(redisErr) != "ERR unknown command 'nope'" {
+		t.Errorf("error reply: got %v", err)
+	}
+
+	for _, in := range []string{"", "+OK", "+OK\n", ":x\r\n", "$-2\r\n", "$5\r\nhi\r\n", "*x\r\n", "*2\r\n+a\r\n", "?\r\n"} {
+		if _, err := readReply(bufio.NewReader(strings.NewReader(in))); err == nil || errors.As(err, &redisErr) {
+			t.Errorf("%q: got %v, want a protocol error", in, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package queue carries render jobs from the processes that accept them to the
+// workers that render them.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Job is a request to render an order summary in the background
+type Job struct {
+	ID string `json:"id"`
+	// Request is the render request, in the JSON accepted by the server's render endpoint
+	Request json.RawMessage `json:"request"`
+	// CallbackURL receives the outcome of the job once it is stored or dead-lettered
+	CallbackURL string `json:"callbackUrl,omitempty"`
+	// Attempts counts the failed attempts to render the job so far
+	Attempts int `json:"attempts,omitempty"`
+	// Error is the most recent failure
+	Error string `json:"error,omitempty"`
+}
+
+// Queue delivers each job to one consumer. A job that is dequeued is removed from
+// the queue; consumers that fail put it back with Enqueue or DeadLetter.
+type Queue interface {
+	// Enqueue adds a job, delivered once delay has passed
+	Enqueue(ctx context.Context, job Job, delay time.Duration) error
+	// Dequeue blocks until a job is ready or ctx is done
+	Dequeue(ctx context.Context) (Job, error)
+	// DeadLetter sets aside a job that will not be retried
+	DeadLetter(ctx context.Context, job Job) error
+}
+
+// ErrClosed is returned by a closed Memory queue
+var ErrClosed = errors.New("queue: closed")
+
+// Memory is a Queue held in process memory, for a single process or tests
+type Memory struct {
+	mu     sync.Mutex
+	ready  []Job
+	dead   []Job
+	timers map[*time.Timer]struct{}
+	closed bool
+	// notify is signalled whenever a job becomes ready
+	notify chan struct{}
+}
+
+// NewMemory returns an empty in-memory queue
+func NewMemory() *Memory {
+	return &Memory{timers: make(map[*time.Timer]struct{}), notify: make(chan struct{}, 1)}
+}
+
+// Enqueue adds a job, holding it in a timer until delay has passed
+func (m *Memory) Enqueue(ctx context.Context, job Job, delay time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if delay <= 0 {
+		m.push(job)
+		return nil
+	}
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.timers, t)
+		if !m.closed {
+			m.push(job)
+		}
+	})
+	m.timers[t] = struct{}{}
+	return nil
+}
+
+// push appends a ready job; m.mu must be held
+func (m *Memory) push(job Job) {
+	m.ready = append(m.ready, job)
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Dequeue returns the oldest ready job, waiting for one if there is none
+func (m *Memory) Dequeue(ctx context.Context) (Job, error) {
+	for {
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			return Job{}, ErrClosed
+		}
+		if len(m.ready) > 0 {
+			job := m.ready[0]
+			m.ready = m.ready[1:]
+			if len(m.ready) > 0 {
+				// Pass the signal on to any other waiting consumer
+				select {
+				case m.notify <- struct{}{}:
+				default:
+				}
+			}
+			m.mu.Unlock()
+			return job, nil
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-m.notify:
+		case <-ctx.Done():
+			return Job{}, ctx.Err()
+		}
+	}
+}
+
+// DeadLetter keeps job in the list returned by Dead
+func (m *Memory) DeadLetter(ctx context.Context, job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.dead = append(m.dead, job)
+	return nil
+}
+
+// Len returns the number of jobs ready or waiting out a delay
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.ready) + len(m.timers)
+}
+
+// Dead returns the dead-lettered jobs, oldest first
+func (m *Memory) Dead() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Job(nil), m.dead...)
+}
+
+// Close drops delayed jobs and makes every call, including blocked Dequeues, fail
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	for t := range m.timers {
+		t.Stop()
+	}
+	m.timers = nil
+	close(m.notify)
+	return nil
+}
@@ -0,0 +1,133 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/biswaz/img-maker/queue"
+)
+
+// dequeue takes the next job, failing the test if none is ready within a second
+func dequeue(t *testing.T, q queue.Queue) queue.Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	job, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Dequeue: %v", err)
+	}
+	return job
+}
+
+func TestMemoryOrder(t *testing.T) {
+	q := queue.NewMemory()
+	defer q.Close()
+	ctx := context.Background()
+	for _, id := range []string{"a", "b", "c"} {
+		if err := q.Enqueue(ctx, queue.Job{ID: id}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := q.Len(); n != 3 {
+		t.Errorf("Len = %d, want 3", n)
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		if job := dequeue(t, q); job.ID != want {
+			t.Errorf("dequeued %q, want %q", job.ID, want)
+		}
+	}
+}
+
+func TestMemoryDelay(t *testing.T) {
+	q := queue.NewMemory()
+	defer q.Close()
+	ctx := context.Background()
+	if err := q.Enqueue(ctx, queue.Job{ID: "later"}, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue(ctx, queue.Job{ID: "now"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := q.Len(); n != 2 {
+		t.Errorf("Len = %d, want 2 counting the delayed job", n)
+	}
+
+	start := time.Now()
+	if job := dequeue(t, q); job.ID != "now" {
+		t.Errorf("dequeued %q before the job without a delay", job.ID)
+	}
+	if job := dequeue(t, q); job.ID != "later" {
+		t.Errorf("dequeued %q, want the delayed job", job.ID)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("delayed job arrived after %v", elapsed)
+	}
+}
+
+func TestMemoryDequeueWaits(t *testing.T) {
+	q := queue.NewMemory()
+	defer q.Close()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		q.Enqueue(context.Background(), queue.Job{ID: "a"}, 0)
+	}()
+	if job := dequeue(t, q); job.ID != "a" {
+		t.Errorf("dequeued %q", job.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Dequeue(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Dequeue on an empty queue: got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestMemoryDeadLetter(t *testing.T) {
+	q := queue.NewMemory()
+	defer q.Close()
+	job := queue.Job{ID: "a", Attempts: 3, Error: "store unavailable"}
+	if err := q.DeadLetter(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	if dead := q.Dead(); len(dead) != 1 || dead[0].ID != "a" || dead[0].Attempts != 3 {
+		t.Errorf("Dead = %+v", dead)
+	}
+	if n := q.Len(); n != 0 {
+		t.Errorf("dead-lettered job counts as queued: Len = %d", n)
+	}
+}
+
+func TestMemoryClose(t *testing.T) {
+	q := queue.NewMemory()
+	q.Enqueue(context.Background(), queue.Job{ID: "delayed"}, time.Hour)
+
+	errs := make(chan error)
+	go func() {
+		_, err := q.Dequeue(context.Background())
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, queue.ErrClosed) {
+			t.Errorf("blocked Dequeue: got %v, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not wake a blocked Dequeue")
+	}
+
+	if err := q.Enqueue(context.Background(), queue.Job{}, 0); !errors.Is(err, queue.ErrClosed) {
+		t.Errorf("Enqueue after Close: got %v, want ErrClosed", err)
+	}
+	if err := q.DeadLetter(context.Background(), queue.Job{}); !errors.Is(err, queue.ErrClosed) {
+		t.Errorf("DeadLetter after Close: got %v, want ErrClosed", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,351 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRedisKey names the list Redis queues use when Key is empty
+const DefaultRedisKey = "ordersummary:jobs"
+
+// Redis is a Queue kept in a server that speaks the Redis protocol, such as Redis,
+// Valkey or KeyDB, so several processes can share it. Ready jobs are a list at
+// Key, delayed jobs a sorted set at Key+":delayed" scored by when they are due,
+// and dead-lettered jobs a list at Key+":dead".
+//
+// A job is removed from the server as it is dequeued, so a job held by a worker
+// that crashes is lost; callers needing stronger delivery should track jobs by ID.
+type Redis struct {
+	// Addr is the server's host:port
+	Addr     string
+	Username string
+	Password string
+	// DB selects a database other than 0
+	DB int
+	// Key is DefaultRedisKey when empty
+	Key string
+	// DialTimeout is 5s when zero
+	DialTimeout time.Duration
+	// PollInterval is how long a Dequeue blocks on the server before it checks for
+	// delayed jobs that are due; 1s when zero
+	PollInterval time.Duration
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+// ParseRedisURL returns a queue for a URL of the form
+// redis://[[user]:password@]host[:port][/db][?key=name]
+func ParseRedisURL(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("queue: invalid redis URL: %v", err)
+	}
+	if u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("queue: invalid redis URL %q", rawURL)
+	}
+	r := &Redis{Addr: u.Host, Key: u.Query().Get("key")}
+	if u.Port() == "" {
+		r.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.Username = u.User.Username()
+		r.Password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.DB, err = strconv.Atoi(db); err != nil || r.DB < 0 {
+			return nil, fmt.Errorf("queue: invalid redis database %q", db)
+		}
+	}
+	return r, nil
+}
+
+// RedisError is an error reply from the server
+type RedisError string
+
+func (e RedisError) Error() string { return "queue: redis: " + string(e) }
+
+// Enqueue pushes job onto the ready list, or the delayed set when delay is positive
+func (r *Redis) Enqueue(ctx context.Context, job Job, delay time.Duration) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("queue: encode job: %w", err)
+	}
+	if delay <= 0 {
+		_, err = r.do(ctx, "LPUSH", r.key(), string(data))
+		return err
+	}
+	due := time.Now().Add(delay).UnixMilli()
+	_, err = r.do(ctx, "ZADD", r.key()+":delayed", strconv.FormatInt(due, 10), string(data))
+	return err
+}
+
+// Dequeue pops the oldest ready job, moving due delayed jobs onto the ready list
+// while it waits
+func (r *Redis) Dequeue(ctx context.Context) (Job, error) {
+	poll := r.PollInterval
+	if poll <= 0 {
+		poll = time.Second
+	}
+	for {
+		if err := r.promote(ctx); err != nil {
+			return Job{}, err
+		}
+		reply, err := r.do(ctx, "BRPOP", r.key(), strconv.FormatFloat(poll.Seconds(), 'f', -1, 64))
+		if err != nil {
+			return Job{}, err
+		}
+		if reply == nil {
+			if err := ctx.Err(); err != nil {
+				return Job{}, err
+			}
+			continue
+		}
+		pair, ok := reply.([]any)
+		if !ok || len(pair) != 2 {
+			return Job{}, fmt.Errorf("queue: unexpected BRPOP reply %v", reply)
+		}
+		data, _ := pair[1].(string)
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return Job{}, fmt.Errorf("queue: decode job: %w", err)
+		}
+		return job, nil
+	}
+}
+
+// promote moves delayed jobs that are due onto the ready list. Removing a job from
+// the set before pushing it means only one of several consumers moves each job.
+func (r *Redis) promote(ctx context.Context) error {
+	delayed := r.key() + ":delayed"
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	reply, err := r.do(ctx, "ZRANGEBYSCORE", delayed, "-inf", now, "LIMIT", "0", "100")
+	if err != nil {
+		return err
+	}
+	members, _ := reply.([]any)
+	for _, m := range members {
+		member, _ := m.(string)
+		removed, err := r.do(ctx, "ZREM", delayed, member)
+		if err != nil {
+			return err
+		}
+		if n, _ := removed.(int64); n == 0 {
+			continue
+		}
+		if _, err := r.do(ctx, "LPUSH", r.key(), member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeadLetter pushes job onto the dead list
+func (r *Redis) DeadLetter(ctx context.Context, job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("queue: encode job: %w", err)
+	}
+	_, err = r.do(ctx, "LPUSH", r.key()+":dead", string(data))
+	return err
+}
+
+// Ping checks that the server is reachable and accepts the credentials
+func (r *Redis) Ping(ctx context.Context) error {
+	_, err := r.do(ctx, "PING")
+	return err
+}
+
+// Close closes idle connections
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.idle {
+		c.Close()
+	}
+	r.idle = nil
+	return nil
+}
+
+func (r *Redis) key() string {
+	if r.Key == "" {
+		return DefaultRedisKey
+	}
+	return r.Key
+}
+
+// do sends one command on a pooled connection and returns its reply: a string,
+// an int64, nil, or a []any of those. Error replies are returned as RedisError.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	c, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.do(ctx, args...)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection may hold half a reply, so it cannot be reused
+		c.Close()
+		return nil, contextError(ctx, err)
+	}
+	r.mu.Lock()
+	r.idle = append(r.idle, c)
+	r.mu.Unlock()
+	return reply, err
+}
+
+// contextError returns the context's error in place of err when ctx ended the
+// command, since the connection deadline that enforces it reports an i/o timeout
+// that may fire just before ctx does
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// conn returns an idle connection or dials, authenticates and selects a new one
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	if n := len(r.idle); n > 0 {
+		c := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return c, nil
+	}
+	r.mu.Unlock()
+
+	timeout := r.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	d := net.Dialer{Timeout: timeout}
+	nc, err := d.DialContext(ctx, "tcp", r.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("queue: %w", err)
+	}
+	c := &redisConn{Conn: nc, rd: bufio.NewReader(nc), wr: bufio.NewWriter(nc)}
+	if r.Password != "" {
+		args := []string{"AUTH", r.Password}
+		if r.Username != "" {
+			args = []string{"AUTH", r.Username, r.Password}
+		}
+		if _, err := c.do(ctx, args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if r.DB != 0 {
+		if _, err := c.do(ctx, "SELECT", strconv.Itoa(r.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// redisConn is one connection speaking RESP2
+type redisConn struct {
+	net.Conn
+	rd *bufio.Reader
+	wr *bufio.Writer
+}
+
+func (c *redisConn) do(ctx context.Context, args ...string) (any, error) {
+	// Commands that block on the server are bounded by their own timeout, so the
+	// deadline only needs to cover it plus the round trip
+	deadline := time.Now().Add(30 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+
+	fmt.Fprintf(c.wr, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.wr, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := c.wr.Flush(); err != nil {
+		return nil, fmt.Errorf("queue: %w", err)
+	}
+	reply, err := readReply(c.rd)
+	if err != nil {
+		var redisErr RedisError
+		if !errors.As(err, &redisErr) {
+			err = fmt.Errorf("queue: %w", err)
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+// readReply reads one RESP2 value: simple strings and bulk strings as string,
+// integers as int64, null bulk strings and arrays as nil, arrays as []any and
+// error replies as a RedisError
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RedisError(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("malformed bulk length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("malformed array length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		values := make([]any, n)
+		for i := range values {
+			v, err := readReply(rd)
+			var redisErr RedisError
+			if err != nil && !errors.As(err, &redisErr) {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unknown reply type %q", kind)
+	}
+}
@@ -0,0 +1,180 @@
+package queue_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/biswaz/img-maker/queue"
+	"github.com/biswaz/img-maker/queue/redistest"
+)
+
+const password = "hunter2"
+
+// newRedis starts a password-protected stub and a queue for it
+func newRedis(t *testing.T) (*redistest.Server, *queue.Redis) {
+	t.Helper()
+	srv := redistest.NewServer(password)
+	q := srv.Queue()
+	t.Cleanup(func() {
+		q.Close()
+		srv.Close()
+	})
+	return srv, q
+}
+
+func TestParseRedisURL(t *testing.T) {
+	for _, tc := range []struct {
+		url                           string
+		addr, username, password, key string
+		db                            int
+	}{
+		{"redis://localhost", "localhost:6379", "", "", "", 0},
+		{"redis://:secret@redis.internal:6380/2", "redis.internal:6380", "", "secret", "", 2},
+		{"redis://worker:secret@[::1]:6379/0?key=receipts", "[::1]:6379", "worker", "secret", "receipts", 0},
+	} {
+		r, err := queue.ParseRedisURL(tc.url)
+		if err != nil {
+			t.Errorf("%s: %v", tc.url, err)
+			continue
+		}
+		if r.Addr != tc.addr || r.Username != tc.username || r.Password != tc.password || r.Key != tc.key || r.DB != tc.db {
+			t.Errorf("%s: got %+v", tc.url, r)
+		}
+	}
+	for _, bad := range []string{"http://localhost", "redis://", "redis://localhost/db", "redis://localhost/-1", "::"} {
+		if _, err := queue.ParseRedisURL(bad); err == nil {
+			t.Errorf("%s: parsed", bad)
+		}
+	}
+}
+
+func TestRedisRoundTrip(t *testing.T) {
+	srv, q := newRedis(t)
+	ctx := context.Background()
+	job := queue.Job{ID: "a", Request: json.RawMessage(`{"order":{"orderId":"#1042"}}`), CallbackURL: "https://example.com/done"}
+	for _, id := range []string{"a", "b"} {
+		job.ID = id
+		if err := q.Enqueue(ctx, job, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(srv.List(queue.DefaultRedisKey)); n != 2 {
+		t.Fatalf("%d jobs on the list, want 2", n)
+	}
+	for _, want := range []string{"a", "b"} {
+		got := dequeue(t, q)
+		if got.ID != want || string(got.Request) != string(job.Request) || got.CallbackURL != job.CallbackURL {
+			t.Errorf("dequeued %+v, want job %q", got, want)
+		}
+	}
+	if n := len(srv.List(queue.DefaultRedisKey)); n != 0 {
+		t.Errorf("%d jobs left on the list", n)
+	}
+}
+
+func TestRedisKey(t *testing.T) {
+	srv, q := newRedis(t)
+	q.Key = "receipts"
+	if err := q.Enqueue(context.Background(), queue.Job{ID: "a"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.List("receipts")) != 1 || len(srv.List(queue.DefaultRedisKey)) != 0 {
+		t.Error("job was not pushed onto the configured key")
+	}
+}
+
+func TestRedisDelay(t *testing.T) {
+	srv, q := newRedis(t)
+	if err := q.Enqueue(context.Background(), queue.Job{ID: "later"}, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.ZCard(queue.DefaultRedisKey + ":delayed"); n != 1 {
+		t.Fatalf("%d delayed jobs, want 1", n)
+	}
+
+	start := time.Now()
+	if job := dequeue(t, q); job.ID != "later" {
+		t.Errorf("dequeued %q", job.ID)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("delayed job arrived after %v", elapsed)
+	}
+	if n := srv.ZCard(queue.DefaultRedisKey + ":delayed"); n != 0 {
+		t.Errorf("%d jobs left delayed", n)
+	}
+}
+
+func TestRedisDeadLetter(t *testing.T) {
+	srv, q := newRedis(t)
+	if err := q.DeadLetter(context.Background(), queue.Job{ID: "a", Attempts: 1, Error: "invalid order"}); err != nil {
+		t.Fatal(err)
+	}
+	dead := srv.List(queue.DefaultRedisKey + ":dead")
+	if len(dead) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(dead))
+	}
+	var job queue.Job
+	if err := json.Unmarshal([]byte(dead[0]), &job); err != nil || job.ID != "a" || job.Error != "invalid order" {
+		t.Errorf("dead letter %s: %v", dead[0], err)
+	}
+}
+
+func TestRedisDequeueStopsWithContext(t *testing.T) {
+	_, q := newRedis(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	if _, err := q.Dequeue(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRedisReconnects(t *testing.T) {
+	srv, q := newRedis(t)
+	ctx := context.Background()
+	if err := q.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pooled connection is dropped, as by a restarting server
+	srv.DropNext("LPUSH", 1)
+	if err := q.Enqueue(ctx, queue.Job{ID: "a"}, 0); err == nil {
+		t.Fatal("Enqueue on a dropped connection succeeded")
+	}
+	if err := q.Enqueue(ctx, queue.Job{ID: "a"}, 0); err != nil {
+		t.Fatalf("Enqueue after the drop: %v", err)
+	}
+	if job := dequeue(t, q); job.ID != "a" {
+		t.Errorf("dequeued %q", job.ID)
+	}
+}
+
+func TestRedisAuth(t *testing.T) {
+	srv, q := newRedis(t)
+	q.Password = "wrong"
+	err := q.Ping(context.Background())
+	var redisErr queue.RedisError
+	if !errors.As(err, &redisErr) || !strings.HasPrefix(string(redisErr), "WRONGPASS") {
+		t.Errorf("wrong password: got %v, want WRONGPASS", err)
+	}
+
+	noAuth := &queue.Redis{Addr: srv.Addr}
+	defer noAuth.Close()
+	if err := noAuth.Ping(context.Background()); !errors.As(err, &redisErr) || !strings.HasPrefix(string(redisErr), "NOAUTH") {
+		t.Errorf("no password: got %v, want NOAUTH", err)
+	}
+}
+
+func TestRedisDialFailure(t *testing.T) {
+	srv := redistest.NewServer("")
+	addr := srv.Addr
+	srv.Close()
+
+	q := &queue.Redis{Addr: addr, DialTimeout: time.Second}
+	if err := q.Ping(context.Background()); err == nil {
+		t.Error("Ping to a stopped server succeeded")
+	}
+}
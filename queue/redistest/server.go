@@ -0,0 +1,436 @@
+// Package redistest provides a local stand-in for a Redis server that implements
+// the handful of list and sorted set commands the Redis queue uses, over RESP2.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/biswaz/img-maker/queue"
+)
+
+// Server keeps lists and sorted sets in memory and serves them on a local port
+type Server struct {
+	// Addr is the host:port the server listens on
+	Addr     string
+	password string
+
+	ln   net.Listener
+	wg   sync.WaitGroup
+	done chan struct{}
+
+	mu    sync.Mutex
+	lists map[string][]string
+	zsets map[string]map[string]float64
+	conns map[net.Conn]struct{}
+	drop  map[string]int
+	// pushed is closed and replaced whenever a list grows, waking blocked BRPOPs
+	pushed chan struct{}
+}
+
+// NewServer starts a stub server that requires AUTH with password, unless it is
+// empty; callers must Close it
+func NewServer(password string) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("redistest: failed to listen: %v", err))
+	}
+	s := &Server{
+		Addr:     ln.Addr().String(),
+		password: password,
+		ln:       ln,
+		lists:    make(map[string][]string),
+		zsets:    make(map[string]map[string]float64),
+		conns:    make(map[net.Conn]struct{}),
+		drop:     make(map[string]int),
+		pushed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Queue returns a Redis queue for the server that polls for delayed jobs often
+func (s *Server) Queue() *queue.Redis {
+	return &queue.Redis{Addr: s.Addr, Password: s.password, PollInterval: 50 * time.Millisecond}
+}
+
+// DropNext makes the server close the connection instead of answering the next n
+// calls of command, such as "BRPOP", as a restarting server would
+func (s *Server) DropNext(command string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drop[strings.ToUpper(command)] = n
+}
+
+// List returns the list at key, head first
+func (s *Server) List(key string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lists[key]...)
+}
+
+// ZCard returns the size of the sorted set at key
+func (s *Server) ZCard(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.zsets[key])
+}
+
+// Close stops the server and closes every connection
+func (s *Server) Close() {
+	s.ln.Close()
+	close(s.done)
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	rd := bufio.NewReader(c)
+	wr := bufio.NewWriter(c)
+	authed := s.password == ""
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				writeError(wr, "ERR Protocol error: "+err.Error())
+				wr.Flush()
+			}
+			return
+		}
+		name := strings.ToUpper(args[0])
+		s.mu.Lock()
+		drop := s.drop[name] > 0
+		if drop {
+			s.drop[name]--
+		}
+		s.mu.Unlock()
+		if drop {
+			return
+		}
+
+		switch {
+		case name == "AUTH":
+			if args[len(args)-1] != s.password || len(args) > 3 {
+				writeError(wr, "WRONGPASS invalid username-password pair or user is disabled.")
+				break
+			}
+			authed = true
+			wr.WriteString("+OK\r\n")
+		case !authed:
+			writeError(wr, "NOAUTH Authentication required.")
+		default:
+			s.exec(wr, name, args[1:])
+		}
+		if err := wr.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// exec runs one command, writing its reply
+func (s *Server) exec(wr *bufio.Writer, name string, args []string) {
+	arity := map[string]int{
+		"PING": 0, "SELECT": 1, "LPUSH": 2, "BRPOP": 2, "LLEN": 1, "LRANGE": 3,
+		"ZADD": 3, "ZREM": 2, "ZCARD": 1, "ZRANGEBYSCORE": 3,
+	}
+	want, ok := arity[name]
+	if !ok {
+		writeError(wr, fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+		return
+	}
+	if len(args) < want {
+		writeError(wr, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+
+	switch name {
+	case "PING":
+		wr.WriteString("+PONG\r\n")
+	case "SELECT":
+		wr.WriteString("+OK\r\n")
+	case "LPUSH":
+		s.mu.Lock()
+		key := args[0]
+		for _, v := range args[1:] {
+			s.lists[key] = append([]string{v}, s.lists[key]...)
+		}
+		n := len(s.lists[key])
+		close(s.pushed)
+		s.pushed = make(chan struct{})
+		s.mu.Unlock()
+		writeInt(wr, n)
+	case "BRPOP":
+		s.brpop(wr, args[:len(args)-1], args[len(args)-1])
+	case "LLEN":
+		s.mu.Lock()
+		n := len(s.lists[args[0]])
+		s.mu.Unlock()
+		writeInt(wr, n)
+	case "LRANGE":
+		start, err1 := strconv.Atoi(args[1])
+		stop, err2 := strconv.Atoi(args[2])
+		if err1 != nil || err2 != nil {
+			writeError(wr, "ERR value is not an integer or out of range")
+			return
+		}
+		list := s.List(args[0])
+		start, stop = clampRange(start, stop, len(list))
+		writeArray(wr, list[start:stop])
+	case "ZADD":
+		if len(args)%2 != 1 {
+			writeError(wr, "ERR syntax error")
+			return
+		}
+		s.mu.Lock()
+		set := s.zsets[args[0]]
+		if set == nil {
+			set = make(map[string]float64)
+			s.zsets[args[0]] = set
+		}
+		added := 0
+		for i := 1; i < len(args); i += 2 {
+			score, err := strconv.ParseFloat(args[i], 64)
+			if err != nil {
+				s.mu.Unlock()
+				writeError(wr, "ERR value is not a valid float")
+				return
+			}
+			if _, ok := set[args[i+1]]; !ok {
+				added++
+			}
+			set[args[i+1]] = score
+		}
+		s.mu.Unlock()
+		writeInt(wr, added)
+	case "ZREM":
+		s.mu.Lock()
+		removed := 0
+		for _, m := range args[1:] {
+			if _, ok := s.zsets[args[0]][m]; ok {
+				delete(s.zsets[args[0]], m)
+				removed++
+			}
+		}
+		s.mu.Unlock()
+		writeInt(wr, removed)
+	case "ZCARD":
+		writeInt(wr, s.ZCard(args[0]))
+	case "ZRANGEBYSCORE":
+		s.zrangeByScore(wr, args)
+	}
+}
+
+// brpop pops from the tail of the first non-empty list, waiting up to timeout
+// seconds, or forever when it is 0
+func (s *Server) brpop(wr *bufio.Writer, keys []string, timeout string) {
+	secs, err := strconv.ParseFloat(timeout, 64)
+	if err != nil || secs < 0 {
+		writeError(wr, "ERR timeout is not a float or out of range")
+		return
+	}
+	var expired <-chan time.Time
+	if secs > 0 {
+		t := time.NewTimer(time.Duration(secs * float64(time.Second)))
+		defer t.Stop()
+		expired = t.C
+	}
+	for {
+		s.mu.Lock()
+		for _, key := range keys {
+			if list := s.lists[key]; len(list) > 0 {
+				v := list[len(list)-1]
+				s.lists[key] = list[:len(list)-1]
+				s.mu.Unlock()
+				writeArray(wr, []string{key, v})
+				return
+			}
+		}
+		pushed := s.pushed
+		s.mu.Unlock()
+
+		select {
+		case <-pushed:
+		case <-expired:
+			wr.WriteString("*-1\r\n")
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// zrangeByScore handles ZRANGEBYSCORE key min max [LIMIT offset count]
+func (s *Server) zrangeByScore(wr *bufio.Writer, args []string) {
+	lo, err1 := parseScore(args[1])
+	hi, err2 := parseScore(args[2])
+	if err1 != nil || err2 != nil {
+		writeError(wr, "ERR min or max is not a float")
+		return
+	}
+	offset, count := 0, -1
+	if len(args) == 6 && strings.EqualFold(args[3], "LIMIT") {
+		offset, err1 = strconv.Atoi(args[4])
+		count, err2 = strconv.Atoi(args[5])
+		if err1 != nil || err2 != nil {
+			writeError(wr, "ERR value is not an integer or out of range")
+			return
+		}
+	} else if len(args) != 3 {
+		writeError(wr, "ERR syntax error")
+		return
+	}
+
+	type member struct {
+		name  string
+		score float64
+	}
+	var members []member
+	s.mu.Lock()
+	for name, score := range s.zsets[args[0]] {
+		if score >= lo && score <= hi {
+			members = append(members, member{name, score})
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score != members[j].score {
+			return members[i].score < members[j].score
+		}
+		return members[i].name < members[j].name
+	})
+
+	var names []string
+	for i, m := range members {
+		if i < offset {
+			continue
+		}
+		if count >= 0 && len(names) == count {
+			break
+		}
+		names = append(names, m.name)
+	}
+	writeArray(wr, names)
+}
+
+func parseScore(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "-inf":
+		return math.Inf(-1), nil
+	case "+inf", "inf":
+		return math.Inf(1), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// clampRange turns an LRANGE start and inclusive stop, which may count from the
+// end, into slice bounds
+func clampRange(start, stop, n int) (int, int) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop+1, n)
+	if start >= stop {
+		return 0, 0
+	}
+	return start, stop
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := readLine(rd)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("expected '*', got %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid multibulk length %q", line[1:])
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(rd)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("expected '$', got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", line[1:])
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+func writeError(wr *bufio.Writer, msg string) {
+	wr.WriteString("-" + msg + "\r\n")
+}
+
+func writeInt(wr *bufio.Writer, n int) {
+	wr.WriteString(":" + strconv.Itoa(n) + "\r\n")
+}
+
+func writeArray(wr *bufio.Writer, values []string) {
+	wr.WriteString("*" + strconv.Itoa(len(values)) + "\r\n")
+	for _, v := range values {
+		wr.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/queue"
+)
+
+func TestJobs(t *testing.T) {
+	q := queue.NewMemory()
+	defer q.Close()
+	ts := newTestServer(t, Config{Queue: q, CallbackHosts: []string{"example.com"}})
+
+	req := JobRequest{RenderRequest: RenderRequest{Order: testOrder(), Format: "jpeg"}, CallbackURL: "https://example.com/done"}
+	resp, body := post(t, ts, "/jobs", req, nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("got status %d: %s", resp.StatusCode, body)
+	}
+	var accepted JobResponse
+	if err := json.Unmarshal(body, &accepted); err != nil || len(accepted.ID) != 32 || accepted.Status != "queued" {
+		t.Fatalf("response %s: %v", body, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	job, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != accepted.ID || job.CallbackURL != req.CallbackURL {
+		t.Errorf("queued %+v", job)
+	}
+	order, opts, err := New(Config{}).Prepare(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderID != "#1042" || opts.Format != ordersummary.FormatJPEG {
+		t.Errorf("queued request prepares order %q as %s", order.OrderID, opts.Format)
+	}
+}
+
+func TestJobsKeepsID(t *testing.T) {
+	q := queue.NewMemory()
+	defer q.Close()
+	ts := newTestServer(t, Config{Queue: q})
+
+	resp, body := post(t, ts, "/jobs", JobRequest{RenderRequest: RenderRequest{Order: testOrder()}, ID: "order-1042"}, nil)
+	var accepted JobResponse
+	json.Unmarshal(body, &accepted)
+	if resp.StatusCode != http.StatusAccepted || accepted.ID != "order-1042" {
+		t.Errorf("got status %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestJobsRejectsInvalidRequests(t *testing.T) {
+	q := queue.NewMemory()
+	defer q.Close()
+	ts := newTestServer(t, Config{Queue: q})
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"order":{"items":[],"currency":"INR"}}`, http.StatusUnprocessableEntity},
+		{`{"order":{"items":[{"name":"Rose","quantity":-1,"price":5}],"currency":"INR"}}`, http.StatusUnprocessableEntity},
+		{`{"order":{"items":[{"name":"Rose","quantity":1,"price":5}],"currency":"INR"},"format":"gif"}`, http.StatusUnprocessableEntity},
+		{`{"order":{"items":[{"name":"Rose","quantity":1,"price":5}],"currency":"INR"},"callbackUrl":"ftp://example.com"}`, http.StatusBadRequest},
+		{`{"order":{"items":[{"name":"Rose","quantity":1,"price":5}],"currency":"INR"},"callbackUrl":"/done"}`, http.StatusBadRequest},
+		{`{"order":{},"unknown":1}`, http.StatusBadRequest},
+	} {
+		if resp, body := post(t, ts, "/jobs", json.RawMessage(tc.body), nil); resp.StatusCode != tc.status {
+			t.Errorf("%s: got status %d, want %d: %s", tc.body, resp.StatusCode, tc.status, body)
+		}
+	}
+	if n := q.Len(); n != 0 {
+		t.Errorf("%d rejected jobs were queued", n)
+	}
+}
+
+func TestJobsDisabledWithoutQueue(t *testing.T) {
+	ts := newTestServer(t, Config{})
+	if resp, _ := post(t, ts, "/jobs", JobRequest{RenderRequest: RenderRequest{Order: testOrder()}}, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestJobsCallbackPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		hosts   []string
+		url     string
+		allowed bool
+	}{
+		{"public address", nil, "https://93.184.215.14/done", true},
+		{"loopback", nil, "http://127.0.0.1:8080/done", false},
+		{"localhost", nil, "http://localhost/done", false},
+		{"ipv6 loopback", nil, "http://[::1]/done", false},
+		{"mapped loopback", nil, "http://[::ffff:127.0.0.1]/done", false},
+		{"private", nil, "https://10.0.0.5/done", false},
+		{"link-local metadata", nil, "http://169.254.169.254/latest/meta-data", false},
+		{"shared address space", nil, "http://100.64.0.1/done", false},
+		{"unspecified", nil, "http://0.0.0.0/done", false},
+		{"listed host", []string{"hooks.example.com"}, "https://Hooks.Example.com./done", true},
+		{"listed private host", []string{"10.0.0.5"}, "https://10.0.0.5/done", true},
+		{"unlisted host", []string{"hooks.example.com"}, "https://93.184.215.14/done", false},
+		{"subdomain", []string{"*.example.com"}, "https://eu.hooks.example.com/done", true},
+		{"wildcard is not the domain", []string{"*.example.com"}, "https://example.com/done", false},
+		{"suffix is not a subdomain", []string{"*.example.com"}, "https://evilexample.com/done", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q := queue.NewMemory()
+			defer q.Close()
+			ts := newTestServer(t, Config{Queue: q, CallbackHosts: tc.hosts})
+
+			resp, body := post(t, ts, "/jobs", JobRequest{RenderRequest: RenderRequest{Order: testOrder()}, CallbackURL: tc.url}, nil)
+			want := http.StatusAccepted
+			if !tc.allowed {
+				want = http.StatusBadRequest
+			}
+			if resp.StatusCode != want {
+				t.Errorf("got status %d, want %d: %s", resp.StatusCode, want, body)
+			}
+			if queued := q.Len() == 1; queued != tc.allowed {
+				t.Errorf("job queued: %v", queued)
+			}
+		})
+	}
+}
@@ -0,0 +1,574 @@
+// Package server exposes the order summary generator over HTTP.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/biswaz/img-maker/cache"
+	"github.com/biswaz/img-maker/internal/publicnet"
+	"github.com/biswaz/img-maker/metrics"
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/queue"
+)
+
+// Default request limits
+const (
+	DefaultMaxBodyBytes = 1 << 20
+	DefaultMaxItems     = 200
+)
+
+// Config configures the rendering server
+type Config struct {
+	// MaxBodyBytes limits the size of a render request body
+	MaxBodyBytes int64
+	// MaxItems limits the number of items in a single order
+	MaxItems int
+	// Catalog provides the text content for a request's locale; defaults to the built-in catalog
+	Catalog *ordersummary.Catalog
+	// Layout is used when a request does not specify one; unset fields take the package defaults
+	Layout ordersummary.Layout
+	// Footer is used when a request does not specify one
+	Footer string
+	// Cache stores rendered images; rendering is uncached when nil
+	Cache cache.Cache
+	// Queue receives render jobs posted to /jobs; the endpoint is disabled when nil
+	Queue queue.Queue
+	// CallbackHosts lists the hosts jobs may post callbacks to, such as
+	// "hooks.example.com", or "*.example.com" for its subdomains. When empty, any
+	// host is allowed that resolves only to public addresses, so callers cannot
+	// point the worker at loopback, private or link-local services.
+	CallbackHosts []string
+	// Metrics counts cache lookups and is served on /metrics; both are disabled when nil
+	Metrics *metrics.Metrics
+	// Logger is slog.Default() when nil
+	Logger *slog.Logger
+}
+
+// Server renders order summary images over HTTP
+type Server struct {
+	cfg   Config
+	ready atomic.Bool
+}
+
+// RenderRequest is the JSON body accepted by the render endpoint
+type RenderRequest struct {
+	Order  ordersummary.OrderSummary `json:"order"`
+	Layout *ordersummary.Layout      `json:"layout,omitempty"`
+	Theme  *ordersummary.Theme       `json:"theme,omitempty"`
+	// Locale selects the message catalog used for labels, "en" when empty
+	Locale string `json:"locale,omitempty"`
+	// Text overrides individual catalog messages by key
+	Text   map[string]string `json:"text,omitempty"`
+	Footer *string           `json:"footer,omitempty"`
+	// Format requests an output format, taking precedence over the Accept header
+	Format      string `json:"format,omitempty"`
+	JPEGQuality int    `json:"jpegQuality,omitempty"`
+}
+
+// JobRequest is the JSON body accepted by the jobs endpoint: a render request
+// and where to report the outcome
+type JobRequest struct {
+	RenderRequest
+	// ID identifies the job in its callback; a random ID is used when empty
+	ID string `json:"id,omitempty"`
+	// CallbackURL receives a worker.Callback once the job is stored or has failed
+	CallbackURL string `json:"callbackUrl,omitempty"`
+}
+
+// JobResponse is returned for an accepted job
+type JobResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// New creates a server, filling unset configuration with defaults
+func New(cfg Config) *Server {
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = DefaultMaxItems
+	}
+	if cfg.Catalog == nil {
+		cfg.Catalog = ordersummary.DefaultCatalog()
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	cfg.Layout = cfg.Layout.WithDefaults()
+	return &Server{cfg: cfg}
+}
+
+// Handler returns the HTTP routes of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /render", s.handleRender)
+	mux.HandleFunc("POST /hitmap", s.handleHitMap)
+	if s.cfg.Queue != nil {
+		mux.HandleFunc("POST /jobs", s.handleJob)
+	}
+	if s.cfg.Metrics != nil {
+		mux.Handle("GET /metrics", s.cfg.Metrics.Handler())
+	}
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	return mux
+}
+
+// SetReady marks the server as ready or not ready to receive traffic
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// WarmUp renders a small order to load fonts, then marks the server ready
+func (s *Server) WarmUp() error {
+	var buf bytes.Buffer
+	order := ordersummary.OrderSummary{
+		Items:    []ordersummary.Item{{Name: "Warm-up", Quantity: 1, Price: 1}},
+		Currency: "INR",
+	}
+	if _, err := ordersummary.Render(order, &buf, ordersummary.Options{Layout: s.cfg.Layout}); err != nil {
+		return fmt.Errorf("warm up: %w", err)
+	}
+	s.SetReady(true)
+	return nil
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !s.ready.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "not ready")
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
+
+func (s *Server) handleRender(w http.ResponseWriter, r *http.Request) {
+	if !checkContentType(w, r) {
+		return
+	}
+
+	req, status, err := s.decodeRequest(w, r)
+	if err != nil {
+		httpError(w, status, err.Error())
+		return
+	}
+
+	format, err := negotiateFormat(req.Format, r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		status := http.StatusNotAcceptable
+		if errors.Is(err, ordersummary.ErrUnsupportedFormat) {
+			// The body or query named a format that does not exist
+			status = http.StatusBadRequest
+		}
+		httpError(w, status, err.Error())
+		return
+	}
+
+	opts, err := s.options(req)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.Format = format
+
+	key, err := cache.Key(req.Order, opts)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Render, or fetch from the cache, before answering a conditional request, so
+	// a request that cannot render reports its error instead of 304
+	start := time.Now()
+	data, cached, err := s.render(r.Context(), key, req.Order, opts)
+	if err != nil {
+		status := renderErrorStatus(err)
+		if status >= http.StatusInternalServerError {
+			s.cfg.Logger.Error("render failed", "order", req.Order.OrderID, "format", format, "kind", ordersummary.ErrorKind(err), "err", err)
+			httpError(w, status, "failed to render order summary")
+			return
+		}
+		httpError(w, status, err.Error())
+		return
+	}
+	s.cfg.Logger.Debug("rendered order", "order", req.Order.OrderID, "format", format,
+		"items", len(req.Order.Items), "bytes", len(data), "cached", cached, "duration", time.Since(start))
+
+	etag := cache.ETag(key)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept")
+	if cache.NotModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// handleHitMap lays out a render request without drawing it and returns the
+// pixel regions of its item rows, totals and images as JSON
+func (s *Server) handleHitMap(w http.ResponseWriter, r *http.Request) {
+	if !checkContentType(w, r) {
+		return
+	}
+
+	req, status, err := s.decodeRequest(w, r)
+	if err != nil {
+		httpError(w, status, err.Error())
+		return
+	}
+	opts, err := s.options(req)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	doc, err := ordersummary.Measure(req.Order, opts)
+	if err != nil {
+		status := renderErrorStatus(err)
+		if status >= http.StatusInternalServerError {
+			s.cfg.Logger.Error("layout failed", "order", req.Order.OrderID, "kind", ordersummary.ErrorKind(err), "err", err)
+			httpError(w, status, "failed to lay out order summary")
+			return
+		}
+		httpError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(doc.HitMap(req.Order)); err != nil {
+		s.cfg.Logger.Warn("write hit map", "err", err)
+	}
+}
+
+// checkContentType rejects request bodies that are not JSON, reporting whether
+// the request may continue
+func checkContentType(w http.ResponseWriter, r *http.Request) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/json" {
+			httpError(w, http.StatusUnsupportedMediaType, "content type must be application/json")
+			return false
+		}
+	}
+	return true
+}
+
+// render returns the encoded image for key, consulting the configured cache
+// first, and whether it came from the cache
+func (s *Server) render(ctx context.Context, key string, order ordersummary.OrderSummary, opts ordersummary.Options) ([]byte, bool, error) {
+	if s.cfg.Cache != nil {
+		data, ok, err := s.cfg.Cache.Get(key)
+		if err != nil {
+			s.cfg.Logger.Warn("cache get failed", "key", key, "err", err)
+		}
+		if s.cfg.Metrics != nil {
+			s.cfg.Metrics.ObserveCache(ok && err == nil)
+		}
+		if ok && err == nil {
+			return data, true, nil
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := ordersummary.RenderContext(ctx, order, &buf, opts); err != nil {
+		return nil, false, err
+	}
+
+	if s.cfg.Cache != nil {
+		if err := s.cfg.Cache.Set(key, buf.Bytes()); err != nil {
+			s.cfg.Logger.Warn("cache set failed", "key", key, "err", err)
+		}
+	}
+	return buf.Bytes(), false, nil
+}
+
+func (s *Server) decodeRequest(w http.ResponseWriter, r *http.Request) (*RenderRequest, int, error) {
+	var req RenderRequest
+	if status, err := s.decodeBody(w, r, &req); err != nil {
+		return nil, status, err
+	}
+	if err := s.checkItems(req.Order); err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+	if err := req.Order.Validate(); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return &req, http.StatusOK, nil
+}
+
+// decodeBody decodes a size-limited JSON body into v, rejecting unknown fields
+func (s *Server) decodeBody(w http.ResponseWriter, r *http.Request, v any) (int, error) {
+	body := http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", maxErr.Limit)
+		}
+		return http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err)
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Server) checkItems(order ordersummary.OrderSummary) error {
+	if n := len(order.Items); n == 0 {
+		return errors.New("order has no items")
+	} else if n > s.cfg.MaxItems {
+		return fmt.Errorf("order has %d items, the limit is %d", n, s.cfg.MaxItems)
+	}
+	return nil
+}
+
+// handleJob validates a render request and queues it for a worker, answering
+// 202 Accepted with the job's ID
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if !checkContentType(w, r) {
+		return
+	}
+
+	var req JobRequest
+	if status, err := s.decodeBody(w, r, &req); err != nil {
+		httpError(w, status, err.Error())
+		return
+	}
+	if req.CallbackURL != "" {
+		if err := s.checkCallbackURL(r.Context(), req.CallbackURL); err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.ID == "" {
+		req.ID = newJobID()
+	}
+
+	data, err := json.Marshal(req.RenderRequest)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	job := queue.Job{ID: req.ID, Request: data, CallbackURL: req.CallbackURL}
+	// Reject what a worker could never render now, while the client is listening
+	if _, _, err := s.Prepare(job); err != nil {
+		httpError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err := s.cfg.Queue.Enqueue(r.Context(), job, 0); err != nil {
+		s.cfg.Logger.Error("enqueue failed", "job", job.ID, "err", err)
+		httpError(w, http.StatusServiceUnavailable, "failed to queue job")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(JobResponse{ID: job.ID, Status: "queued"})
+}
+
+// checkCallbackURL reports why a job may not post its callback to rawURL: it
+// must be an absolute http or https URL on a host allowed by CallbackHosts, or on
+// a public host when CallbackHosts is empty
+func (s *Server) checkCallbackURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("callbackUrl must be an absolute http or https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if len(s.cfg.CallbackHosts) > 0 {
+		for _, pattern := range s.cfg.CallbackHosts {
+			if matchHost(strings.ToLower(pattern), host) {
+				return nil
+			}
+		}
+		return fmt.Errorf("callbackUrl host %q is not allowed", host)
+	}
+
+	// The name could resolve differently by the time the callback is posted, so
+	// this only rejects obvious cases early; the worker checks each connection
+	addrs, err := lookupHost(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("callbackUrl host %q does not resolve", host)
+	}
+	for _, addr := range addrs {
+		if !publicnet.Addr(addr) {
+			return fmt.Errorf("callbackUrl host %q is not a public address", host)
+		}
+	}
+	return nil
+}
+
+// matchHost reports whether host is pattern, or a subdomain of it when pattern
+// starts with "*."
+func matchHost(pattern, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == pattern
+}
+
+// lookupHost returns the addresses of host, which may be an IP literal
+func lookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// Prepare decodes a queued job's render request into its order and render
+// options, applying the same defaults and limits as the render endpoint. The
+// format is taken from the request alone, PNG when unset.
+func (s *Server) Prepare(job queue.Job) (ordersummary.OrderSummary, ordersummary.Options, error) {
+	dec := json.NewDecoder(bytes.NewReader(job.Request))
+	dec.DisallowUnknownFields()
+	var req RenderRequest
+	if err := dec.Decode(&req); err != nil {
+		return ordersummary.OrderSummary{}, ordersummary.Options{}, fmt.Errorf("invalid request: %v", err)
+	}
+	if err := s.checkItems(req.Order); err != nil {
+		return ordersummary.OrderSummary{}, ordersummary.Options{}, err
+	}
+	if err := req.Order.Validate(); err != nil {
+		return ordersummary.OrderSummary{}, ordersummary.Options{}, err
+	}
+	opts, err := s.options(&req)
+	if err != nil {
+		return ordersummary.OrderSummary{}, ordersummary.Options{}, err
+	}
+	if opts.Format, err = ordersummary.ParseFormat(req.Format); err != nil {
+		return ordersummary.OrderSummary{}, ordersummary.Options{}, err
+	}
+	return req.Order, opts, nil
+}
+
+// newJobID returns a random 128-bit hex ID
+func newJobID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func (s *Server) options(req *RenderRequest) (ordersummary.Options, error) {
+	locale := req.Locale
+	if locale == "" {
+		locale = ordersummary.DefaultLocale
+	}
+	text, err := s.cfg.Catalog.TextContent(locale, len(req.Order.Items), req.Text)
+	if err != nil {
+		return ordersummary.Options{}, err
+	}
+
+	opts := ordersummary.Options{
+		Layout:      s.cfg.Layout,
+		Text:        text,
+		Footer:      s.cfg.Footer,
+		JPEGQuality: req.JPEGQuality,
+		Language:    locale,
+	}
+	if req.Layout != nil {
+		opts.Layout = req.Layout.WithDefaults()
+	}
+	if err := opts.Layout.Validate(); err != nil {
+		return ordersummary.Options{}, err
+	}
+	if req.Theme != nil {
+		opts.Theme = *req.Theme
+	}
+	if req.Footer != nil {
+		opts.Footer = *req.Footer
+	}
+	return opts, nil
+}
+
+// renderErrorStatus maps a rendering error to the HTTP status reported to the client
+func renderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ordersummary.ErrInvalidLayout),
+		errors.Is(err, ordersummary.ErrTemplate),
+		errors.Is(err, ordersummary.ErrUnsupportedFormat):
+		return http.StatusBadRequest
+	case errors.Is(err, ordersummary.ErrCanvasTooLarge):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// negotiateFormat picks the output format from an explicit body or query format,
+// falling back to the most preferred supported type in the Accept header. An
+// unknown explicit format fails with ordersummary.ErrUnsupportedFormat.
+func negotiateFormat(bodyFormat, queryFormat, accept string) (ordersummary.Format, error) {
+	for _, f := range []string{bodyFormat, queryFormat} {
+		if f != "" {
+			return ordersummary.ParseFormat(f)
+		}
+	}
+	if strings.TrimSpace(accept) == "" {
+		return ordersummary.FormatPNG, nil
+	}
+
+	type acceptRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, acceptRange{mt, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, ar := range ranges {
+		switch ar.mediaType {
+		case "image/png", "image/*", "*/*":
+			return ordersummary.FormatPNG, nil
+		case "image/jpeg":
+			return ordersummary.FormatJPEG, nil
+		case "text/plain":
+			return ordersummary.FormatText, nil
+		case "text/html":
+			return ordersummary.FormatHTML, nil
+		}
+	}
+	return "", fmt.Errorf("none of %q is supported, use image/png, image/jpeg, text/plain or text/html", accept)
+}
+
+func httpError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
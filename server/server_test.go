@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/biswaz/img-maker/cache"
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+func newTestServer(t *testing.T, cfg Config) *httptest.Server {
+	t.Helper()
+	cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	ts := httptest.NewServer(New(cfg).Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func testOrder() ordersummary.OrderSummary {
+	return ordersummary.OrderSummary{
+		OrderID:  "#1042",
+		Items:    []ordersummary.Item{{Name: "Phalaenopsis Orchid", Quantity: 1, Price: 349.99}},
+		Subtotal: 349.99,
+		Total:    349.99,
+		Currency: "INR",
+	}
+}
+
+// post sends req to path with the given headers, returning the response and its body
+func post(t *testing.T, ts *httptest.Server, path string, req any, header map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := http.NewRequest(http.MethodPost, ts.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, data
+}
+
+func TestRender(t *testing.T) {
+	ts := newTestServer(t, Config{})
+	resp, body := post(t, ts, "/render", RenderRequest{Order: testOrder()}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d: %s", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("got content type %q, want image/png", ct)
+	}
+	if !bytes.HasPrefix(body, []byte("\x89PNG")) {
+		t.Errorf("body is not a PNG")
+	}
+	if resp.Header.Get("ETag") == "" {
+		t.Errorf("response has no ETag")
+	}
+}
+
+func TestRenderRejectsInvalidRequests(t *testing.T) {
+	ts := newTestServer(t, Config{})
+	badQuantity := testOrder()
+	badQuantity.Items[0].Quantity = -1
+	noCurrency := testOrder()
+	noCurrency.Currency = ""
+	for _, tt := range []struct {
+		name   string
+		req    RenderRequest
+		accept string
+		want   int
+	}{
+		{"negative quantity", RenderRequest{Order: badQuantity}, "", http.StatusBadRequest},
+		{"no currency", RenderRequest{Order: noCurrency}, "", http.StatusBadRequest},
+		{"unknown format", RenderRequest{Order: testOrder(), Format: "gif"}, "", http.StatusBadRequest},
+		{"unacceptable", RenderRequest{Order: testOrder()}, "image/gif", http.StatusNotAcceptable},
+		{"no items", RenderRequest{Order: ordersummary.OrderSummary{Currency: "INR"}}, "", http.StatusUnprocessableEntity},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{}
+			if tt.accept != "" {
+				header["Accept"] = tt.accept
+			}
+			resp, body := post(t, ts, "/render", tt.req, header)
+			if resp.StatusCode != tt.want {
+				t.Errorf("got status %d, want %d: %s", resp.StatusCode, tt.want, body)
+			}
+		})
+	}
+}
+
+func TestRenderUnknownQueryFormat(t *testing.T) {
+	ts := newTestServer(t, Config{})
+	resp, body := post(t, ts, "/render?format=gif", RenderRequest{Order: testOrder()}, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d, want 400: %s", resp.StatusCode, body)
+	}
+}
+
+func TestRenderNotModified(t *testing.T) {
+	ts := newTestServer(t, Config{Cache: cache.NewLRU(1 << 20)})
+	req := RenderRequest{Order: testOrder()}
+	resp, _ := post(t, ts, "/render", req, nil)
+	etag := resp.Header.Get("ETag")
+
+	resp, body := post(t, ts, "/render", req, map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusNotModified || len(body) != 0 {
+		t.Errorf("got status %d with %d bytes, want 304 with none", resp.StatusCode, len(body))
+	}
+	if resp.Header.Get("ETag") != etag {
+		t.Errorf("304 has ETag %q, want %q", resp.Header.Get("ETag"), etag)
+	}
+}
+
+func TestRenderFailureIsNotNotModified(t *testing.T) {
+	ts := newTestServer(t, Config{})
+	footer := "{{.Nope}}"
+	resp, body := post(t, ts, "/render", RenderRequest{Order: testOrder(), Footer: &footer}, map[string]string{"If-None-Match": "*"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d, want 400: %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,44 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// Dir stores objects as files under Root at their Key. Files are served with the
+// type of their extension; cache headers are left to whatever serves them.
+type Dir struct {
+	Root string
+}
+
+// Put writes obj and returns its path. An existing file with the same key holds
+// the same content, so it is left alone.
+func (d Dir) Put(ctx context.Context, obj Object) (string, error) {
+	path := filepath.Join(d.Root, filepath.FromSlash(Key(obj)))
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, obj.Data) {
+		return path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so readers never see a partial image
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(obj.Data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
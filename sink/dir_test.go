@@ -0,0 +1,53 @@
+package sink_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/biswaz/img-maker/sink"
+)
+
+func TestDir(t *testing.T) {
+	root := t.TempDir()
+	d := sink.Dir{Root: root}
+	obj := testObject()
+
+	path, err := d.Put(context.Background(), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(root, filepath.FromSlash(sink.Key(obj))); path != want {
+		t.Errorf("Put = %q, want %q", path, want)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(data, obj.Data) {
+		t.Fatalf("stored %q, %v", data, err)
+	}
+
+	// The same content is not rewritten
+	info, _ := os.Stat(path)
+	if again, err := d.Put(context.Background(), obj); err != nil || again != path {
+		t.Errorf("second Put = %q, %v", again, err)
+	}
+	if info2, _ := os.Stat(path); !info2.ModTime().Equal(info.ModTime()) {
+		t.Error("second Put rewrote the file")
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("got %d files beside the object, want none left over", len(entries)-1)
+	}
+}
+
+func TestDirFailure(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(root, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (sink.Dir{Root: root}).Put(context.Background(), testObject()); err == nil {
+		t.Error("Put under a regular file succeeded")
+	}
+}
@@ -0,0 +1,120 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/biswaz/img-maker/internal/sigv4"
+)
+
+// S3 stores objects in a bucket of an S3-compatible store, such as AWS S3 or
+// MinIO, using path-style URLs and Signature Version 4
+type S3 struct {
+	// Endpoint is the store's base URL, such as "https://s3.us-east-1.amazonaws.com"
+	// or "http://localhost:9000"
+	Endpoint string
+	// Region is "us-east-1" when empty
+	Region string
+	Bucket string
+	// Prefix is prepended to every key, such as "receipts/"
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// PresignExpiry makes Put return a presigned GET URL valid this long instead
+	// of the plain object URL, for buckets that are not public
+	PresignExpiry time.Duration
+	// HTTPClient is http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// Put uploads obj with its content type and cache headers and returns its URL
+func (s *S3) Put(ctx context.Context, obj Object) (string, error) {
+	key := s.Prefix + Key(obj)
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(obj.Data))
+	if err != nil {
+		return "", err
+	}
+	if obj.ContentType != "" {
+		req.Header.Set("Content-Type", obj.ContentType)
+	}
+	req.Header.Set("Cache-Control", obj.cacheControl())
+	s.signer().Sign(req, sigv4.HashPayload(obj.Data), time.Now())
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", s3Error(resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	if s.PresignExpiry > 0 {
+		return s.Presign(key, s.PresignExpiry)
+	}
+	return u.String(), nil
+}
+
+// Presign returns a URL that allows anyone to GET the object at key until
+// expires has passed, up to seven days
+func (s *S3) Presign(key string, expires time.Duration) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	return s.signer().Presign(http.MethodGet, u, expires, time.Now())
+}
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	if s.Bucket == "" {
+		return nil, fmt.Errorf("s3 sink: bucket is not set")
+	}
+	u, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("s3 sink: invalid endpoint %q", s.Endpoint)
+	}
+	u.Path += "/" + s.Bucket + "/" + key
+	return u, nil
+}
+
+func (s *S3) signer() sigv4.Signer {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return sigv4.Signer{
+		Credentials: sigv4.Credentials{AccessKey: s.AccessKey, SecretKey: s.SecretKey, SessionToken: s.SessionToken},
+		Region:      region,
+		Service:     "s3",
+	}
+}
+
+// s3Error reads the XML error body S3 returns with a failed request
+func s3Error(resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &body) != nil || body.Code == "" {
+		return fmt.Errorf("s3 sink: HTTP %d", resp.StatusCode)
+	}
+	return fmt.Errorf("s3 sink: HTTP %d: %s: %s", resp.StatusCode, body.Code, body.Message)
+}
@@ -0,0 +1,121 @@
+package sink_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/biswaz/img-maker/sink"
+	"github.com/biswaz/img-maker/sink/s3test"
+)
+
+func newS3(t *testing.T) *s3test.Server {
+	t.Helper()
+	s := s3test.NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestS3Put(t *testing.T) {
+	s := newS3(t)
+	sk := s.Sink()
+	sk.Prefix = "receipts/"
+	obj := testObject()
+
+	location, err := sk.Put(context.Background(), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "receipts/" + sink.Key(obj)
+	if want := s.URL + "/" + s3test.Bucket + "/" + key; location != want {
+		t.Errorf("Put = %q, want %q", location, want)
+	}
+	stored, ok := s.Object(key)
+	if !ok {
+		t.Fatalf("no object at %q", key)
+	}
+	if !bytes.Equal(stored.Data, obj.Data) || stored.ContentType != "image/png" || stored.CacheControl != sink.DefaultCacheControl {
+		t.Errorf("stored %q as %q with Cache-Control %q", stored.Data, stored.ContentType, stored.CacheControl)
+	}
+}
+
+func TestS3PutCacheControl(t *testing.T) {
+	s := newS3(t)
+	obj := testObject()
+	obj.ContentType = "text/html; charset=utf-8"
+	obj.CacheControl = "no-store"
+	if _, err := s.Sink().Put(context.Background(), obj); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := s.Object(sink.Key(obj))
+	if stored.ContentType != obj.ContentType || stored.CacheControl != "no-store" {
+		t.Errorf("stored as %q with Cache-Control %q", stored.ContentType, stored.CacheControl)
+	}
+}
+
+func TestS3Presign(t *testing.T) {
+	s := newS3(t)
+	sk := s.Sink()
+	sk.PresignExpiry = time.Hour
+	obj := testObject()
+
+	location, err := sk.Put(context.Background(), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(location, "X-Amz-Signature=") || !strings.Contains(location, "X-Amz-Expires=3600") {
+		t.Fatalf("Put = %q, want a presigned URL", location)
+	}
+
+	resp, err := s.Client().Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(data, obj.Data) {
+		t.Fatalf("GET presigned URL: HTTP %d %q", resp.StatusCode, data)
+	}
+	if got := resp.Header.Get("Cache-Control"); got != sink.DefaultCacheControl {
+		t.Errorf("served with Cache-Control %q", got)
+	}
+
+	// The plain URL is not readable without a signature
+	plain, _, _ := strings.Cut(location, "?")
+	resp, err = s.Client().Get(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET unsigned URL: HTTP %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestS3BadCredentials(t *testing.T) {
+	s := newS3(t)
+	sk := s.Sink()
+	sk.SecretKey = "wrong"
+	_, err := sk.Put(context.Background(), testObject())
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("got error %v, want SignatureDoesNotMatch", err)
+	}
+	if n := s.Len(); n != 0 {
+		t.Errorf("stored %d objects", n)
+	}
+}
+
+func TestS3Config(t *testing.T) {
+	for name, sk := range map[string]*sink.S3{
+		"no bucket":    {Endpoint: "http://localhost:9000"},
+		"bad endpoint": {Endpoint: "localhost", Bucket: "receipts"},
+	} {
+		if _, err := sk.Put(context.Background(), testObject()); err == nil {
+			t.Errorf("%s: Put succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package s3test provides a local stand-in for an S3-compatible store, in the
+// manner of a MinIO test instance: objects are kept in memory and every request
+// must carry a valid Signature Version 4 header or presigned query.
+package s3test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/biswaz/img-maker/internal/sigv4"
+	"github.com/biswaz/img-maker/sink"
+)
+
+// Credentials and bucket served by a new Server
+const (
+	AccessKey = "s3test-access"
+	SecretKey = "s3test-secret"
+	Region    = "us-east-1"
+	Bucket    = "receipts"
+)
+
+// StoredObject is an object held by the server
+type StoredObject struct {
+	Data         []byte
+	ContentType  string
+	CacheControl string
+}
+
+// Server serves PUT, GET and HEAD for objects in Bucket
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string]StoredObject
+}
+
+// NewServer starts a stub server; callers must Close it
+func NewServer() *Server {
+	s := &Server{objects: make(map[string]StoredObject)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /{bucket}/{key...}", s.handlePut)
+	mux.HandleFunc("GET /{bucket}/{key...}", s.handleGet)
+	mux.HandleFunc("HEAD /{bucket}/{key...}", s.handleGet)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Sink returns an S3 sink for the server's bucket
+func (s *Server) Sink() *sink.S3 {
+	return &sink.S3{
+		Endpoint:   s.URL,
+		Region:     Region,
+		Bucket:     Bucket,
+		AccessKey:  AccessKey,
+		SecretKey:  SecretKey,
+		HTTPClient: s.Client(),
+	}
+}
+
+// Object returns the object stored at key
+func (s *Server) Object(key string) (StoredObject, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	return obj, ok
+}
+
+// Len returns the number of objects stored
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.objects)
+}
+
+func (s *Server) handlePut(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		s3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if !s.authorize(w, r, sigv4.HashPayload(data)) {
+		return
+	}
+	s.mu.Lock()
+	s.objects[r.PathValue("key")] = StoredObject{
+		Data:         data,
+		ContentType:  r.Header.Get("Content-Type"),
+		CacheControl: r.Header.Get("Cache-Control"),
+	}
+	s.mu.Unlock()
+	w.Header().Set("ETag", `"`+sigv4.HashPayload(data)[:32]+`"`)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, sigv4.HashPayload(nil)) {
+		return
+	}
+	obj, ok := s.Object(r.PathValue("key"))
+	if !ok {
+		s3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+	if obj.CacheControl != "" {
+		w.Header().Set("Cache-Control", obj.CacheControl)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(obj.Data)))
+	if r.Method == http.MethodGet {
+		w.Write(obj.Data)
+	}
+}
+
+// authorize checks the bucket and signature, writing an error response on failure
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, payloadHash string) bool {
+	if r.PathValue("bucket") != Bucket {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return false
+	}
+	secret := func(key string) (string, bool) { return SecretKey, key == AccessKey }
+	if err := sigv4.Verify(r, payloadHash, secret, time.Now()); err != nil {
+		s3Error(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		return false
+	}
+	return true
+}
+
+func s3Error(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: message})
+}
@@ -0,0 +1,119 @@
+// Package sink stores rendered order summaries once they leave the renderer.
+package sink
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Object is a rendered order summary to store
+type Object struct {
+	// OrderID identifies the order the object was rendered from
+	OrderID string
+	// Source names where the order came from, such as "shopify"
+	Source      string
+	Data        []byte
+	ContentType string
+	// CacheControl is sent with the object by sinks that serve it over HTTP;
+	// DefaultCacheControl when empty
+	CacheControl string
+}
+
+// DefaultCacheControl lets clients cache objects forever, which is safe because
+// keys include a hash of the content
+const DefaultCacheControl = "public, max-age=31536000, immutable"
+
+func (o Object) cacheControl() string {
+	if o.CacheControl == "" {
+		return DefaultCacheControl
+	}
+	return o.CacheControl
+}
+
+// Key returns the deterministic storage key of obj: its source, its order ID made
+// safe for paths, and a hash of its content with an extension for its type, as in
+// "shopify/1042/3f2a9c0b1d4e5f60.png". Rendering the same order the same way
+// always gives the same key.
+func Key(obj Object) string {
+	sum := sha256.Sum256(obj.Data)
+	name := hex.EncodeToString(sum[:8]) + extension(obj.ContentType)
+	key := safeSegment(obj.OrderID, "order") + "/" + name
+	if obj.Source != "" {
+		key = safeSegment(obj.Source, "source") + "/" + key
+	}
+	return key
+}
+
+// safeSegment keeps letters, digits, dots, dashes and underscores, replacing
+// runs of anything else with a dash
+func safeSegment(s, fallback string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range s {
+		if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' || r == '-') {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+	seg := strings.Trim(sb.String(), "-.")
+	if seg == "" {
+		return fallback
+	}
+	return seg
+}
+
+func extension(contentType string) string {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch mt {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "text/plain":
+		return ".txt"
+	case "text/html":
+		return ".html"
+	}
+	return ""
+}
+
+// Sink stores objects, returning where each one was stored
+type Sink interface {
+	Put(ctx context.Context, obj Object) (string, error)
+}
+
+// Func adapts a function to a Sink
+type Func func(ctx context.Context, obj Object) (string, error)
+
+// Put calls f
+func (f Func) Put(ctx context.Context, obj Object) (string, error) { return f(ctx, obj) }
+
+// Memory keeps objects in memory, for tests and local runs
+type Memory struct {
+	mu      sync.Mutex
+	objects []Object
+}
+
+// Put records obj and returns its position as "memory:<n>"
+func (m *Memory) Put(ctx context.Context, obj Object) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects = append(m.objects, obj)
+	return "memory:" + strconv.Itoa(len(m.objects)-1), nil
+}
+
+// Objects returns the objects stored so far
+func (m *Memory) Objects() []Object {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Object(nil), m.objects...)
+}
@@ -0,0 +1,66 @@
+package sink_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/biswaz/img-maker/sink"
+)
+
+// testObject is a stand-in for a rendered PNG
+func testObject() sink.Object {
+	return sink.Object{OrderID: "#1042", Source: "shopify", Data: []byte("\x89PNG fake image"), ContentType: "image/png"}
+}
+
+func TestKey(t *testing.T) {
+	obj := testObject()
+	key := sink.Key(obj)
+	if !strings.HasPrefix(key, "shopify/1042/") || !strings.HasSuffix(key, ".png") {
+		t.Errorf("Key = %q, want shopify/1042/<hash>.png", key)
+	}
+	if name := strings.TrimSuffix(key[len("shopify/1042/"):], ".png"); len(name) != 16 {
+		t.Errorf("Key = %q, want a 16 digit hash", key)
+	}
+	if again := sink.Key(testObject()); again != key {
+		t.Errorf("Key is not deterministic: %q then %q", key, again)
+	}
+
+	other := testObject()
+	other.Data = []byte("\x89PNG other image")
+	if sink.Key(other) == key {
+		t.Error("objects with different content share a key")
+	}
+}
+
+func TestKeySegments(t *testing.T) {
+	for _, tc := range []struct {
+		orderID, source, contentType string
+		prefix, ext                  string
+	}{
+		{"1042", "", "image/png", "1042/", ".png"},
+		{"#1042", "woocommerce", "image/jpeg", "woocommerce/1042/", ".jpg"},
+		{"../../etc/passwd", "shop/../x", "text/plain; charset=utf-8", "shop-..-x/etc-passwd/", ".txt"},
+		{"pedido número 7", "", "text/html; charset=utf-8", "pedido-n-mero-7/", ".html"},
+		{"###", "///", "application/octet-stream", "source/order/", ""},
+	} {
+		obj := sink.Object{OrderID: tc.orderID, Source: tc.source, Data: []byte("x"), ContentType: tc.contentType}
+		key := sink.Key(obj)
+		if !strings.HasPrefix(key, tc.prefix) || !strings.HasSuffix(key, tc.ext) || strings.Count(key, "/") != strings.Count(tc.prefix, "/") {
+			t.Errorf("Key(%q, %q, %q) = %q, want %s<hash>%s", tc.orderID, tc.source, tc.contentType, key, tc.prefix, tc.ext)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	var m sink.Memory
+	for i, want := range []string{"memory:0", "memory:1"} {
+		got, err := m.Put(context.Background(), testObject())
+		if err != nil || got != want {
+			t.Errorf("Put %d = %q, %v, want %q", i, got, err, want)
+		}
+	}
+	if n := len(m.Objects()); n != 2 {
+		t.Errorf("got %d objects, want 2", n)
+	}
+}
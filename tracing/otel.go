@@ -0,0 +1,72 @@
+// Package tracing adapts OpenTelemetry to the rendering trace hooks.
+//
+// Install an adapter with ordersummary.SetTracer to emit a span for every phase
+// of every render in the process:
+//
+//	ordersummary.SetTracer(tracing.OTel(otel.Tracer("ordersummary")))
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+// OTel returns an ordersummary.Tracer that starts its spans with t
+func OTel(t trace.Tracer) ordersummary.Tracer {
+	return otelTracer{t}
+}
+
+type otelTracer struct {
+	t trace.Tracer
+}
+
+func (o otelTracer) Start(ctx context.Context, name string, attrs ...ordersummary.Attr) (context.Context, ordersummary.Span) {
+	ctx, span := o.t.Start(ctx, name, trace.WithAttributes(convert(attrs)...))
+	return ctx, otelSpan{span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttributes(attrs ...ordersummary.Attr) {
+	s.span.SetAttributes(convert(attrs)...)
+}
+
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+		s.span.SetAttributes(attribute.String("error.kind", ordersummary.ErrorKind(err)))
+	}
+	s.span.End()
+}
+
+// convert maps attributes to OpenTelemetry's typed ones, formatting values of
+// any other type as strings
+func convert(attrs []ordersummary.Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,128 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/tracing"
+)
+
+// otelTracer is a trace.Tracer that keeps the spans it starts, standing in for
+// an SDK
+type otelTracer struct {
+	noop.Tracer
+	spans []*otelSpan
+}
+
+func (t *otelTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	s := &otelSpan{name: name, attrs: cfg.Attributes()}
+	t.spans = append(t.spans, s)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+type otelSpan struct {
+	noop.Span
+	name   string
+	attrs  []attribute.KeyValue
+	status codes.Code
+	errs   int
+	ended  bool
+}
+
+func (s *otelSpan) SetAttributes(kv ...attribute.KeyValue)  { s.attrs = append(s.attrs, kv...) }
+func (s *otelSpan) SetStatus(code codes.Code, _ string)     { s.status = code }
+func (s *otelSpan) RecordError(error, ...trace.EventOption) { s.errs++ }
+func (s *otelSpan) End(...trace.SpanEndOption)              { s.ended = true }
+
+func (s *otelSpan) attr(key string) attribute.Value {
+	for _, kv := range s.attrs {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestOTelAttributes(t *testing.T) {
+	ot := &otelTracer{}
+	tr := tracing.OTel(ot)
+	_, span := tr.Start(context.Background(), "phase",
+		ordersummary.Attr{Key: "s", Value: "png"},
+		ordersummary.Attr{Key: "i", Value: 2},
+		ordersummary.Attr{Key: "i64", Value: int64(1 << 40)},
+		ordersummary.Attr{Key: "f", Value: 1.5},
+		ordersummary.Attr{Key: "b", Value: true},
+		ordersummary.Attr{Key: "d", Value: time.Second},
+	)
+	span.SetAttributes(ordersummary.Attr{Key: "later", Value: "set"})
+	span.End(nil)
+
+	s := ot.spans[0]
+	if s.name != "phase" || !s.ended || s.status != codes.Unset || s.errs != 0 {
+		t.Errorf("span %+v", s)
+	}
+	for key, want := range map[string]attribute.Value{
+		"s":     attribute.StringValue("png"),
+		"i":     attribute.IntValue(2),
+		"i64":   attribute.Int64Value(1 << 40),
+		"f":     attribute.Float64Value(1.5),
+		"b":     attribute.BoolValue(true),
+		"d":     attribute.StringValue("1s"),
+		"later": attribute.StringValue("set"),
+	} {
+		if got := s.attr(key); got != want {
+			t.Errorf("attribute %s = %v (%v), want %v (%v)", key, got.Emit(), got.Type(), want.Emit(), want.Type())
+		}
+	}
+}
+
+func TestOTelError(t *testing.T) {
+	ot := &otelTracer{}
+	_, span := tracing.OTel(ot).Start(context.Background(), "phase")
+	span.End(fmt.Errorf("draw: %w", ordersummary.ErrCanvasTooLarge))
+
+	s := ot.spans[0]
+	if !s.ended || s.status != codes.Error || s.errs != 1 || s.attr("error.kind").AsString() != "canvas_too_large" {
+		t.Errorf("failed span has status %v, %d errors, attributes %v", s.status, s.errs, s.attrs)
+	}
+}
+
+func TestOTelRender(t *testing.T) {
+	ot := &otelTracer{}
+	ordersummary.SetTracer(tracing.OTel(ot))
+	defer ordersummary.SetTracer(nil)
+
+	order := ordersummary.OrderSummary{
+		Items:    []ordersummary.Item{{Name: "Phalaenopsis Orchid", Quantity: 1, Price: 349.99}},
+		Subtotal: 349.99,
+		Total:    349.99,
+		Currency: "INR",
+	}
+	var buf bytes.Buffer
+	if _, err := ordersummary.Render(order, &buf, ordersummary.Options{Layout: ordersummary.DefaultLayout()}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ot.spans) != 6 {
+		t.Fatalf("got %d spans, want 6", len(ot.spans))
+	}
+	root := ot.spans[0]
+	if root.name != ordersummary.SpanRender || root.attr("backend").AsString() != ordersummary.BackendRaw ||
+		root.attr("items").AsInt64() != 1 || root.attr("bytes").AsInt64() != int64(buf.Len()) {
+		t.Errorf("root span %s has attributes %v", root.name, root.attrs)
+	}
+	for _, s := range ot.spans {
+		if !s.ended {
+			t.Errorf("%s span did not end", s.name)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package tracetest records rendering trace spans in memory, for checking which
+// phases a render went through.
+package tracetest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+// Span is a finished span
+type Span struct {
+	Name string
+	// Parent is the name of the span this one was started under; empty for a root span
+	Parent     string
+	Attrs      map[string]any
+	Err        error
+	Start, End time.Time
+}
+
+// Duration is the time the span took
+func (s Span) Duration() time.Duration { return s.End.Sub(s.Start) }
+
+// Recorder is an ordersummary.Tracer that keeps every span it finishes. It is
+// safe for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+type spanKey struct{}
+
+// Start begins a span as a child of any recorded span in ctx
+func (r *Recorder) Start(ctx context.Context, name string, attrs ...ordersummary.Attr) (context.Context, ordersummary.Span) {
+	s := &span{r: r, rec: Span{Name: name, Attrs: map[string]any{}, Start: time.Now()}}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.rec.Parent = parent.rec.Name
+	}
+	s.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Spans returns the finished spans in the order they ended
+func (r *Recorder) Spans() []Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Span(nil), r.spans...)
+}
+
+// Named returns the finished spans called name
+func (r *Recorder) Named(name string) []Span {
+	var spans []Span
+	for _, s := range r.Spans() {
+		if s.Name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Reset forgets the finished spans
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+type span struct {
+	r   *Recorder
+	rec Span
+}
+
+// SetAttributes is only called by the goroutine that started the span
+func (s *span) SetAttributes(attrs ...ordersummary.Attr) {
+	for _, a := range attrs {
+		s.rec.Attrs[a.Key] = a.Value
+	}
+}
+
+func (s *span) End(err error) {
+	s.rec.Err = err
+	s.rec.End = time.Now()
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.r.spans = append(s.r.spans, s.rec)
+}
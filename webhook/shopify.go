@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+// shopifyOrder holds the fields of a Shopify order payload that appear on a summary
+type shopifyOrder struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	CreatedAt time.Time `json:"created_at"`
+	Currency  string    `json:"currency"`
+	Customer  *struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Phone     string `json:"phone"`
+	} `json:"customer"`
+	LineItems []struct {
+		Title        string `json:"title"`
+		VariantTitle string `json:"variant_title"`
+		Quantity     int    `json:"quantity"`
+		Price        amount `json:"price"`
+	} `json:"line_items"`
+	TotalLineItemsPrice amount        `json:"total_line_items_price"`
+	TotalDiscounts      amount        `json:"total_discounts"`
+	ShippingLines       []shopifyLine `json:"shipping_lines"`
+	TaxLines            []shopifyLine `json:"tax_lines"`
+	TotalTax            amount        `json:"total_tax"`
+	TotalPrice          amount        `json:"total_price"`
+}
+
+// shopifyLine is a shipping or tax line
+type shopifyLine struct {
+	Price amount `json:"price"`
+}
+
+func (l shopifyLine) price() amount { return l.Price }
+
+// ParseShopify maps a Shopify orders/create payload to an order summary. Line
+// items show their unit price before discounts, which appear as one total.
+func ParseShopify(body []byte) (ordersummary.OrderSummary, error) {
+	var o shopifyOrder
+	if err := json.Unmarshal(body, &o); err != nil {
+		return ordersummary.OrderSummary{}, fmt.Errorf("shopify order: %w", err)
+	}
+
+	summary := ordersummary.OrderSummary{
+		OrderID:   o.Name,
+		CreatedAt: o.CreatedAt,
+		Customer:  ordersummary.Customer{Email: o.Email, Phone: o.Phone},
+		Subtotal:  float64(o.TotalLineItemsPrice),
+		Discount:  float64(o.TotalDiscounts),
+		Shipping:  sum(o.ShippingLines, shopifyLine.price),
+		Taxes:     sum(o.TaxLines, shopifyLine.price),
+		Total:     float64(o.TotalPrice),
+		Currency:  o.Currency,
+	}
+	if summary.OrderID == "" {
+		summary.OrderID = strconv.FormatInt(o.ID, 10)
+	}
+	if o.Customer != nil {
+		summary.Customer.Name = joinName(o.Customer.FirstName, o.Customer.LastName)
+		if summary.Customer.Phone == "" {
+			summary.Customer.Phone = o.Customer.Phone
+		}
+	}
+	// Orders without tax lines still report their tax total
+	if len(o.TaxLines) == 0 {
+		summary.Taxes = float64(o.TotalTax)
+	}
+	for _, li := range o.LineItems {
+		name := li.Title
+		if li.VariantTitle != "" {
+			name += " - " + li.VariantTitle
+		}
+		summary.Items = append(summary.Items, ordersummary.Item{Name: name, Quantity: li.Quantity, Price: float64(li.Price)})
+	}
+	return summary, nil
+}
@@ -0,0 +1,260 @@
+// Package webhook renders order summaries from commerce platform order-created
+// webhooks. Payloads are verified against the shop's HMAC secret, mapped into an
+// ordersummary.OrderSummary, rendered and handed to a sink.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/sink"
+)
+
+// DefaultMaxBodyBytes limits webhook payloads, which can be large for big orders
+const DefaultMaxBodyBytes = 4 << 20
+
+// ErrSignature is returned when a payload's HMAC signature does not match
+var ErrSignature = errors.New("webhook: invalid signature")
+
+// ErrNoSink is returned by NewHandler when the config has no Sink for the rendered images
+var ErrNoSink = errors.New("webhook: no sink configured")
+
+// Platform describes how one commerce platform signs and shapes its webhooks
+type Platform struct {
+	// Name is used in routes and as the sink object's Source
+	Name string
+	// SignatureHeader holds the base64 HMAC-SHA256 of the body
+	SignatureHeader string
+	// TopicHeader names the event; only Topic is rendered
+	TopicHeader string
+	Topic       string
+	// Parse maps an order payload to an order summary
+	Parse func(body []byte) (ordersummary.OrderSummary, error)
+}
+
+// Supported platforms
+var (
+	Shopify = Platform{
+		Name:            "shopify",
+		SignatureHeader: "X-Shopify-Hmac-Sha256",
+		TopicHeader:     "X-Shopify-Topic",
+		Topic:           "orders/create",
+		Parse:           ParseShopify,
+	}
+	WooCommerce = Platform{
+		Name:            "woocommerce",
+		SignatureHeader: "X-WC-Webhook-Signature",
+		TopicHeader:     "X-WC-Webhook-Topic",
+		Topic:           "order.created",
+		Parse:           ParseWooCommerce,
+	}
+)
+
+// Config configures the webhook handler
+type Config struct {
+	// Secrets maps a platform name to its webhook signing secret. Platforms
+	// without a secret are not served.
+	Secrets map[string]string
+	// Sink stores the rendered images
+	Sink sink.Sink
+	// Options returns the render options for an order; DefaultOptions when nil
+	Options func(order ordersummary.OrderSummary) (ordersummary.Options, error)
+	// MaxBodyBytes is DefaultMaxBodyBytes when zero
+	MaxBodyBytes int64
+	// Logger is slog.Default() when nil
+	Logger *slog.Logger
+}
+
+// Handler serves POST /webhooks/{platform} for each configured platform
+type Handler struct {
+	cfg Config
+	mux *http.ServeMux
+}
+
+// NewHandler creates a webhook handler for the platforms with a secret in cfg.
+// It returns ErrNoSink if cfg.Sink is nil, since every rendered order needs a
+// place to go.
+func NewHandler(cfg Config) (*Handler, error) {
+	if cfg.Sink == nil {
+		return nil, ErrNoSink
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if cfg.Options == nil {
+		cfg.Options = DefaultOptions
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	h := &Handler{cfg: cfg, mux: http.NewServeMux()}
+	for _, p := range []Platform{Shopify, WooCommerce} {
+		if secret := cfg.Secrets[p.Name]; secret != "" {
+			h.mux.Handle("POST /webhooks/"+p.Name, h.platformHandler(p, secret))
+		}
+	}
+	return h, nil
+}
+
+// DefaultOptions renders a PNG with the default layout and English labels
+func DefaultOptions(order ordersummary.OrderSummary) (ordersummary.Options, error) {
+	text, err := ordersummary.TextContentForLocale(ordersummary.DefaultLocale, len(order.Items))
+	if err != nil {
+		return ordersummary.Options{}, err
+	}
+	return ordersummary.Options{Layout: ordersummary.DefaultLayout(), Text: text, Format: ordersummary.FormatPNG}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Response is the JSON body returned for a handled webhook
+type Response struct {
+	OrderID  string `json:"orderId,omitempty"`
+	Location string `json:"location,omitempty"`
+	// Ignored explains why an event was accepted without rendering
+	Ignored string `json:"ignored,omitempty"`
+}
+
+func (h *Handler) platformHandler(p Platform, secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.cfg.MaxBodyBytes))
+		if err != nil {
+			httpError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", h.cfg.MaxBodyBytes))
+			return
+		}
+		if err := Verify(body, r.Header.Get(p.SignatureHeader), secret); err != nil {
+			httpError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		// Other topics and delivery pings are acknowledged so the platform does not retry them
+		if topic := r.Header.Get(p.TopicHeader); topic != p.Topic {
+			writeJSON(w, http.StatusOK, Response{Ignored: fmt.Sprintf("topic %q", topic)})
+			return
+		}
+
+		order, err := p.Parse(body)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := order.Validate(); err != nil {
+			httpError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		location, err := h.render(r.Context(), p, order)
+		if err != nil {
+			h.cfg.Logger.Error("webhook render failed", "platform", p.Name, "order", order.OrderID,
+				"kind", ordersummary.ErrorKind(err), "err", err)
+			httpError(w, http.StatusInternalServerError, "failed to render order summary")
+			return
+		}
+		h.cfg.Logger.Info("webhook order stored", "platform", p.Name, "order", order.OrderID, "location", location)
+		writeJSON(w, http.StatusOK, Response{OrderID: order.OrderID, Location: location})
+	}
+}
+
+// render draws the order and stores it in the sink
+func (h *Handler) render(ctx context.Context, p Platform, order ordersummary.OrderSummary) (string, error) {
+	opts, err := h.cfg.Options(order)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	res, err := ordersummary.RenderContext(ctx, order, &buf, opts)
+	if err != nil {
+		return "", err
+	}
+	return h.cfg.Sink.Put(ctx, sink.Object{
+		OrderID:     order.OrderID,
+		Source:      p.Name,
+		Data:        buf.Bytes(),
+		ContentType: res.Format.ContentType(),
+	})
+}
+
+// Verify checks that signature is the base64 HMAC-SHA256 of body under secret,
+// as both Shopify and WooCommerce sign their webhooks
+func Verify(body []byte, signature, secret string) error {
+	got, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(got) == 0 {
+		return ErrSignature
+	}
+	if !hmac.Equal(got, Sign(body, secret)) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// Sign returns the HMAC-SHA256 of body under secret
+func Sign(body []byte, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// amount decodes money sent as a JSON string, as both platforms do, or a number
+type amount float64
+
+func (a *amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*a = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	*a = amount(v)
+	return nil
+}
+
+// sum adds amounts selected from each element of s
+func sum[T any](s []T, f func(T) amount) float64 {
+	var total float64
+	for _, v := range s {
+		total += float64(f(v))
+	}
+	return cents(total)
+}
+
+// cents rounds away the float error that adding decimal amounts leaves behind
+func cents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// joinName joins the non-empty parts of a name with spaces
+func joinName(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
+
+func httpError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
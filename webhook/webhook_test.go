@@ -0,0 +1,228 @@
+package webhook_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/biswaz/img-maker/sink"
+	"github.com/biswaz/img-maker/webhook"
+)
+
+// Rewrite the expected summaries after an intended mapping change with
+//
+//	go test ./webhook -update
+var update = flag.Bool("update", false, "write the mapped orders as the expected summaries under testdata")
+
+const secret = "test-secret"
+
+// fixtures are the recorded payloads under testdata/<platform>
+var fixtures = []struct {
+	platform webhook.Platform
+	name     string
+}{
+	{webhook.Shopify, "orders-create"},
+	{webhook.WooCommerce, "order-created"},
+}
+
+func readFixture(t *testing.T, p webhook.Platform, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", p.Name, name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func sign(body []byte) string {
+	return base64.StdEncoding.EncodeToString(webhook.Sign(body, secret))
+}
+
+// TestParse compares each mapped payload with the <name>.summary.json beside it
+func TestParse(t *testing.T) {
+	for _, f := range fixtures {
+		t.Run(f.platform.Name, func(t *testing.T) {
+			order, err := f.platform.Parse(readFixture(t, f.platform, f.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := order.Validate(); err != nil {
+				t.Errorf("mapped order is invalid: %v", err)
+			}
+			got, err := json.MarshalIndent(order, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", f.platform.Name, f.name+".summary.json")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("mapped order differs from %s:\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestParseRejectsMalformedPayloads(t *testing.T) {
+	for _, p := range []webhook.Platform{webhook.Shopify, webhook.WooCommerce} {
+		if _, err := p.Parse([]byte(`{"total_price": "lots"`)); err == nil {
+			t.Errorf("%s: parsed a truncated payload", p.Name)
+		}
+		if _, err := p.Parse([]byte(`{"total_price": "lots", "total": "lots"}`)); err == nil {
+			t.Errorf("%s: parsed an invalid amount", p.Name)
+		}
+	}
+	if _, err := webhook.ParseWooCommerce([]byte(`{"date_created_gmt": "yesterday"}`)); err == nil {
+		t.Error("woocommerce: parsed an invalid date")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	if err := webhook.Verify(body, sign(body), secret); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	if err := webhook.Verify(body, " "+sign(body)+"\n", secret); err != nil {
+		t.Errorf("signature with surrounding space: %v", err)
+	}
+	for name, signature := range map[string]string{
+		"empty":        "",
+		"not base64":   "%%%",
+		"other secret": base64.StdEncoding.EncodeToString(webhook.Sign(body, "other")),
+		"other body":   sign([]byte(`{"id":2}`)),
+	} {
+		if err := webhook.Verify(body, signature, secret); !errors.Is(err, webhook.ErrSignature) {
+			t.Errorf("%s: got %v, want ErrSignature", name, err)
+		}
+	}
+}
+
+func newHandler(t *testing.T, cfg webhook.Config) *webhook.Handler {
+	t.Helper()
+	h, err := webhook.NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// post sends body to the platform's route with the given signature and topic
+func post(h http.Handler, p webhook.Platform, body []byte, signature, topic string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/"+p.Name, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(p.SignatureHeader, signature)
+	req.Header.Set(p.TopicHeader, topic)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandlerStoresSignedOrders(t *testing.T) {
+	for _, f := range fixtures {
+		t.Run(f.platform.Name, func(t *testing.T) {
+			mem := &sink.Memory{}
+			h := newHandler(t, webhook.Config{Secrets: map[string]string{f.platform.Name: secret}, Sink: mem})
+			body := readFixture(t, f.platform, f.name)
+
+			rec := post(h, f.platform, body, sign(body), f.platform.Topic)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("HTTP %d: %s", rec.Code, rec.Body)
+			}
+			var resp webhook.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			objects := mem.Objects()
+			if len(objects) != 1 {
+				t.Fatalf("got %d objects in the sink, want 1", len(objects))
+			}
+			o := objects[0]
+			if o.Source != f.platform.Name || o.ContentType != "image/png" || !bytes.HasPrefix(o.Data, []byte("\x89PNG")) {
+				t.Errorf("stored %s object from %q", o.ContentType, o.Source)
+			}
+			if o.OrderID != resp.OrderID || resp.Location == "" {
+				t.Errorf("response %+v does not match the stored order %q", resp, o.OrderID)
+			}
+		})
+	}
+}
+
+func TestHandlerRejectsBadSignatures(t *testing.T) {
+	for _, f := range fixtures {
+		t.Run(f.platform.Name, func(t *testing.T) {
+			mem := &sink.Memory{}
+			h := newHandler(t, webhook.Config{Secrets: map[string]string{f.platform.Name: secret}, Sink: mem})
+			body := readFixture(t, f.platform, f.name)
+			tampered := bytes.Replace(body, []byte(`"total`), []byte(`"totaI`), 1)
+
+			for name, rec := range map[string]*httptest.ResponseRecorder{
+				"tampered body": post(h, f.platform, tampered, sign(body), f.platform.Topic),
+				"no signature":  post(h, f.platform, body, "", f.platform.Topic),
+				"other secret":  post(h, f.platform, body, base64.StdEncoding.EncodeToString(webhook.Sign(body, "other")), f.platform.Topic),
+			} {
+				if rec.Code != http.StatusUnauthorized {
+					t.Errorf("%s: got HTTP %d, want 401", name, rec.Code)
+				}
+			}
+			if n := len(mem.Objects()); n != 0 {
+				t.Errorf("rejected payloads stored %d objects", n)
+			}
+		})
+	}
+}
+
+func TestHandlerIgnoresOtherTopics(t *testing.T) {
+	mem := &sink.Memory{}
+	h := newHandler(t, webhook.Config{Secrets: map[string]string{webhook.Shopify.Name: secret}, Sink: mem})
+	body := readFixture(t, webhook.Shopify, "orders-create")
+
+	rec := post(h, webhook.Shopify, body, sign(body), "orders/updated")
+	var resp webhook.Response
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.Ignored == "" {
+		t.Errorf("got HTTP %d %+v, want 200 with the topic ignored", rec.Code, resp)
+	}
+	if n := len(mem.Objects()); n != 0 {
+		t.Errorf("other topic stored %d objects", n)
+	}
+}
+
+func TestHandlerServesOnlyConfiguredPlatforms(t *testing.T) {
+	h := newHandler(t, webhook.Config{Secrets: map[string]string{webhook.Shopify.Name: secret}, Sink: &sink.Memory{}})
+	body := readFixture(t, webhook.WooCommerce, "order-created")
+	if rec := post(h, webhook.WooCommerce, body, sign(body), webhook.WooCommerce.Topic); rec.Code != http.StatusNotFound {
+		t.Errorf("platform without a secret: got HTTP %d, want 404", rec.Code)
+	}
+}
+
+func TestHandlerLimitsBody(t *testing.T) {
+	h := newHandler(t, webhook.Config{Secrets: map[string]string{webhook.Shopify.Name: secret}, Sink: &sink.Memory{}, MaxBodyBytes: 64})
+	body := readFixture(t, webhook.Shopify, "orders-create")
+	if rec := post(h, webhook.Shopify, body, sign(body), webhook.Shopify.Topic); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got HTTP %d, want 413", rec.Code)
+	}
+}
+
+func TestNewHandlerRequiresSink(t *testing.T) {
+	h, err := webhook.NewHandler(webhook.Config{Secrets: map[string]string{webhook.Shopify.Name: secret}})
+	if !errors.Is(err, webhook.ErrNoSink) || h != nil {
+		t.Errorf("got %v, %v, want ErrNoSink", h, err)
+	}
+}
@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+// wooOrder holds the fields of a WooCommerce order payload that appear on a summary
+type wooOrder struct {
+	ID             int64  `json:"id"`
+	Number         string `json:"number"`
+	Currency       string `json:"currency"`
+	DateCreatedGMT string `json:"date_created_gmt"`
+	Billing        struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Email     string `json:"email"`
+		Phone     string `json:"phone"`
+	} `json:"billing"`
+	LineItems []struct {
+		Name     string `json:"name"`
+		Quantity int    `json:"quantity"`
+		// Subtotal is the line total before discounts
+		Subtotal amount `json:"subtotal"`
+	} `json:"line_items"`
+	DiscountTotal amount     `json:"discount_total"`
+	ShippingLines []wooTotal `json:"shipping_lines"`
+	TaxLines      []struct {
+		TaxTotal         amount `json:"tax_total"`
+		ShippingTaxTotal amount `json:"shipping_tax_total"`
+	} `json:"tax_lines"`
+	TotalTax amount `json:"total_tax"`
+	Total    amount `json:"total"`
+}
+
+// wooTotal is a shipping line
+type wooTotal struct {
+	Total amount `json:"total"`
+}
+
+func (l wooTotal) total() amount { return l.Total }
+
+// wooTimeLayout is how WooCommerce formats dates, without a zone
+const wooTimeLayout = "2006-01-02T15:04:05"
+
+// ParseWooCommerce maps a WooCommerce order.created payload to an order summary.
+// Line items show their unit price before discounts, which appear as one total.
+func ParseWooCommerce(body []byte) (ordersummary.OrderSummary, error) {
+	var o wooOrder
+	if err := json.Unmarshal(body, &o); err != nil {
+		return ordersummary.OrderSummary{}, fmt.Errorf("woocommerce order: %w", err)
+	}
+
+	summary := ordersummary.OrderSummary{
+		OrderID: o.Number,
+		Customer: ordersummary.Customer{
+			Name:  joinName(o.Billing.FirstName, o.Billing.LastName),
+			Email: o.Billing.Email,
+			Phone: o.Billing.Phone,
+		},
+		Discount: float64(o.DiscountTotal),
+		Shipping: sum(o.ShippingLines, wooTotal.total),
+		Total:    float64(o.Total),
+		Currency: o.Currency,
+	}
+	if summary.OrderID == "" {
+		summary.OrderID = strconv.FormatInt(o.ID, 10)
+	}
+	if o.DateCreatedGMT != "" {
+		t, err := time.Parse(wooTimeLayout, strings.TrimSuffix(o.DateCreatedGMT, "Z"))
+		if err != nil {
+			return ordersummary.OrderSummary{}, fmt.Errorf("woocommerce order: date_created_gmt: %w", err)
+		}
+		summary.CreatedAt = t.UTC()
+	}
+	for _, tl := range o.TaxLines {
+		summary.Taxes += float64(tl.TaxTotal + tl.ShippingTaxTotal)
+	}
+	summary.Taxes = cents(summary.Taxes)
+	if len(o.TaxLines) == 0 {
+		summary.Taxes = float64(o.TotalTax)
+	}
+	for _, li := range o.LineItems {
+		price := float64(li.Subtotal)
+		if li.Quantity > 0 {
+			price /= float64(li.Quantity)
+		}
+		summary.Items = append(summary.Items, ordersummary.Item{Name: li.Name, Quantity: li.Quantity, Price: price})
+		summary.Subtotal += float64(li.Subtotal)
+	}
+	summary.Subtotal = cents(summary.Subtotal)
+	return summary, nil
+}
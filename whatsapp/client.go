@@ -0,0 +1,280 @@
+// Package whatsapp uploads rendered order summaries to a WhatsApp Cloud API
+// compatible endpoint and sends them into a conversation as image messages.
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the Graph API the Cloud API is served from
+const DefaultBaseURL = "https://graph.facebook.com/v19.0"
+
+// Messenger uploads media and sends image messages. Client implements it against
+// the Cloud API; tests can use a Client pointed at whatsapptest.Server.
+type Messenger interface {
+	// UploadMedia uploads data and returns the media ID to send it with
+	UploadMedia(ctx context.Context, data []byte, mimeType string) (string, error)
+	// SendImage sends uploaded media to the phone number to and returns the message ID
+	SendImage(ctx context.Context, to, mediaID, caption string) (string, error)
+}
+
+// APIError is an error response from the Cloud API
+type APIError struct {
+	// Status is the HTTP status code of the response
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	TraceID string `json:"fbtrace_id"`
+	// RetryAfter is the delay requested by a Retry-After header, if any
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("whatsapp: HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("whatsapp: HTTP %d: %s (code %d)", e.Status, e.Message, e.Code)
+}
+
+// Temporary reports whether the request may succeed if retried: rate limits and
+// server errors are, rejected requests are not
+func (e *APIError) Temporary() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= http.StatusInternalServerError
+}
+
+// Backoff controls how failed requests are retried
+type Backoff struct {
+	// Attempts is the total number of tries, including the first; 1 disables retries
+	Attempts int
+	// Min is the delay before the first retry, doubling on each one up to Max
+	Min time.Duration
+	Max time.Duration
+}
+
+// DefaultBackoff tries a request up to four times. The three retries wait up to
+// 0.5s, 1s and 2s with full jitter: about 1.75s in all on average and 3.5s at most.
+var DefaultBackoff = Backoff{Attempts: 4, Min: 500 * time.Millisecond, Max: 8 * time.Second}
+
+// delay returns the wait before retry n, counting from 0, with full jitter so
+// concurrent senders do not retry in step
+func (b Backoff) delay(n int) time.Duration {
+	d := b.Min << n
+	if d <= 0 || d > b.Max {
+		d = b.Max
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+// Client calls the Cloud API for one business phone number
+type Client struct {
+	// BaseURL is DefaultBaseURL when empty
+	BaseURL string
+	// PhoneNumberID identifies the business phone number messages are sent from
+	PhoneNumberID string
+	// Token is the access token sent as a bearer token
+	Token string
+	// HTTPClient is http.DefaultClient when nil
+	HTTPClient *http.Client
+	// Backoff is DefaultBackoff when zero
+	Backoff Backoff
+}
+
+// UploadMedia uploads data as a media file of the given MIME type
+func (c *Client) UploadMedia(ctx context.Context, data []byte, mimeType string) (string, error) {
+	if err := checkMedia(data, mimeType); err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("messaging_product", "whatsapp")
+	mw.WriteField("type", mimeType)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="order-summary`+extension(mimeType)+`"`)
+	h.Set("Content-Type", mimeType)
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return "", err
+	}
+	part.Write(data)
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	var res struct {
+		ID string `json:"id"`
+	}
+	// Uploading twice only leaves an unused media object, so every failure is retried
+	err = c.do(ctx, "media", mw.FormDataContentType(), body.Bytes(), true, &res)
+	if err != nil {
+		return "", err
+	}
+	if res.ID == "" {
+		return "", errors.New("whatsapp: upload response has no media id")
+	}
+	return res.ID, nil
+}
+
+// SendImage sends an image message with an optional caption
+func (c *Client) SendImage(ctx context.Context, to, mediaID, caption string) (string, error) {
+	if err := checkCaption(caption); err != nil {
+		return "", err
+	}
+	type image struct {
+		ID      string `json:"id"`
+		Caption string `json:"caption,omitempty"`
+	}
+	body, err := json.Marshal(struct {
+		Product       string `json:"messaging_product"`
+		RecipientType string `json:"recipient_type"`
+		To            string `json:"to"`
+		Type          string `json:"type"`
+		Image         image  `json:"image"`
+	}{"whatsapp", "individual", to, "image", image{mediaID, caption}})
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	// A send whose response was lost may still have been delivered, so only
+	// responses the API marks as temporary are retried
+	if err := c.do(ctx, "messages", "application/json", body, false, &res); err != nil {
+		return "", err
+	}
+	if len(res.Messages) == 0 || res.Messages[0].ID == "" {
+		return "", errors.New("whatsapp: send response has no message id")
+	}
+	return res.Messages[0].ID, nil
+}
+
+// do posts body to the phone number's edge and decodes the JSON response into v,
+// retrying temporary failures. Transport errors are only retried when idempotent.
+func (c *Client) do(ctx context.Context, edge, contentType string, body []byte, idempotent bool, v any) error {
+	backoff := c.Backoff
+	if backoff == (Backoff{}) {
+		backoff = DefaultBackoff
+	}
+	attempts := max(backoff.Attempts, 1)
+
+	var err error
+	for n := 0; n < attempts; n++ {
+		if n > 0 {
+			wait := backoff.delay(n - 1)
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+				wait = apiErr.RetryAfter
+			}
+			if serr := sleep(ctx, wait); serr != nil {
+				return errors.Join(err, serr)
+			}
+		}
+
+		var retry bool
+		retry, err = c.post(ctx, edge, contentType, body, v)
+		if err == nil {
+			return nil
+		}
+		if !retry && !(idempotent && ctx.Err() == nil && isTransport(err)) {
+			return err
+		}
+	}
+	return fmt.Errorf("after %d attempts: %w", attempts, err)
+}
+
+// post makes a single request, reporting whether a failure is worth retrying
+func (c *Client) post(ctx context.Context, edge, contentType string, body []byte, v any) (bool, error) {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	u, err := url.JoinPath(base, url.PathEscape(c.PhoneNumberID), edge)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return false, &transportError{err}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return false, &transportError{err}
+	}
+
+	if resp.StatusCode/100 != 2 {
+		apiErr := &APIError{Status: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+		// The body fills in the API's code and message when it has them
+		env := struct {
+			Error *APIError `json:"error"`
+		}{apiErr}
+		json.Unmarshal(data, &env)
+		return apiErr.Temporary(), apiErr
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("whatsapp: decode %s response: %w", edge, err)
+	}
+	return false, nil
+}
+
+// transportError is a request that failed before a response was read
+type transportError struct{ err error }
+
+func (e *transportError) Error() string { return "whatsapp: " + e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+func isTransport(err error) bool {
+	var te *transportError
+	return errors.As(err, &te)
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(v string) time.Duration {
+	secs, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
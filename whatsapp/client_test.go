@@ -0,0 +1,163 @@
+package whatsapp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/biswaz/img-maker/whatsapp"
+	"github.com/biswaz/img-maker/whatsapp/whatsapptest"
+)
+
+var png = []byte("\x89PNG\r\n\x1a\n fake image")
+
+func newServer(t *testing.T) *whatsapptest.Server {
+	t.Helper()
+	s := whatsapptest.NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestUploadAndSend(t *testing.T) {
+	s := newServer(t)
+	c := s.Client()
+	ctx := context.Background()
+
+	mediaID, err := c.UploadMedia(ctx, png, "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageID, err := c.SendImage(ctx, "919800000000", mediaID, "Your order")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploads, messages := s.Uploads(), s.Messages()
+	if len(uploads) != 1 || uploads[0].MimeType != "image/png" || !bytes.Equal(uploads[0].Data, png) {
+		t.Errorf("got uploads %+v", uploads)
+	}
+	want := whatsapptest.Message{ID: messageID, To: "919800000000", MediaID: mediaID, Caption: "Your order"}
+	if len(messages) != 1 || messages[0] != want {
+		t.Errorf("got messages %+v, want %+v", messages, want)
+	}
+}
+
+func TestRetriesTemporaryFailures(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			s := newServer(t)
+			s.FailNext(2, status, "")
+			if _, err := s.Client().UploadMedia(context.Background(), png, "image/png"); err != nil {
+				t.Fatal(err)
+			}
+			if n := s.Requests(); n != 3 {
+				t.Errorf("got %d requests, want 3", n)
+			}
+		})
+	}
+}
+
+func TestSendRetriesTemporaryFailures(t *testing.T) {
+	s := newServer(t)
+	c := s.Client()
+	mediaID, err := c.UploadMedia(context.Background(), png, "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FailNext(1, http.StatusServiceUnavailable, "")
+	if _, err := c.SendImage(context.Background(), "919800000000", mediaID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Messages()); n != 1 {
+		t.Errorf("got %d messages, want 1", n)
+	}
+}
+
+func TestGivesUpAfterAttempts(t *testing.T) {
+	s := newServer(t)
+	c := s.Client()
+	c.Backoff.Attempts = 3
+	s.FailNext(5, http.StatusBadGateway, "")
+
+	_, err := c.UploadMedia(context.Background(), png, "image/png")
+	var apiErr *whatsapp.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway {
+		t.Fatalf("got error %v, want an APIError with status 502", err)
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("error %q does not give the attempts", err)
+	}
+	if n := s.Requests(); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+}
+
+func TestDoesNotRetryRejectedRequests(t *testing.T) {
+	s := newServer(t)
+	c := s.Client()
+	c.Token = "wrong"
+
+	_, err := c.UploadMedia(context.Background(), png, "image/png")
+	var apiErr *whatsapp.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || apiErr.Code != 190 {
+		t.Fatalf("got error %v, want an APIError with status 401 and code 190", err)
+	}
+	if apiErr.Temporary() {
+		t.Error("401 is reported as temporary")
+	}
+	if n := s.Requests(); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestHonorsRetryAfter(t *testing.T) {
+	s := newServer(t)
+	s.FailNext(1, http.StatusTooManyRequests, "1")
+
+	start := time.Now()
+	if _, err := s.Client().UploadMedia(context.Background(), png, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, before the requested 1s", elapsed)
+	}
+}
+
+func TestRetryAfterStopsWithContext(t *testing.T) {
+	s := newServer(t)
+	s.FailNext(1, http.StatusTooManyRequests, "60")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := s.Client().UploadMedia(ctx, png, "image/png")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestPreChecks(t *testing.T) {
+	s := newServer(t)
+	c := s.Client()
+	ctx := context.Background()
+
+	if _, err := c.UploadMedia(ctx, make([]byte, whatsapp.MaxImageBytes+1), "image/png"); !errors.Is(err, whatsapp.ErrMediaTooLarge) {
+		t.Errorf("oversized upload: got %v, want ErrMediaTooLarge", err)
+	}
+	if _, err := c.UploadMedia(ctx, png, "image/gif"); !errors.Is(err, whatsapp.ErrUnsupportedMedia) {
+		t.Errorf("GIF upload: got %v, want ErrUnsupportedMedia", err)
+	}
+	caption := strings.Repeat("é", whatsapp.MaxCaptionLength+1)
+	if _, err := c.SendImage(ctx, "919800000000", "media-1", caption); !errors.Is(err, whatsapp.ErrCaptionTooLong) {
+		t.Errorf("long caption: got %v, want ErrCaptionTooLong", err)
+	}
+	if _, err := c.UploadMedia(ctx, make([]byte, whatsapp.MaxImageBytes), "image/jpeg"); err != nil {
+		t.Errorf("upload at the size limit: %v", err)
+	}
+	if n := s.Requests(); n != 1 {
+		t.Errorf("got %d requests, want only the upload at the limit", n)
+	}
+}
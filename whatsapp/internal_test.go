@@ -0,0 +1,80 @@
+package whatsapp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+func TestRenderWithinFallsBackToJPEG(t *testing.T) {
+	order := ordersummary.OrderSummary{
+		Items:    []ordersummary.Item{{Name: "Phalaenopsis Orchid", Quantity: 1, Price: 349.99}},
+		Total:    349.99,
+		Currency: "INR",
+	}
+	opts := ordersummary.Options{Layout: ordersummary.DefaultLayout()}
+
+	data, res, err := renderWithin(order, opts, ordersummary.FormatPNG, MaxImageBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Format != ordersummary.FormatPNG {
+		t.Fatalf("a PNG within the limit was rendered as %s", res.Format)
+	}
+
+	data, res, err = renderWithin(order, opts, ordersummary.FormatPNG, len(data)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Format != ordersummary.FormatJPEG || !bytes.HasPrefix(data, []byte{0xff, 0xd8}) {
+		t.Errorf("a PNG over the limit was rendered as %s", res.Format)
+	}
+
+	// JPEGs have no smaller fallback
+	if _, res, _ = renderWithin(order, opts, ordersummary.FormatJPEG, 1); res.Format != ordersummary.FormatJPEG {
+		t.Errorf("a JPEG over the limit was rendered as %s", res.Format)
+	}
+}
+
+func TestClipCaption(t *testing.T) {
+	short := "Order Summary, 1 item"
+	if got := clipCaption(short); got != short {
+		t.Errorf("clipCaption changed a short caption to %q", got)
+	}
+
+	// Flags are two runes each, so clipping must not split one
+	long := strings.Repeat("🇮🇳", MaxCaptionLength)
+	got := clipCaption(long)
+	if n := utf8.RuneCountInString(got); n > MaxCaptionLength {
+		t.Errorf("clipped caption has %d characters", n)
+	}
+	if body := strings.TrimSuffix(got, "…"); body == got || strings.Count(body, "🇮🇳")*2 != utf8.RuneCountInString(body) {
+		t.Errorf("clipped caption split a grapheme or lacks the ellipsis")
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Attempts: 5, Min: 100 * time.Millisecond, Max: 300 * time.Millisecond}
+	for n, limit := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond} {
+		for range 100 {
+			if d := b.delay(n); d <= 0 || d > limit {
+				t.Fatalf("delay(%d) = %v, want within (0, %v]", n, d, limit)
+			}
+		}
+	}
+	if d := (Backoff{Attempts: 3}).delay(2); d != 0 {
+		t.Errorf("zero backoff waits %v", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for in, want := range map[string]time.Duration{"": 0, "3": 3 * time.Second, " 10 ": 10 * time.Second, "-1": 0, "soon": 0} {
+		if got := retryAfter(in); got != want {
+			t.Errorf("retryAfter(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
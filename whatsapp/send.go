@@ -0,0 +1,147 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+// Cloud API limits checked before uploading, so oversized media fails fast
+// instead of after a round trip
+const (
+	// MaxImageBytes is the largest image the Cloud API accepts
+	MaxImageBytes = 5 << 20
+	// MaxCaptionLength is the longest image caption, in characters
+	MaxCaptionLength = 1024
+)
+
+// Errors returned by the pre-upload checks
+var (
+	ErrMediaTooLarge    = errors.New("whatsapp: media too large")
+	ErrUnsupportedMedia = errors.New("whatsapp: unsupported media type")
+	ErrCaptionTooLong   = errors.New("whatsapp: caption too long")
+)
+
+func checkMedia(data []byte, mimeType string) error {
+	if extension(mimeType) == "" {
+		return fmt.Errorf("%w: %q", ErrUnsupportedMedia, mimeType)
+	}
+	if len(data) > MaxImageBytes {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrMediaTooLarge, len(data), MaxImageBytes)
+	}
+	return nil
+}
+
+func checkCaption(caption string) error {
+	if n := utf8.RuneCountInString(caption); n > MaxCaptionLength {
+		return fmt.Errorf("%w: %d characters, the limit is %d", ErrCaptionTooLong, n, MaxCaptionLength)
+	}
+	return nil
+}
+
+// clipCaption shortens a generated caption to MaxCaptionLength characters,
+// ending it with an ellipsis on a grapheme boundary
+func clipCaption(caption string) string {
+	if utf8.RuneCountInString(caption) <= MaxCaptionLength {
+		return caption
+	}
+	var sb strings.Builder
+	n := 0
+	g := uniseg.NewGraphemes(caption)
+	for g.Next() {
+		runes := g.Runes()
+		if n+len(runes) > MaxCaptionLength-1 {
+			break
+		}
+		sb.WriteString(g.Str())
+		n += len(runes)
+	}
+	return sb.String() + "…"
+}
+
+// extension returns the file extension for an image type the Cloud API accepts,
+// or "" for any other type
+func extension(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	}
+	return ""
+}
+
+// Sent describes an order summary sent as an image message
+type Sent struct {
+	MediaID   string
+	MessageID string
+	Format    ordersummary.Format
+	Bytes     int
+	Caption   string
+}
+
+// SendOrderSummary renders the order, uploads the image and sends it to the phone
+// number to. An empty caption uses the summary's alt text, clipped to
+// MaxCaptionLength. A PNG over MaxImageBytes is rendered again as JPEG before
+// giving up with ErrMediaTooLarge.
+func SendOrderSummary(ctx context.Context, m Messenger, to string, order ordersummary.OrderSummary, opts ordersummary.Options, caption string) (*Sent, error) {
+	format, err := ordersummary.ParseFormat(string(opts.Format))
+	if err != nil {
+		return nil, err
+	}
+	if format != ordersummary.FormatPNG && format != ordersummary.FormatJPEG {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMedia, format)
+	}
+
+	opts.AltText = caption == ""
+	data, res, err := renderWithin(order, opts, format, MaxImageBytes)
+	if err != nil {
+		return nil, err
+	}
+	format = res.Format
+	if caption == "" {
+		// Long item names can make the alt text too long, so it is clipped rather than rejected
+		caption = clipCaption(res.AltText)
+	}
+	if err := checkCaption(caption); err != nil {
+		return nil, err
+	}
+
+	mediaID, err := m.UploadMedia(ctx, data, format.ContentType())
+	if err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+	messageID, err := m.SendImage(ctx, to, mediaID, caption)
+	if err != nil {
+		return nil, fmt.Errorf("send: %w", err)
+	}
+	return &Sent{MediaID: mediaID, MessageID: messageID, Format: format, Bytes: len(data), Caption: caption}, nil
+}
+
+// renderWithin renders the order in format, rendering a PNG over limit bytes
+// again as JPEG. The result may still exceed limit; UploadMedia reports that
+// with ErrMediaTooLarge.
+func renderWithin(order ordersummary.OrderSummary, opts ordersummary.Options, format ordersummary.Format, limit int) ([]byte, *ordersummary.Result, error) {
+	data, res, err := render(order, opts, format)
+	if err != nil || len(data) <= limit || format != ordersummary.FormatPNG {
+		return data, res, err
+	}
+	return render(order, opts, ordersummary.FormatJPEG)
+}
+
+func render(order ordersummary.OrderSummary, opts ordersummary.Options, format ordersummary.Format) ([]byte, *ordersummary.Result, error) {
+	opts.Format = format
+	var buf bytes.Buffer
+	res, err := ordersummary.Render(order, &buf, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), res, nil
+}
@@ -0,0 +1,90 @@
+package whatsapp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/whatsapp"
+)
+
+func testOrder(items int) ordersummary.OrderSummary {
+	o := ordersummary.OrderSummary{OrderID: "#1042", Currency: "INR"}
+	for range items {
+		o.Items = append(o.Items, ordersummary.Item{Name: "Phalaenopsis Orchid", Quantity: 1, Price: 349.99})
+		o.Total += 349.99
+	}
+	o.Subtotal = o.Total
+	return o
+}
+
+func testOptions(format ordersummary.Format) ordersummary.Options {
+	text, _ := ordersummary.TextContentForLocale(ordersummary.DefaultLocale, 1)
+	return ordersummary.Options{Layout: ordersummary.DefaultLayout(), Text: text, Format: format}
+}
+
+func TestSendOrderSummary(t *testing.T) {
+	s := newServer(t)
+	sent, err := whatsapp.SendOrderSummary(context.Background(), s.Client(), "919800000000", testOrder(1), testOptions(""), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Format != ordersummary.FormatPNG {
+		t.Errorf("sent %s, want png", sent.Format)
+	}
+	if !strings.HasPrefix(sent.Caption, "Order Summary, ") || !strings.Contains(sent.Caption, "Phalaenopsis Orchid") {
+		t.Errorf("caption %q is not the alt text", sent.Caption)
+	}
+	uploads, messages := s.Uploads(), s.Messages()
+	if len(uploads) != 1 || !bytes.HasPrefix(uploads[0].Data, []byte("\x89PNG")) || len(uploads[0].Data) != sent.Bytes {
+		t.Fatalf("got uploads %+v", uploads)
+	}
+	if len(messages) != 1 || messages[0].MediaID != sent.MediaID || messages[0].Caption != sent.Caption {
+		t.Errorf("got messages %+v for %+v", messages, sent)
+	}
+}
+
+func TestSendOrderSummaryJPEG(t *testing.T) {
+	s := newServer(t)
+	sent, err := whatsapp.SendOrderSummary(context.Background(), s.Client(), "919800000000", testOrder(1), testOptions(ordersummary.FormatJPEG), "Thanks!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Format != ordersummary.FormatJPEG || s.Uploads()[0].MimeType != "image/jpeg" || sent.Caption != "Thanks!" {
+		t.Errorf("got %+v", sent)
+	}
+}
+
+func TestSendOrderSummaryClipsAltText(t *testing.T) {
+	s := newServer(t)
+	order := testOrder(3)
+	for i := range order.Items {
+		order.Items[i].Name = strings.Repeat("Phalaenopsis Orchid ", 30)
+	}
+	sent, err := whatsapp.SendOrderSummary(context.Background(), s.Client(), "919800000000", order, testOptions(""), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := utf8.RuneCountInString(sent.Caption); n != whatsapp.MaxCaptionLength || !strings.HasSuffix(sent.Caption, "…") {
+		t.Errorf("caption has %d characters, ending %q", n, sent.Caption[len(sent.Caption)-10:])
+	}
+}
+
+func TestSendOrderSummaryPreChecks(t *testing.T) {
+	s := newServer(t)
+	ctx := context.Background()
+	if _, err := whatsapp.SendOrderSummary(ctx, s.Client(), "919800000000", testOrder(1), testOptions(ordersummary.FormatHTML), ""); !errors.Is(err, whatsapp.ErrUnsupportedMedia) {
+		t.Errorf("HTML: got %v, want ErrUnsupportedMedia", err)
+	}
+	caption := strings.Repeat("x", whatsapp.MaxCaptionLength+1)
+	if _, err := whatsapp.SendOrderSummary(ctx, s.Client(), "919800000000", testOrder(1), testOptions(""), caption); !errors.Is(err, whatsapp.ErrCaptionTooLong) {
+		t.Errorf("long caption: got %v, want ErrCaptionTooLong", err)
+	}
+	if n := s.Requests(); n != 0 {
+		t.Errorf("pre-checks made %d requests", n)
+	}
+}
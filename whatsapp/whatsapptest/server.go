@@ -0,0 +1,229 @@
+// Package whatsapptest provides a local fake of the Cloud API media and messages
+// endpoints, so uploads can be exercised without a WhatsApp business account.
+package whatsapptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/biswaz/img-maker/whatsapp"
+)
+
+// Credentials accepted by a new Server
+const (
+	PhoneNumberID = "100000000000001"
+	Token         = "test-token"
+)
+
+// Upload is a media file received by the server
+type Upload struct {
+	ID       string
+	MimeType string
+	Data     []byte
+}
+
+// Message is an image message received by the server
+type Message struct {
+	ID      string
+	To      string
+	MediaID string
+	Caption string
+}
+
+// Server fakes the Cloud API for PhoneNumberID. It checks the token, the upload
+// form and the media limits, and records what it receives.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	uploads  []Upload
+	messages []Message
+	failures []failure
+	requests int
+}
+
+// failure is a queued error response
+type failure struct {
+	status     int
+	retryAfter string
+}
+
+// NewServer starts a fake server; callers must Close it
+func NewServer() *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{phone}/media", s.handleMedia)
+	mux.HandleFunc("POST /{phone}/messages", s.handleMessages)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Client returns a client for the server with retries that do not wait
+func (s *Server) Client() *whatsapp.Client {
+	return &whatsapp.Client{
+		BaseURL:       s.URL,
+		PhoneNumberID: PhoneNumberID,
+		Token:         Token,
+		HTTPClient:    s.Server.Client(),
+		Backoff:       whatsapp.Backoff{Attempts: whatsapp.DefaultBackoff.Attempts},
+	}
+}
+
+// FailNext makes the next n requests fail with status, sending a Retry-After
+// header when retryAfter is set
+func (s *Server) FailNext(n, status int, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{status, retryAfter})
+	}
+}
+
+// Uploads returns the media received so far
+func (s *Server) Uploads() []Upload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Upload(nil), s.uploads...)
+}
+
+// Messages returns the messages received so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Requests returns the number of requests handled, including failed ones
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// begin counts the request and checks it, writing an error response and
+// returning false if it must fail
+func (s *Server) begin(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	s.requests++
+	var f *failure
+	if len(s.failures) > 0 {
+		f = &s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	switch {
+	case f != nil:
+		if f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+		apiError(w, f.status, 0, http.StatusText(f.status))
+	case r.Header.Get("Authorization") != "Bearer "+Token:
+		apiError(w, http.StatusUnauthorized, 190, "Invalid OAuth access token")
+	case r.PathValue("phone") != PhoneNumberID:
+		apiError(w, http.StatusBadRequest, 100, "Unknown phone number ID")
+	default:
+		return true
+	}
+	return false
+}
+
+func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
+	if !s.begin(w, r) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, whatsapp.MaxImageBytes+1<<16)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		apiError(w, http.StatusBadRequest, 100, "Invalid upload: "+err.Error())
+		return
+	}
+	if r.FormValue("messaging_product") != "whatsapp" {
+		apiError(w, http.StatusBadRequest, 100, "messaging_product must be whatsapp")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		apiError(w, http.StatusBadRequest, 100, "Missing file")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, 100, err.Error())
+		return
+	}
+	mimeType := header.Header.Get("Content-Type")
+	switch {
+	case mimeType != "image/png" && mimeType != "image/jpeg":
+		apiError(w, http.StatusBadRequest, 131053, "Unsupported media type "+mimeType)
+		return
+	case len(data) > whatsapp.MaxImageBytes:
+		apiError(w, http.StatusBadRequest, 131053, "Media file too large")
+		return
+	}
+
+	s.mu.Lock()
+	id := fmt.Sprintf("media-%d", len(s.uploads)+1)
+	s.uploads = append(s.uploads, Upload{ID: id, MimeType: mimeType, Data: data})
+	s.mu.Unlock()
+	writeJSON(w, map[string]string{"id": id})
+}
+
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	if !s.begin(w, r) {
+		return
+	}
+	var req struct {
+		Product string `json:"messaging_product"`
+		To      string `json:"to"`
+		Type    string `json:"type"`
+		Image   struct {
+			ID      string `json:"id"`
+			Caption string `json:"caption"`
+		} `json:"image"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apiError(w, http.StatusBadRequest, 100, "Invalid JSON: "+err.Error())
+		return
+	}
+	if req.Product != "whatsapp" || req.Type != "image" || req.To == "" {
+		apiError(w, http.StatusBadRequest, 100, "Expected an image message with messaging_product and to")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for _, u := range s.uploads {
+		found = found || u.ID == req.Image.ID
+	}
+	if !found {
+		apiError(w, http.StatusBadRequest, 131009, "Unknown media ID "+req.Image.ID)
+		return
+	}
+	id := fmt.Sprintf("wamid.%d", len(s.messages)+1)
+	s.messages = append(s.messages, Message{ID: id, To: req.To, MediaID: req.Image.ID, Caption: req.Image.Caption})
+	writeJSON(w, map[string]any{
+		"messaging_product": "whatsapp",
+		"contacts":          []map[string]string{{"input": req.To, "wa_id": req.To}},
+		"messages":          []map[string]string{{"id": id}},
+	})
+}
+
+// apiError writes an error in the Graph API's error envelope
+func apiError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": message, "type": "OAuthException", "code": code, "fbtrace_id": "fake"},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/biswaz/img-maker/ordersummary"
+)
+
+func TestBackoff(t *testing.T) {
+	w := New(Config{MinBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond})
+	for n, limit := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond} {
+		for range 100 {
+			if d := w.backoff(n); d <= 0 || d > limit {
+				t.Fatalf("backoff(%d) = %v, want within (0, %v]", n, d, limit)
+			}
+		}
+	}
+	// Shifting far enough overflows, which must not disable the cap
+	if d := w.backoff(70); d <= 0 || d > 300*time.Millisecond {
+		t.Errorf("backoff(70) = %v", d)
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	err := errors.New("invalid order")
+	p := Permanent(err)
+	if !IsPermanent(p) || !errors.Is(p, err) || p.Error() != err.Error() {
+		t.Errorf("Permanent(%v) = %v", err, p)
+	}
+	if !IsPermanent(fmt.Errorf("job a: %w", p)) {
+		t.Error("wrapped permanent error is not permanent")
+	}
+	if Permanent(p) != p {
+		t.Error("Permanent wrapped a permanent error again")
+	}
+	if Permanent(nil) != nil || IsPermanent(err) {
+		t.Error("Permanent(nil) or a plain error is permanent")
+	}
+}
+
+func TestPoisonKind(t *testing.T) {
+	for _, err := range []error{ordersummary.ErrInvalidLayout, ordersummary.ErrTemplate, ordersummary.ErrUnsupportedFormat, ordersummary.ErrCanvasTooLarge} {
+		if !poisonKind(fmt.Errorf("render: %w", err)) {
+			t.Errorf("%v is not poison", err)
+		}
+	}
+	if poisonKind(errors.New("disk full")) {
+		t.Error("an environment error is poison")
+	}
+}
@@ -0,0 +1,360 @@
+// Package worker renders order summaries from a job queue in the background,
+// storing them in a sink and reporting each outcome to the job's callback URL.
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/biswaz/img-maker/internal/publicnet"
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/queue"
+	"github.com/biswaz/img-maker/sink"
+)
+
+// Defaults for unset Config fields
+const (
+	DefaultMaxAttempts = 5
+	DefaultMinBackoff  = time.Second
+	DefaultMaxBackoff  = 5 * time.Minute
+	DefaultSource      = "jobs"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of a callback body under
+// Config.CallbackSecret
+const SignatureHeader = "X-Ordersummary-Signature"
+
+// Config configures a worker
+type Config struct {
+	Queue queue.Queue
+	Sink  sink.Sink
+	// Prepare decodes a job's request into the order and its render options.
+	// Jobs it fails are dead-lettered without a retry.
+	Prepare func(job queue.Job) (ordersummary.OrderSummary, ordersummary.Options, error)
+	// Source names the jobs in sink keys; DefaultSource when empty
+	Source string
+	// Concurrency is the number of jobs rendered at once; 1 when zero
+	Concurrency int
+	// MaxAttempts is the number of times a job is tried before it is dead-lettered;
+	// DefaultMaxAttempts when zero
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, doubling on each one up to
+	// MaxBackoff; DefaultMinBackoff and DefaultMaxBackoff when zero
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// CallbackSecret signs callback bodies in SignatureHeader when set
+	CallbackSecret string
+	// CallbackHosts is the allowlist the server checked callback URLs against. When
+	// it is empty the default HTTPClient connects only to public addresses, so a
+	// host that resolved to one when the job was queued cannot be rebound to a
+	// loopback, private or link-local address before the callback is posted.
+	CallbackHosts []string
+	// HTTPClient sends callbacks; a client with a 10s timeout that does not follow
+	// redirects when nil
+	HTTPClient *http.Client
+	// Logger is slog.Default() when nil
+	Logger *slog.Logger
+}
+
+// Callback is the JSON body posted to a job's callback URL
+type Callback struct {
+	ID string `json:"id"`
+	// Status is "done" or "failed"
+	Status      string `json:"status"`
+	OrderID     string `json:"orderId,omitempty"`
+	Location    string `json:"location,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	// Attempts is the number of times the job was tried
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Callback statuses
+const (
+	StatusDone   = "done"
+	StatusFailed = "failed"
+)
+
+// Worker consumes render jobs from a queue
+type Worker struct {
+	cfg Config
+}
+
+// New creates a worker, filling unset configuration with defaults
+func New(cfg Config) *Worker {
+	if cfg.Source == "" {
+		cfg.Source = DefaultSource
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.HTTPClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if len(cfg.CallbackHosts) == 0 {
+			transport.DialContext = publicnet.Dialer(30 * time.Second).DialContext
+			// A proxy would make the connection on the worker's behalf, unchecked
+			transport.Proxy = nil
+		}
+		// A redirect could send the callback to a host the server did not allow
+		cfg.HTTPClient = &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &Worker{cfg: cfg}
+}
+
+// Run processes jobs until ctx is done, then waits for the jobs in progress and
+// returns nil. It returns early only if the queue fails to deliver jobs.
+func (w *Worker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, w.cfg.Concurrency)
+	for range w.cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loop dequeues and processes jobs one at a time. Queue errors are retried a few
+// times, since a restarting server drops connections.
+func (w *Worker) loop(ctx context.Context) error {
+	failures := 0
+	for {
+		job, err := w.cfg.Queue.Dequeue(ctx)
+		if ctx.Err() != nil {
+			if err == nil {
+				// Put back a job taken just as the worker was stopped
+				w.requeue(job, 0)
+			}
+			return nil
+		}
+		if err != nil {
+			if failures++; failures >= 5 {
+				return fmt.Errorf("worker: dequeue: %w", err)
+			}
+			w.cfg.Logger.Warn("dequeue failed", "err", err)
+			if sleep(ctx, w.backoff(failures-1)) != nil {
+				return nil
+			}
+			continue
+		}
+		failures = 0
+		w.Process(context.WithoutCancel(ctx), job)
+	}
+}
+
+// Process renders and stores one job, then retries it later, dead-letters it or
+// reports it done
+func (w *Worker) Process(ctx context.Context, job queue.Job) {
+	start := time.Now()
+	cb, err := w.render(ctx, job)
+	if err == nil {
+		cb.Attempts = job.Attempts + 1
+		w.cfg.Logger.Info("job done", "job", job.ID, "order", cb.OrderID, "location", cb.Location,
+			"attempts", cb.Attempts, "duration", time.Since(start))
+		w.callback(ctx, job, cb)
+		return
+	}
+
+	job.Attempts++
+	job.Error = err.Error()
+	if !IsPermanent(err) && job.Attempts < w.cfg.MaxAttempts {
+		delay := w.backoff(job.Attempts - 1)
+		w.cfg.Logger.Warn("job failed, retrying", "job", job.ID, "order", cb.OrderID, "attempts", job.Attempts,
+			"retry_in", delay.Round(time.Millisecond), "err", err)
+		w.requeue(job, delay)
+		return
+	}
+
+	w.cfg.Logger.Error("job failed, dead-lettering", "job", job.ID, "order", cb.OrderID, "attempts", job.Attempts,
+		"permanent", IsPermanent(err), "err", err)
+	if err := w.cfg.Queue.DeadLetter(ctx, job); err != nil {
+		w.cfg.Logger.Error("dead-letter failed", "job", job.ID, "err", err)
+	}
+	cb.ID, cb.Status, cb.Attempts, cb.Error = job.ID, StatusFailed, job.Attempts, job.Error
+	w.callback(ctx, job, cb)
+}
+
+// render draws the job's order and stores it, returning the callback to report
+// on success. The callback carries the order ID even when rendering fails.
+func (w *Worker) render(ctx context.Context, job queue.Job) (Callback, error) {
+	order, opts, err := w.cfg.Prepare(job)
+	if err != nil {
+		return Callback{}, Permanent(err)
+	}
+	cb := Callback{ID: job.ID, Status: StatusDone, OrderID: order.OrderID}
+	if err := order.Validate(); err != nil {
+		return cb, Permanent(err)
+	}
+
+	var buf bytes.Buffer
+	res, err := ordersummary.RenderContext(ctx, order, &buf, opts)
+	if err != nil {
+		if poisonKind(err) {
+			err = Permanent(err)
+		}
+		return cb, err
+	}
+	location, err := w.cfg.Sink.Put(ctx, sink.Object{
+		OrderID:     order.OrderID,
+		Source:      w.cfg.Source,
+		Data:        buf.Bytes(),
+		ContentType: res.Format.ContentType(),
+	})
+	if err != nil {
+		return cb, fmt.Errorf("store: %w", err)
+	}
+	cb.Location = location
+	cb.ContentType = res.Format.ContentType()
+	return cb, nil
+}
+
+// requeue puts a job back on the queue; it is lost if the queue fails as well
+func (w *Worker) requeue(job queue.Job, delay time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := w.cfg.Queue.Enqueue(ctx, job, delay); err != nil {
+		w.cfg.Logger.Error("requeue failed, job lost", "job", job.ID, "err", err)
+	}
+}
+
+// callback posts cb to the job's callback URL, trying up to three times. Failed
+// callbacks are logged and do not affect the job.
+func (w *Worker) callback(ctx context.Context, job queue.Job, cb Callback) {
+	if job.CallbackURL == "" {
+		return
+	}
+	body, err := json.Marshal(cb)
+	if err != nil {
+		w.cfg.Logger.Error("callback failed", "job", job.ID, "err", err)
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		err = w.postCallback(ctx, job.CallbackURL, body)
+		if err == nil || attempt == 2 {
+			break
+		}
+		if sleep(ctx, w.backoff(attempt)) != nil {
+			break
+		}
+	}
+	if err != nil {
+		w.cfg.Logger.Warn("callback failed", "job", job.ID, "url", job.CallbackURL, "err", err)
+	}
+}
+
+func (w *Worker) postCallback(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.cfg.CallbackSecret != "" {
+		req.Header.Set(SignatureHeader, Sign(body, w.cfg.CallbackSecret))
+	}
+	resp, err := w.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of a callback body under secret, as sent in
+// SignatureHeader
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the delay before retry n, counting from 0, with full jitter so
+// jobs that failed together are not retried together
+func (w *Worker) backoff(n int) time.Duration {
+	d := w.cfg.MinBackoff << n
+	if d <= 0 || d > w.cfg.MaxBackoff {
+		d = w.cfg.MaxBackoff
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that retrying cannot fix, so the job is
+// dead-lettered at once
+func Permanent(err error) error {
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+	return &permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// poisonKind reports whether a rendering error comes from the order or its
+// options rather than the environment, so the same job will always fail
+func poisonKind(err error) bool {
+	return errors.Is(err, ordersummary.ErrInvalidLayout) ||
+		errors.Is(err, ordersummary.ErrTemplate) ||
+		errors.Is(err, ordersummary.ErrUnsupportedFormat) ||
+		errors.Is(err, ordersummary.ErrCanvasTooLarge)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
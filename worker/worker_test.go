@@ -0,0 +1,381 @@
+package worker_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/biswaz/img-maker/ordersummary"
+	"github.com/biswaz/img-maker/queue"
+	"github.com/biswaz/img-maker/queue/redistest"
+	"github.com/biswaz/img-maker/sink"
+	"github.com/biswaz/img-maker/worker"
+)
+
+const secret = "callback-secret"
+
+// request is the job request the tests' Prepare accepts
+type request struct {
+	Order  ordersummary.OrderSummary `json:"order"`
+	Format ordersummary.Format       `json:"format,omitempty"`
+}
+
+func prepare(job queue.Job) (ordersummary.OrderSummary, ordersummary.Options, error) {
+	var req request
+	if err := json.Unmarshal(job.Request, &req); err != nil {
+		return ordersummary.OrderSummary{}, ordersummary.Options{}, err
+	}
+	text, err := ordersummary.TextContentForLocale(ordersummary.DefaultLocale, len(req.Order.Items))
+	if err != nil {
+		return ordersummary.OrderSummary{}, ordersummary.Options{}, err
+	}
+	return req.Order, ordersummary.Options{Layout: ordersummary.DefaultLayout(), Text: text, Format: req.Format}, nil
+}
+
+func testOrder(id string) ordersummary.OrderSummary {
+	return ordersummary.OrderSummary{
+		OrderID:  id,
+		Items:    []ordersummary.Item{{Name: "Phalaenopsis Orchid", Quantity: 1, Price: 349.99}},
+		Subtotal: 349.99,
+		Total:    349.99,
+		Currency: "INR",
+	}
+}
+
+func testJob(t *testing.T, id string, req request, callbackURL string) queue.Job {
+	t.Helper()
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return queue.Job{ID: id, Request: data, CallbackURL: callbackURL}
+}
+
+// receiver collects the callbacks posted to it, failing the first fail requests
+type receiver struct {
+	*httptest.Server
+	fail atomic.Int32
+
+	mu        sync.Mutex
+	callbacks []worker.Callback
+	// signatures holds the SignatureHeader of each callback, "" when it did not verify
+	signatures []string
+	arrived    chan struct{}
+}
+
+func newReceiver(t *testing.T) *receiver {
+	rc := &receiver{arrived: make(chan struct{}, 16)}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rc.fail.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var cb worker.Callback
+		json.Unmarshal(body, &cb)
+		signature := r.Header.Get(worker.SignatureHeader)
+		if signature != worker.Sign(body, secret) {
+			signature = ""
+		}
+		rc.mu.Lock()
+		rc.callbacks = append(rc.callbacks, cb)
+		rc.signatures = append(rc.signatures, signature)
+		rc.mu.Unlock()
+		rc.arrived <- struct{}{}
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+// wait returns the next callback
+func (rc *receiver) wait(t *testing.T) worker.Callback {
+	t.Helper()
+	select {
+	case <-rc.arrived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no callback")
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.signatures[len(rc.signatures)-1] == "" {
+		t.Error("callback signature does not verify")
+	}
+	return rc.callbacks[len(rc.callbacks)-1]
+}
+
+// flakySink fails the first n puts
+func flakySink(n int32, store sink.Sink) sink.Sink {
+	var calls atomic.Int32
+	return sink.Func(func(ctx context.Context, obj sink.Object) (string, error) {
+		if calls.Add(1) <= n {
+			return "", errors.New("store unavailable")
+		}
+		return store.Put(ctx, obj)
+	})
+}
+
+// callbackHosts allows the loopback receivers the tests post callbacks to
+var callbackHosts = []string{"127.0.0.1"}
+
+func newWorker(q queue.Queue, store sink.Sink) *worker.Worker {
+	return worker.New(worker.Config{
+		Queue:          q,
+		Sink:           store,
+		Prepare:        prepare,
+		Concurrency:    2,
+		MaxAttempts:    3,
+		MinBackoff:     10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		CallbackSecret: secret,
+		CallbackHosts:  callbackHosts,
+	})
+}
+
+func TestProcessDone(t *testing.T) {
+	rc := newReceiver(t)
+	q := queue.NewMemory()
+	defer q.Close()
+	store := &sink.Memory{}
+
+	newWorker(q, store).Process(context.Background(), testJob(t, "a", request{Order: testOrder("#1042")}, rc.URL))
+	cb := rc.wait(t)
+	want := worker.Callback{ID: "a", Status: worker.StatusDone, OrderID: "#1042", Location: "memory:0", ContentType: "image/png", Attempts: 1}
+	if cb != want {
+		t.Errorf("callback %+v, want %+v", cb, want)
+	}
+	objects := store.Objects()
+	if len(objects) != 1 || objects[0].Source != worker.DefaultSource || !bytes.HasPrefix(objects[0].Data, []byte("\x89PNG")) {
+		t.Errorf("stored %d objects", len(objects))
+	}
+	if q.Len() != 0 || len(q.Dead()) != 0 {
+		t.Error("a done job was requeued or dead-lettered")
+	}
+}
+
+func TestProcessRetries(t *testing.T) {
+	rc := newReceiver(t)
+	q := queue.NewMemory()
+	defer q.Close()
+	w := newWorker(q, flakySink(1, &sink.Memory{}))
+
+	w.Process(context.Background(), testJob(t, "a", request{Order: testOrder("#1042")}, rc.URL))
+	if n := q.Len(); n != 1 {
+		t.Fatalf("Len = %d, want the job requeued", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	job, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Attempts != 1 || !strings.Contains(job.Error, "store unavailable") {
+		t.Errorf("requeued %+v", job)
+	}
+
+	w.Process(context.Background(), job)
+	if cb := rc.wait(t); cb.Status != worker.StatusDone || cb.Attempts != 2 {
+		t.Errorf("callback %+v, want done after 2 attempts", cb)
+	}
+}
+
+func TestProcessDeadLettersAfterMaxAttempts(t *testing.T) {
+	rc := newReceiver(t)
+	q := queue.NewMemory()
+	defer q.Close()
+
+	job := testJob(t, "a", request{Order: testOrder("#1042")}, rc.URL)
+	job.Attempts = 2
+	newWorker(q, flakySink(1, &sink.Memory{})).Process(context.Background(), job)
+
+	cb := rc.wait(t)
+	if cb.Status != worker.StatusFailed || cb.Attempts != 3 || cb.OrderID != "#1042" || !strings.Contains(cb.Error, "store unavailable") {
+		t.Errorf("callback %+v", cb)
+	}
+	if dead := q.Dead(); len(dead) != 1 || dead[0].ID != "a" || dead[0].Attempts != 3 {
+		t.Errorf("dead letters %+v", dead)
+	}
+	if q.Len() != 0 {
+		t.Error("an exhausted job was requeued")
+	}
+}
+
+func TestProcessDeadLettersPoisonJobs(t *testing.T) {
+	invalid := testOrder("#1043")
+	invalid.Items[0].Quantity = 0
+	for name, job := range map[string]queue.Job{
+		"invalid order":      testJob(t, "a", request{Order: invalid}, ""),
+		"unsupported format": testJob(t, "a", request{Order: testOrder("#1044"), Format: "gif"}, ""),
+		"undecodable":        {ID: "a", Request: json.RawMessage(`"nope"`)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rc := newReceiver(t)
+			q := queue.NewMemory()
+			defer q.Close()
+			store := &sink.Memory{}
+			job.CallbackURL = rc.URL
+
+			newWorker(q, store).Process(context.Background(), job)
+			if cb := rc.wait(t); cb.Status != worker.StatusFailed || cb.Attempts != 1 || cb.Error == "" {
+				t.Errorf("callback %+v, want failed after 1 attempt", cb)
+			}
+			if dead := q.Dead(); len(dead) != 1 || dead[0].Attempts != 1 {
+				t.Errorf("dead letters %+v", dead)
+			}
+			if q.Len() != 0 || len(store.Objects()) != 0 {
+				t.Error("a poison job was requeued or stored")
+			}
+		})
+	}
+}
+
+func TestCallbackRetries(t *testing.T) {
+	rc := newReceiver(t)
+	rc.fail.Store(2)
+	q := queue.NewMemory()
+	defer q.Close()
+
+	newWorker(q, &sink.Memory{}).Process(context.Background(), testJob(t, "a", request{Order: testOrder("#1042")}, rc.URL))
+	if cb := rc.wait(t); cb.Status != worker.StatusDone {
+		t.Errorf("callback %+v", cb)
+	}
+}
+
+func TestCallbackUnsigned(t *testing.T) {
+	var signature atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature.Store(r.Header.Get(worker.SignatureHeader))
+	}))
+	defer ts.Close()
+	q := queue.NewMemory()
+	defer q.Close()
+
+	w := worker.New(worker.Config{Queue: q, Sink: &sink.Memory{}, Prepare: prepare, CallbackHosts: callbackHosts})
+	w.Process(context.Background(), testJob(t, "a", request{Order: testOrder("#1042")}, ts.URL))
+	if got, _ := signature.Load().(string); got != "" {
+		t.Errorf("callback without a secret was signed %q", got)
+	}
+}
+
+func TestCallbackDoesNotFollowRedirects(t *testing.T) {
+	var followed atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+	q := queue.NewMemory()
+	defer q.Close()
+
+	w := worker.New(worker.Config{Queue: q, Sink: &sink.Memory{}, Prepare: prepare, MinBackoff: time.Millisecond, CallbackHosts: callbackHosts})
+	w.Process(context.Background(), testJob(t, "a", request{Order: testOrder("#1042")}, redirect.URL))
+	if followed.Load() {
+		t.Error("callback followed a redirect")
+	}
+}
+
+// TestCallbackRefusesPrivateAddresses posts to a host name that resolves to
+// loopback when the worker connects, as a rebound name would after passing the
+// server's check, and expects the connection to be refused
+func TestCallbackRefusesPrivateAddresses(t *testing.T) {
+	var received atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Store(true)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Host = net.JoinHostPort("localhost", u.Port())
+	q := queue.NewMemory()
+	defer q.Close()
+
+	w := worker.New(worker.Config{Queue: q, Sink: &sink.Memory{}, Prepare: prepare, MinBackoff: time.Millisecond})
+	w.Process(context.Background(), testJob(t, "a", request{Order: testOrder("#1042")}, u.String()))
+	if received.Load() {
+		t.Error("callback was posted to a loopback address")
+	}
+
+	// The same name is reachable once it is on the allowlist
+	w = worker.New(worker.Config{Queue: q, Sink: &sink.Memory{}, Prepare: prepare, MinBackoff: time.Millisecond, CallbackHosts: []string{"localhost"}})
+	w.Process(context.Background(), testJob(t, "b", request{Order: testOrder("#1043")}, u.String()))
+	if !received.Load() {
+		t.Error("callback to an allowed host was not posted")
+	}
+}
+
+// run starts w and returns a function that stops it and returns Run's error
+func run(w *worker.Worker) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- w.Run(ctx) }()
+	return func() error {
+		cancel()
+		return <-errs
+	}
+}
+
+func TestRunRedis(t *testing.T) {
+	srv := redistest.NewServer("hunter2")
+	defer srv.Close()
+	q := srv.Queue()
+	defer q.Close()
+	rc := newReceiver(t)
+
+	// The worker's connection is dropped while it waits for a job, and the
+	// first store fails
+	srv.DropNext("BRPOP", 1)
+	stop := run(newWorker(q, flakySink(1, &sink.Memory{})))
+	for _, id := range []string{"a", "b"} {
+		if err := q.Enqueue(context.Background(), testJob(t, id, request{Order: testOrder("#" + id)}, rc.URL), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attempts := map[string]int{}
+	for range 2 {
+		cb := rc.wait(t)
+		if cb.Status != worker.StatusDone {
+			t.Errorf("callback %+v", cb)
+		}
+		attempts[cb.ID] = cb.Attempts
+	}
+	if attempts["a"]+attempts["b"] != 3 {
+		t.Errorf("attempts %v, want one retry between the jobs", attempts)
+	}
+	if err := stop(); err != nil {
+		t.Errorf("Run: %v", err)
+	}
+	if n := len(srv.List(queue.DefaultRedisKey)) + srv.ZCard(queue.DefaultRedisKey+":delayed"); n != 0 {
+		t.Errorf("%d jobs left queued", n)
+	}
+}
+
+func TestRunStopsWhenQueueFails(t *testing.T) {
+	q := queue.NewMemory()
+	q.Close()
+	w := worker.New(worker.Config{Queue: q, Sink: &sink.Memory{}, Prepare: prepare, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, CallbackHosts: callbackHosts})
+
+	errs := make(chan error, 1)
+	go func() { errs <- w.Run(context.Background()) }()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, queue.ErrClosed) {
+			t.Errorf("Run: got %v, want ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run kept going on a closed queue")
+	}
+}